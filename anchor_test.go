@@ -0,0 +1,101 @@
+package lexer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func makefileGrammar(b state.Builder[Token]) []state.Update[Token] {
+	return state.AsSlice[state.Update[Token]](
+		b.Named("Shebang").AtInputStart().Rune('#').Rune('!').UntilRune(state.IsRune('\n')).Emit(Mul),
+		b.Named("Target").AtLineStart().Identifier(state.GoIdentifier).Rune(':').Emit(Div),
+		b.Named("Recipe").AtColumn(0).Rune('\t').UntilRune(state.IsRune('\n')).Emit(String),
+		b.Named("Continuation").Rune('\\').AtLineEnd().Omit(),
+		b.Named("Newline").Rune('\n').Omit(),
+		b.Named("Spaces").WhileRune(state.IsRune(' ')).Omit(),
+		b.Named("Dependency").Identifier(state.GoIdentifier).Emit(Identifier),
+	)
+}
+
+func TestLexer_Anchors(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	type wantMessage struct {
+		token Token
+		value string
+		pos   int
+	}
+
+	input := "#!make\na: b \\\n c\n\tcc\nd:\n"
+	receiver := message.Slice[Token]()
+	l := lexer.New(
+		logger,
+		bytes.NewBufferString(input),
+		message.DefaultFactory[Token](),
+		receiver,
+		lexer.WithOnEOF(func(_ context.Context, emit func(Token) error) error {
+			return emit(Comma)
+		}),
+	).With(makefileGrammar)
+	assert.ErrorIs(t, l.Run(context.Background()), io.EOF)
+	// the hook is called once
+	assert.ErrorIs(t, l.Run(context.Background()), io.EOF)
+
+	got := make([]wantMessage, 0, len(receiver.Slice))
+	for _, msg := range receiver.Slice {
+		got = append(got, wantMessage{msg.Token, msg.AsString(), msg.Pos})
+	}
+	assert.Equal(t, []wantMessage{
+		{Mul, "#!make", 0},
+		{Div, "a:", 7},
+		{Identifier, "b", 10},
+		{Identifier, "c", 15},
+		{String, "\tcc", 17},
+		{Div, "d:", 21},
+		{Comma, "", 24},
+	}, got)
+
+	// the target is not matched in the middle of the line
+	receiver.Reset()
+	l.Reset(bytes.NewBufferString("a: b:"))
+	assert.Error(t, l.Run(context.Background()))
+	if assert.Len(t, receiver.Slice, 2) {
+		assert.Equal(t, Identifier, receiver.Slice[1].Token)
+	}
+}
+
+func TestLexer_PushOnEOF(t *testing.T) {
+	calls := 0
+	receiver := message.Slice[Token]()
+	l := lexer.NewPush(
+		logger.New(),
+		message.DefaultFactory[Token](),
+		receiver,
+		lexer.WithOnEOF(func(_ context.Context, emit func(Token) error) error {
+			calls++
+			return emit(Comma)
+		}),
+	).With(limitedGrammar)
+	assert.NoError(t, l.Feed(context.Background(), []byte("abc ")))
+	assert.NoError(t, l.Feed(context.Background(), []byte("de")))
+	assert.Equal(t, 0, calls)
+	assert.ErrorIs(t, l.Close(context.Background()), io.EOF)
+	assert.Equal(t, 1, calls)
+	if assert.Len(t, receiver.Slice, 3) {
+		assert.Equal(t, Comma, receiver.Slice[2].Token)
+		assert.Equal(t, 6, receiver.Slice[2].Pos)
+		assert.Equal(t, 0, receiver.Slice[2].Width)
+	}
+}
@@ -0,0 +1,242 @@
+package batch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+)
+
+type (
+	// Input is the named input of the batch.
+	Input struct {
+		Name   string
+		Reader io.Reader
+	}
+
+	// Stats are the statistics of the input lexing.
+	Stats struct {
+		// Bytes is the count of the bytes read from the input.
+		Bytes int64
+		// Tokens is the count of the token messages.
+		Tokens int
+		// Diagnostics is the count of the error and warning messages.
+		Diagnostics int
+		// Duration is the time spent on the input.
+		Duration time.Duration
+	}
+
+	// Result is the outcome of the input lexing.
+	Result[T any] struct {
+		// Name is the name of the input.
+		Name string
+		// Index is the index of the input in the stream.
+		Index int
+		// Tokens are the token messages.
+		Tokens []*message.Message[T]
+		// Diagnostics are the error and warning messages.
+		Diagnostics []*message.Message[T]
+		// Stats are the statistics of the input lexing.
+		Stats Stats
+		// Err is the error of the lexer run, it is nil if all input is processed.
+		Err error
+	}
+
+	// Batch lexes many inputs on the bounded pool of workers. The lexers are reused
+	// between the inputs, so the grammar is built once per lexer.
+	Batch[T any] struct {
+		logger    common.Logger
+		factory   message.Factory[T]
+		provider  state.Provider[T]
+		workers   int
+		ordered   bool
+		lexerOpts []lexer.Option[T]
+		pool      sync.Pool
+	}
+
+	// worker is the reusable lexer with its receiver.
+	worker[T any] struct {
+		lexer    *lexer.Lexer[T]
+		receiver *message.SliceReceiver[T]
+		counter  counter
+	}
+
+	// counter counts the bytes read from the reader.
+	counter struct {
+		reader io.Reader
+		n      int64
+	}
+
+	// job is the input with its index.
+	job struct {
+		index int
+		input Input
+	}
+)
+
+// Read implements io.Reader interface.
+func (c *counter) Read(p []byte) (n int, err error) {
+	n, err = c.reader.Read(p)
+	c.n += int64(n)
+	return
+}
+
+// New creates a new batch instance.
+func New[T any](opts ...Option[T]) (ret *Batch[T]) {
+	ret = &Batch[T]{
+		logger:  logger.Nop(),
+		factory: message.DefaultFactory[T](),
+		workers: runtime.GOMAXPROCS(0),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	common.AssertTrue(ret.workers > 0, "invalid workers count")
+	return
+}
+
+// With sets the state provider.
+func (b *Batch[T]) With(fn state.Provider[T]) *Batch[T] {
+	common.AssertNotNil(fn, "state provider is nil")
+	b.provider = fn
+	b.pool = sync.Pool{}
+	return b
+}
+
+// getWorker returns a worker from the pool or creates a new one.
+func (b *Batch[T]) getWorker() (ret *worker[T]) {
+	if w, ok := b.pool.Get().(*worker[T]); ok {
+		ret = w
+		return
+	}
+	ret = &worker[T]{receiver: message.Slice[T]()}
+	ret.lexer = lexer.New(b.logger, &ret.counter, b.factory, ret.receiver, b.lexerOpts...).With(b.provider)
+	return
+}
+
+// lex lexes the input by the worker.
+func (b *Batch[T]) lex(ctx context.Context, w *worker[T], j job) (ret Result[T]) {
+	started := time.Now()
+	w.counter = counter{reader: j.input.Reader}
+	w.lexer.Reset(&w.counter)
+	ret.Name = j.input.Name
+	ret.Index = j.index
+	ret.Err = w.lexer.Run(ctx)
+	if errors.Is(ret.Err, io.EOF) {
+		ret.Err = nil
+	} else if ctxErr := ctx.Err(); ctxErr != nil {
+		ret.Err = ctxErr
+	}
+	for _, msg := range w.receiver.Slice {
+		if msg.Type == message.Token {
+			ret.Tokens = append(ret.Tokens, msg)
+		} else {
+			ret.Diagnostics = append(ret.Diagnostics, msg)
+		}
+	}
+	w.receiver.Reset()
+	w.counter.reader = nil
+	ret.Stats = Stats{
+		Bytes:       w.counter.n,
+		Tokens:      len(ret.Tokens),
+		Diagnostics: len(ret.Diagnostics),
+		Duration:    time.Since(started),
+	}
+	return
+}
+
+// Run lexes the inputs from the channel until it is closed or the context is done.
+// The results are sent to the returned channel, it is closed after the last result.
+// If the context is done, the not started inputs are skipped. The returned channel must
+// be read until it is closed.
+func (b *Batch[T]) Run(ctx context.Context, inputs <-chan Input) <-chan Result[T] {
+	common.AssertNotNil(b.provider, "state provider is nil")
+	jobs := make(chan job)
+	results := make(chan Result[T], b.workers)
+	// in the ordered mode the slot is taken by the input until its result is delivered,
+	// so the count of the results waiting for the previous ones is limited by the workers count
+	var slots chan struct{}
+	if b.ordered {
+		slots = make(chan struct{}, b.workers)
+	}
+	go func() {
+		defer close(jobs)
+		index := 0
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case input, ok := <-inputs:
+				if !ok {
+					return
+				}
+				if slots != nil {
+					select {
+					case slots <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+				select {
+				case jobs <- job{index: index, input: input}:
+					index++
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for range b.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				w := b.getWorker()
+				results <- b.lex(ctx, w, j)
+				b.pool.Put(w)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	if !b.ordered {
+		return results
+	}
+	return reorder(results, slots)
+}
+
+// reorder delivers the results in the order of the inputs and frees the slot of
+// every delivered result.
+func reorder[T any](results <-chan Result[T], slots <-chan struct{}) <-chan Result[T] {
+	out := make(chan Result[T])
+	go func() {
+		defer close(out)
+		pending := map[int]Result[T]{}
+		next := 0
+		for result := range results {
+			pending[result.Index] = result
+			for {
+				ready, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				out <- ready
+				<-slots
+				next++
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,137 @@
+package batch_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/diakovliev/lexer/batch"
+	"github.com/diakovliev/lexer/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type Token int
+
+const (
+	Identifier Token = iota
+)
+
+func grammar(b state.Builder[Token]) []state.Update[Token] {
+	return state.AsSlice[state.Update[Token]](
+		b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+		b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+	)
+}
+
+func makeInputs(count int) <-chan batch.Input {
+	inputs := make(chan batch.Input)
+	go func() {
+		defer close(inputs)
+		for i := range count {
+			text := strings.Repeat("abc ", i%5+1)
+			if i%10 == 3 {
+				text += "+"
+			}
+			inputs <- batch.Input{Name: fmt.Sprintf("input%d", i), Reader: strings.NewReader(text)}
+		}
+	}()
+	return inputs
+}
+
+func TestBatch_Ordered(t *testing.T) {
+	b := batch.New(batch.WithWorkers[Token](4), batch.WithOrdered[Token]()).With(grammar)
+	index := 0
+	for result := range b.Run(context.Background(), makeInputs(100)) {
+		assert.Equal(t, index, result.Index)
+		assert.Equal(t, fmt.Sprintf("input%d", index), result.Name)
+		assert.Len(t, result.Tokens, index%5+1)
+		wantBytes := int64(4 * (index%5 + 1))
+		if index%10 == 3 {
+			assert.ErrorIs(t, result.Err, state.ErrIncomplete)
+			wantBytes++
+		} else {
+			assert.NoError(t, result.Err)
+		}
+		assert.Equal(t, wantBytes, result.Stats.Bytes)
+		assert.Equal(t, len(result.Tokens), result.Stats.Tokens)
+		index++
+	}
+	assert.Equal(t, 100, index)
+}
+
+// startReader counts the started inputs and holds the reading until it is released.
+type startReader struct {
+	reader  io.Reader
+	started *atomic.Int32
+	release chan struct{}
+	once    bool
+}
+
+func (r *startReader) Read(p []byte) (n int, err error) {
+	if !r.once {
+		r.once = true
+		r.started.Add(1)
+		<-r.release
+	}
+	return r.reader.Read(p)
+}
+
+func TestBatch_OrderedLimitsPending(t *testing.T) {
+	var started atomic.Int32
+	first := make(chan struct{})
+	released := make(chan struct{})
+	close(released)
+	inputs := make(chan batch.Input)
+	go func() {
+		defer close(inputs)
+		for i := range 20 {
+			release := released
+			if i == 0 {
+				release = first
+			}
+			reader := &startReader{reader: strings.NewReader("abc"), started: &started, release: release}
+			inputs <- batch.Input{Name: fmt.Sprintf("input%d", i), Reader: reader}
+		}
+	}()
+	results := batch.New(batch.WithWorkers[Token](2), batch.WithOrdered[Token]()).With(grammar).Run(context.Background(), inputs)
+	// the inputs are not lexed ahead while the first one is not done
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, started.Load(), int32(2))
+	close(first)
+	index := 0
+	for result := range results {
+		assert.Equal(t, index, result.Index)
+		index++
+	}
+	assert.Equal(t, 20, index)
+}
+
+func TestBatch_Unordered(t *testing.T) {
+	b := batch.New(batch.WithWorkers[Token](4)).With(grammar)
+	seen := map[int]bool{}
+	for result := range b.Run(context.Background(), makeInputs(50)) {
+		assert.False(t, seen[result.Index])
+		seen[result.Index] = true
+		for _, token := range result.Tokens {
+			assert.Equal(t, "abc", token.AsString())
+		}
+	}
+	assert.Len(t, seen, 50)
+}
+
+func TestBatch_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	inputs := make(chan batch.Input)
+	results := batch.New(batch.WithWorkers[Token](2)).With(grammar).Run(ctx, inputs)
+	inputs <- batch.Input{Name: "first", Reader: strings.NewReader("abc")}
+	result := <-results
+	assert.Equal(t, "first", result.Name)
+	cancel()
+	_, ok := <-results
+	assert.False(t, ok)
+}
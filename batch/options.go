@@ -0,0 +1,47 @@
+package batch
+
+import (
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+)
+
+// Option is an option that can be passed to the batch constructor.
+type Option[T any] func(*Batch[T])
+
+// WithLogger sets the logger to use for logging. If not set, no logging is done.
+func WithLogger[T any](logger common.Logger) Option[T] {
+	return func(b *Batch[T]) {
+		b.logger = logger
+	}
+}
+
+// WithFactory sets the message factory. The default is message.DefaultFactory.
+func WithFactory[T any](factory message.Factory[T]) Option[T] {
+	return func(b *Batch[T]) {
+		b.factory = factory
+	}
+}
+
+// WithWorkers sets the count of the inputs lexed concurrently. The default is
+// runtime.GOMAXPROCS(0).
+func WithWorkers[T any](workers int) Option[T] {
+	return func(b *Batch[T]) {
+		b.workers = workers
+	}
+}
+
+// WithOrdered enables delivery of the results in the order of the inputs. By default
+// the results are delivered as soon as they are ready.
+func WithOrdered[T any]() Option[T] {
+	return func(b *Batch[T]) {
+		b.ordered = true
+	}
+}
+
+// WithLexerOptions sets the options of the lexers.
+func WithLexerOptions[T any](opts ...lexer.Option[T]) Option[T] {
+	return func(b *Batch[T]) {
+		b.lexerOpts = opts
+	}
+}
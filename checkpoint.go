@@ -0,0 +1,188 @@
+package lexer
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+// checkpointVersion is the version of the checkpoint format.
+const checkpointVersion = 2
+
+var (
+	// ErrInvalidCheckpoint is returned by Restore if the checkpoint can't be decoded.
+	ErrInvalidCheckpoint = errors.New("invalid checkpoint")
+	// ErrUnknownEncoding is returned by Restore if the checkpoint encoding is not known.
+	ErrUnknownEncoding = errors.New("unknown encoding")
+)
+
+type (
+	// checkpoint is the serialized lexer progress.
+	checkpoint[T any] struct {
+		Version      int
+		Offset       int64
+		Column       int
+		Encoding     string
+		HistoryDepth int
+		History      []checkpointMessage[T]
+		// States is the data of the states keeping it between the top level commits
+		States [][]byte
+		User   []byte
+	}
+
+	// checkpointMessage is the serialized history message. The error values are stored
+	// by their text, the values other than []byte must be registered with gob.Register.
+	checkpointMessage[T any] struct {
+		Level   int
+		Type    message.Type
+		Token   T
+		Bytes   []byte
+		IsError bool
+		Err     string
+		Value   any
+		Pos     int
+		Width   int
+	}
+)
+
+func makeCheckpointMessage[T any](msg *message.Message[T]) (ret checkpointMessage[T]) {
+	ret = checkpointMessage[T]{
+		Level: msg.Level,
+		Type:  msg.Type,
+		Token: msg.Token,
+		Pos:   msg.Pos,
+		Width: msg.Width,
+	}
+	switch value := msg.Value.(type) {
+	case []byte:
+		ret.Bytes = value
+	case *message.ErrorValue:
+		ret.IsError = true
+		ret.Err = value.Err.Error()
+		if b, ok := value.Value.([]byte); ok {
+			ret.Bytes = b
+		} else {
+			ret.Value = value.Value
+		}
+	default:
+		ret.Value = value
+	}
+	return
+}
+
+func (cm checkpointMessage[T]) message() (ret *message.Message[T]) {
+	ret = &message.Message[T]{
+		Level: cm.Level,
+		Type:  cm.Type,
+		Token: cm.Token,
+		Pos:   cm.Pos,
+		Width: cm.Width,
+	}
+	switch {
+	case cm.IsError:
+		errorValue := &message.ErrorValue{Err: errors.New(cm.Err), Value: cm.Value}
+		if cm.Bytes != nil {
+			errorValue.Value = cm.Bytes
+		}
+		ret.Value = errorValue
+	case cm.Bytes != nil:
+		ret.Value = cm.Bytes
+	default:
+		ret.Value = cm.Value
+	}
+	return
+}
+
+// Checkpoint serializes the lexer progress at the last top level commit: the committed
+// input offset and its column, the current encoding, the history and the given user data. The lexer
+// always continues from its first state after a top level commit, so only the data kept
+// by the states between the top level commits, like the Layout indentation levels, is saved
+// by the states implementing state.Snapshotter.
+// Call it from the commit hook or after Run returns.
+func (l *Lexer[T]) Checkpoint(user []byte) (data []byte, err error) {
+	_, offset, err := l.source.Buffer()
+	common.AssertNoError(err, "get buffer error")
+	cp := checkpoint[T]{
+		Version:      checkpointVersion,
+		Offset:       offset,
+		Column:       xio.AsPosition(l.source).Column(),
+		Encoding:     xio.EncodingOf(l.source).Name(),
+		HistoryDepth: l.historyDepth,
+		User:         user,
+	}
+	if l.run != nil {
+		if cp.States, err = l.run.Snapshot(); err != nil {
+			return
+		}
+	} else {
+		// not run yet, e.g. just restored
+		cp.States = l.states
+	}
+	if l.history != nil {
+		for _, msg := range l.history.Get() {
+			cp.History = append(cp.History, makeCheckpointMessage(msg))
+		}
+	}
+	var buf bytes.Buffer
+	if err = gob.NewEncoder(&buf).Encode(cp); err != nil {
+		return
+	}
+	data = buf.Bytes()
+	return
+}
+
+// Restore creates a new lexer which continues lexing from the checkpoint. The reader must
+// provide the same input as the reader of the checkpointed lexer, it is positioned to the
+// checkpoint offset. The message positions are reported from the beginning of the input.
+// The provider must build the same states as the provider of the checkpointed lexer.
+// It returns the user data stored in the checkpoint. The options are applied after the
+// restored settings.
+func Restore[T any](
+	logger common.Logger,
+	reader io.ReadSeeker,
+	data []byte,
+	factory message.Factory[T],
+	receiver message.Receiver[T],
+	opts ...Option[T],
+) (ret *Lexer[T], user []byte, err error) {
+	var cp checkpoint[T]
+	if err = gob.NewDecoder(bytes.NewReader(data)).Decode(&cp); err != nil {
+		err = fmt.Errorf("%w: %w", ErrInvalidCheckpoint, err)
+		return
+	}
+	if cp.Version != checkpointVersion {
+		err = fmt.Errorf("%w: unsupported version %d", ErrInvalidCheckpoint, cp.Version)
+		return
+	}
+	enc, ok := xio.LookupEncoding(cp.Encoding)
+	if !ok {
+		err = fmt.Errorf("%w: %s", ErrUnknownEncoding, cp.Encoding)
+		return
+	}
+	if _, err = reader.Seek(cp.Offset, io.SeekStart); err != nil {
+		return
+	}
+	restored := []Option[T]{
+		WithEncoding[T](enc),
+		WithHistoryDepth[T](cp.HistoryDepth),
+		WithStartOffset[T](cp.Offset, cp.Column),
+	}
+	ret = New(logger, reader, factory, receiver, append(restored, opts...)...)
+	if history, ok := ret.history.(*message.RememberImpl[T]); ok {
+		messages := make([]*message.Message[T], 0, len(cp.History))
+		for _, cm := range cp.History {
+			messages = append(messages, cm.message())
+		}
+		history.Restore(messages)
+	}
+	// the states are built by the provider set later, so they are restored by the first run
+	ret.states = cp.States
+	user = cp.User
+	return
+}
@@ -0,0 +1,218 @@
+package lexer_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer_Checkpoint(t *testing.T) {
+	errPreempted := errors.New("preempted")
+	input := "abc \"def\" ghi jkl"
+
+	var (
+		l          *lexer.Lexer[Token]
+		checkpoint []byte
+	)
+	receiver := message.Slice[Token]()
+	hook := func(context.Context) (err error) {
+		if len(receiver.Slice) < 2 {
+			return
+		}
+		if checkpoint, err = l.Checkpoint([]byte("user data")); err != nil {
+			return
+		}
+		err = errPreempted
+		return
+	}
+	l = lexer.New(
+		logger.New(),
+		strings.NewReader(input),
+		message.DefaultFactory[Token](),
+		receiver,
+		lexer.WithHistoryDepth[Token](2),
+		lexer.WithCommitHook[Token](hook),
+	).With(limitedGrammar)
+	assert.ErrorIs(t, l.Run(context.Background()), errPreempted)
+	assert.Len(t, receiver.Slice, 2)
+
+	// the first commit after the restore is the omitted space, the history is as restored
+	var history []string
+	restoredHook := func(ctx context.Context) (err error) {
+		if history != nil {
+			return
+		}
+		provider, ok := state.GetHistoryProvider[Token](ctx)
+		if assert.True(t, ok) {
+			history = []string{}
+			for _, msg := range provider.Get() {
+				history = append(history, msg.AsString())
+			}
+		}
+		return
+	}
+	restoredReceiver := message.Slice[Token]()
+	restored, user, err := lexer.Restore(
+		logger.New(),
+		strings.NewReader(input),
+		checkpoint,
+		message.DefaultFactory[Token](),
+		restoredReceiver,
+		lexer.WithCommitHook[Token](restoredHook),
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "user data", string(user))
+	assert.ErrorIs(t, restored.With(limitedGrammar).Run(context.Background()), io.EOF)
+	assert.Equal(t, []string{"abc", "\"def\""}, history)
+	if assert.Len(t, restoredReceiver.Slice, 2) {
+		assert.Equal(t, "ghi", restoredReceiver.Slice[0].AsString())
+		assert.Equal(t, 10, restoredReceiver.Slice[0].Pos)
+		assert.Equal(t, "jkl", restoredReceiver.Slice[1].AsString())
+		assert.Equal(t, 14, restoredReceiver.Slice[1].Pos)
+	}
+}
+
+func TestRestore_InvalidCheckpoint(t *testing.T) {
+	_, _, err := lexer.Restore(
+		logger.New(),
+		strings.NewReader(""),
+		[]byte("garbage"),
+		message.DefaultFactory[Token](),
+		message.Slice[Token](),
+	)
+	assert.ErrorIs(t, err, lexer.ErrInvalidCheckpoint)
+}
+
+func TestLexer_CheckpointColumn(t *testing.T) {
+	errPreempted := errors.New("preempted")
+	input := "a:b:\n"
+
+	var (
+		l          *lexer.Lexer[Token]
+		checkpoint []byte
+	)
+	receiver := message.Slice[Token]()
+	hook := func(context.Context) (err error) {
+		if checkpoint, err = l.Checkpoint(nil); err != nil {
+			return
+		}
+		err = errPreempted
+		return
+	}
+	l = lexer.New(
+		logger.New(),
+		strings.NewReader(input),
+		message.DefaultFactory[Token](),
+		receiver,
+		lexer.WithCommitHook[Token](hook),
+	).With(makefileGrammar)
+	assert.ErrorIs(t, l.Run(context.Background()), errPreempted)
+	if assert.Len(t, receiver.Slice, 1) {
+		assert.Equal(t, Div, receiver.Slice[0].Token)
+	}
+
+	// the restored lexer is not at the line start
+	restoredReceiver := message.Slice[Token]()
+	restored, _, err := lexer.Restore(
+		logger.New(),
+		strings.NewReader(input),
+		checkpoint,
+		message.DefaultFactory[Token](),
+		restoredReceiver,
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Error(t, restored.With(makefileGrammar).Run(context.Background()))
+	if assert.NotEmpty(t, restoredReceiver.Slice) {
+		assert.Equal(t, Identifier, restoredReceiver.Slice[0].Token)
+		assert.Equal(t, "b", restoredReceiver.Slice[0].AsString())
+	}
+}
+
+func TestLexer_CheckpointLayout(t *testing.T) {
+	errPreempted := errors.New("preempted")
+	input := "a\n  b\n    c\nd\n"
+	grammar := func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Layout").Layout(state.LayoutRules[Token]{Newline: Comma, Indent: Bra, Dedent: Ket}, b,
+				func(b state.Builder[Token]) []state.Update[Token] {
+					return state.AsSlice[state.Update[Token]](
+						b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+					)
+				}),
+		)
+	}
+	tokens := func(msgs []*message.Message[Token]) (ret []Token) {
+		for _, msg := range msgs {
+			ret = append(ret, msg.Token)
+		}
+		return
+	}
+
+	want := message.Slice[Token]()
+	assert.ErrorIs(t, lexer.New(logger.New(), strings.NewReader(input), message.DefaultFactory[Token](), want).
+		With(grammar).
+		Run(context.Background()), io.EOF)
+
+	var (
+		l          *lexer.Lexer[Token]
+		checkpoint []byte
+	)
+	receiver := message.Slice[Token]()
+	hook := func(context.Context) (err error) {
+		// two blocks are open after the line "c"
+		if len(receiver.Slice) < 8 {
+			return
+		}
+		if checkpoint, err = l.Checkpoint(nil); err != nil {
+			return
+		}
+		err = errPreempted
+		return
+	}
+	l = lexer.New(
+		logger.New(),
+		strings.NewReader(input),
+		message.DefaultFactory[Token](),
+		receiver,
+		lexer.WithCommitHook[Token](hook),
+	).With(grammar)
+	assert.ErrorIs(t, l.Run(context.Background()), errPreempted)
+
+	restored, _, err := lexer.Restore(
+		logger.New(),
+		strings.NewReader(input),
+		checkpoint,
+		message.DefaultFactory[Token](),
+		receiver,
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.ErrorIs(t, restored.With(grammar).Run(context.Background()), io.EOF)
+	// the restored lexer closes the blocks opened before the checkpoint
+	assert.Equal(t, tokens(want.Slice), tokens(receiver.Slice))
+
+	// the restored states must match the grammar
+	mismatched, _, err := lexer.Restore(
+		logger.New(),
+		strings.NewReader(input),
+		checkpoint,
+		message.DefaultFactory[Token](),
+		message.Slice[Token](),
+	)
+	if assert.NoError(t, err) {
+		assert.ErrorIs(t, mismatched.With(limitedGrammar).Run(context.Background()), lexer.ErrInvalidCheckpoint)
+	}
+}
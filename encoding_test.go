@@ -0,0 +1,91 @@
+package lexer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func encodedWordsGrammar(b state.Builder[Token]) []state.Update[Token] {
+	return state.AsSlice[state.Update[Token]](
+		b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+		b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+	)
+}
+
+func TestLexer_Encoding(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	type testCase struct {
+		name         string
+		input        []byte
+		opts         []lexer.Option[Token]
+		wantMessages []*message.Message[Token]
+	}
+
+	tests := []testCase{
+		{
+			name:  "utf-16le with bom, raw values",
+			input: []byte{0xFF, 0xFE, 'h', 0x00, 0xE9, 0x00, ' ', 0x00, 'x', 0x00},
+			opts:  []lexer.Option[Token]{lexer.WithBOMDetection[Token]()},
+			wantMessages: []*message.Message[Token]{
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte{'h', 0x00, 0xE9, 0x00}, Pos: 2, Width: 4},
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte{'x', 0x00}, Pos: 8, Width: 2},
+			},
+		},
+		{
+			name:  "utf-16le with bom, utf-8 values",
+			input: []byte{0xFF, 0xFE, 'h', 0x00, 0xE9, 0x00, ' ', 0x00, 'x', 0x00},
+			opts:  []lexer.Option[Token]{lexer.WithBOMDetection[Token](), lexer.WithUTF8Values[Token]()},
+			wantMessages: []*message.Message[Token]{
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte("hé"), Pos: 2, Width: 4},
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte("x"), Pos: 8, Width: 2},
+			},
+		},
+		{
+			name:  "latin-1, utf-8 values",
+			input: []byte{'c', 'a', 'f', 0xE9},
+			opts:  []lexer.Option[Token]{lexer.WithEncoding[Token](xio.Latin1), lexer.WithUTF8Values[Token]()},
+			wantMessages: []*message.Message[Token]{
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte("café"), Pos: 0, Width: 4},
+			},
+		},
+		{
+			name:  "utf-8 bom",
+			input: []byte{0xEF, 0xBB, 0xBF, 'a', 'b'},
+			opts:  []lexer.Option[Token]{lexer.WithBOMDetection[Token]()},
+			wantMessages: []*message.Message[Token]{
+				{Level: 0, Type: message.Token, Token: Identifier, Value: []byte("ab"), Pos: 3, Width: 2},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			l := lexer.New(
+				logger,
+				bytes.NewBuffer(tc.input),
+				message.DefaultFactory[Token](),
+				receiver,
+				tc.opts...,
+			).With(encodedWordsGrammar)
+			err := l.Run(context.Background())
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, tc.wantMessages, receiver.Slice)
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math"
 
 	"github.com/diakovliev/lexer/examples/calculator/number"
+	"github.com/diakovliev/lexer/examples/calculator/number/parse"
 	"github.com/diakovliev/lexer/state"
 	"github.com/diakovliev/lexer/xio"
 )
@@ -103,7 +104,7 @@ func numberState(
 		}
 		state = state.RuneCheck(firstDigit).
 			State(b, numberSubState(withFraction, numberBody, maxBodyLen, maxFractionLen, errInvalidNumber)).Optional().
-			Emit(token)
+			EmitAs(token, parse.ParseNumber)
 		return
 	}
 }
@@ -1,3 +1,6 @@
+// Package number implements the calculator numbers. The fractions are allowed in every
+// base without an exponent, e.g. 0b1.01 or 0x1.8, which the state.NumberLiteral rules
+// like GoNumber don't accept, so the calculator keeps its own digits and DetectBase.
 package number
 
 import (
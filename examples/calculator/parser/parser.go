@@ -7,7 +7,6 @@ import (
 	"io"
 
 	"github.com/diakovliev/lexer/examples/calculator/grammar"
-	"github.com/diakovliev/lexer/examples/calculator/number/parse"
 	"github.com/diakovliev/lexer/examples/calculator/vm"
 	"github.com/diakovliev/lexer/message"
 )
@@ -56,6 +55,15 @@ func isNumber(token Token) bool {
 	}
 }
 
+// numberValue returns the value of the number parsed by the lexer, the number with the
+// radix point is float64, otherwise it is int64.
+func numberValue(token Token) (value any) {
+	if i, ok := message.ValueOf[int64](token); ok {
+		return i
+	}
+	return token.AsFloat64()
+}
+
 func New() *Parser {
 	return &Parser{
 		code: make([]vm.Cell, 0),
@@ -70,12 +78,8 @@ func (p *Parser) Receive(msgs []*message.Message[grammar.Token]) (err error) {
 		}
 		switch {
 		case isNumber(token):
-			number, parseErr := parse.ParseNumber(token.AsBytes())
-			if parseErr != nil {
-				err = parseErr
-				return
-			}
-			p.code = append(p.code, vm.Cell{Op: vm.Val, Value: number})
+			// the number is parsed by the lexer
+			p.code = append(p.code, vm.Cell{Op: vm.Val, Value: numberValue(token)})
 			continue
 		default:
 			op, ok := mapOp[token.Token]
@@ -0,0 +1,79 @@
+package lexer_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+// chanReceiver sends the received values to the channel.
+type chanReceiver chan string
+
+func (r chanReceiver) Receive(msgs []*message.Message[Token]) error {
+	for _, msg := range msgs {
+		r <- msg.AsString()
+	}
+	return nil
+}
+
+func TestLexer_Follow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	assert.NoError(t, os.WriteFile(path, []byte("abc de"), 0o644))
+	appendFile := func(data string) {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if assert.NoError(t, err) {
+			_, err = file.WriteString(data)
+			assert.NoError(t, err)
+			assert.NoError(t, file.Close())
+		}
+	}
+
+	values := make(chanReceiver, 16)
+	notify := make(chan struct{}, 1)
+	follower := xio.Follow(path, xio.WithPollInterval(time.Hour), xio.WithNotify(notify))
+	l := lexer.NewPush(logger.New(), message.DefaultFactory[Token](), values).With(limitedGrammar)
+	done := make(chan error)
+	go func() {
+		done <- l.Follow(context.Background(), follower)
+	}()
+	next := func() (ret string) {
+		select {
+		case ret = <-values:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+		return
+	}
+
+	assert.Equal(t, "abc", next())
+	appendFile("f g")
+	notify <- struct{}{}
+	assert.Equal(t, "def", next())
+	// truncate, the incomplete "g" is dropped
+	assert.NoError(t, os.WriteFile(path, []byte("xy "), 0o644))
+	notify <- struct{}{}
+	assert.Equal(t, "xy", next())
+	appendFile("zz")
+	follower.Close()
+	assert.Equal(t, "zz", next())
+	assert.ErrorIs(t, <-done, io.EOF)
+	assert.Empty(t, values)
+}
+
+func TestLexer_FollowCancel(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.log")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	l := lexer.NewPush(logger.New(), message.DefaultFactory[Token](), message.Slice[Token]()).With(limitedGrammar)
+	err := l.Follow(ctx, xio.Follow(path, xio.WithPollInterval(time.Millisecond)))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
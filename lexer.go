@@ -2,6 +2,8 @@ package lexer
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 
 	"github.com/diakovliev/lexer/common"
@@ -19,10 +21,31 @@ type (
 		provider     state.Provider[T]
 		historyDepth int
 		history      message.History[T]
+		sourceOpts   []xio.Option
+		input        []byte
+		inputString  string
+		inMemory     bool
+		fromString   bool
+		push         *xio.Xio
+		pushMode     bool
+		commitHook   func(ctx context.Context) error
+		onEOF        func(ctx context.Context, emit func(token T) error) error
+		// closed is true if the push mode lexer input is closed, eofDone is true
+		// if the OnEOF hook is already called for the current input
+		closed     bool
+		eofDone    bool
+		utf8Values bool
+		factory    message.Factory[T]
+		receiver   message.Receiver[T]
+		// run is the state machine built by the provider, it is reused by the runs
+		run *state.Run[T]
+		// states is the restored data of the states, it is passed to the first built run
+		states [][]byte
 	}
 )
 
-// New creates a new lexer instance with the given reader and logger.
+// New creates a new lexer instance with the given reader and logger. The reader must be
+// nil if the input is set by WithBytes or WithString option.
 func New[T any](
 	logger common.Logger,
 	reader io.Reader,
@@ -32,14 +55,29 @@ func New[T any](
 ) (ret *Lexer[T]) {
 	ret = &Lexer[T]{
 		logger:       logger,
-		source:       xio.New(logger, reader),
 		historyDepth: 0,
+		factory:      factory,
+		receiver:     receiver,
 	}
 	for _, opt := range opts {
 		opt(ret)
 	}
+	switch {
+	case ret.pushMode:
+		ret.push = xio.NewPush(logger, ret.sourceOpts...)
+		ret.source = ret.push
+	case ret.fromString:
+		common.AssertNil(reader, "reader is set for in-memory input")
+		ret.source = xio.NewString(logger, ret.inputString, ret.sourceOpts...)
+	case ret.inMemory:
+		common.AssertNil(reader, "reader is set for in-memory input")
+		ret.source = xio.NewBytes(logger, ret.input, ret.sourceOpts...)
+	default:
+		ret.source = xio.New(logger, reader, ret.sourceOpts...)
+	}
 	if ret.historyDepth > 0 {
 		ret.history = message.Remember(receiver, ret.historyDepth)
+		ret.receiver = ret.history
 		ret.builder = state.Make(
 			logger,
 			factory,
@@ -55,10 +93,23 @@ func New[T any](
 	return ret
 }
 
+// NewPush creates a new push mode lexer. The input is passed by Feed calls and the tokens
+// are emitted as soon as they are complete. Close must be called after the last chunk.
+func NewPush[T any](
+	logger common.Logger,
+	factory message.Factory[T],
+	receiver message.Receiver[T],
+	opts ...Option[T],
+) (ret *Lexer[T]) {
+	ret = New(logger, nil, factory, receiver, append(opts, withPushMode[T]())...)
+	return
+}
+
 // With adds a new states produced by given provider to the lexer.
 func (l *Lexer[T]) With(fn state.Provider[T]) *Lexer[T] {
 	common.AssertNotNil(fn, "state provider is nil")
 	l.provider = fn
+	l.run = nil
 	return l
 }
 
@@ -68,7 +119,137 @@ func (l *Lexer[T]) Run(ctx context.Context) (err error) {
 	if l.history != nil {
 		ctx = state.WithHistoryProvider(ctx, l.history)
 	}
-	err = state.NewRun(l.logger, l.builder, l.provider, io.EOF).
-		Run(ctx, l.source)
+	if l.utf8Values {
+		ctx = state.WithUTF8Values(ctx)
+	}
+	if l.run == nil {
+		l.run = state.NewRun(l.logger, l.builder, l.provider, io.EOF).
+			WithCommitHook(l.commitHook)
+		if l.states != nil {
+			if err = l.run.Restore(l.states); err != nil {
+				l.run = nil
+				err = fmt.Errorf("%w: %w", ErrInvalidCheckpoint, err)
+				return
+			}
+			l.states = nil
+		}
+	}
+	l.run.Reset()
+	err = l.run.Run(ctx, l.source)
+	switch {
+	case errors.Is(err, xio.ErrLookaheadExceeded):
+		err = l.lookaheadExceeded(ctx, err)
+	case errors.Is(err, io.EOF) && (l.push == nil || l.closed):
+		err = l.endOfInput(ctx)
+	}
+	return
+}
+
+// endOfInput calls the OnEOF hook once per input. It returns io.EOF if the hook succeeds.
+func (l *Lexer[T]) endOfInput(ctx context.Context) (err error) {
+	if l.onEOF == nil || l.eofDone {
+		err = io.EOF
+		return
+	}
+	l.eofDone = true
+	_, pos, err := l.source.Buffer()
+	common.AssertNoError(err, "get buffer error")
+	emit := func(token T) (emitErr error) {
+		msg, emitErr := l.factory.Token(ctx, 0, token, []byte{}, int(pos), 0)
+		if emitErr != nil {
+			return
+		}
+		emitErr = l.receiver.Receive([]*message.Message[T]{msg})
+		return
+	}
+	if err = l.onEOF(ctx, emit); err != nil {
+		return
+	}
+	err = io.EOF
+	return
+}
+
+// Reset makes the lexer read the input from the given reader. The states built by the
+// provider and the input buffer are reused, the history is cleared. It allows to lex
+// many inputs by one lexer instance without rebuilding the grammar.
+func (l *Lexer[T]) Reset(reader io.Reader) {
+	common.AssertTrue(l.push == nil, "push mode lexer can't be reset")
+	common.AssertFalse(l.inMemory, "in-memory lexer can't be reset")
+	xio.AsReset(l.source).Reset(reader)
+	l.eofDone = false
+	if history, ok := l.history.(*message.RememberImpl[T]); ok {
+		history.Restore(nil)
+	}
+}
+
+// Feed passes the next input chunk to the push mode lexer and emits all tokens completed by
+// it. The token which needs more data is rolled back and lexed again by the next call.
+func (l *Lexer[T]) Feed(ctx context.Context, data []byte) (err error) {
+	common.AssertNotNilPtr(l.push, "not a push mode lexer")
+	l.push.Feed(data)
+	err = l.Run(ctx)
+	if errors.Is(err, xio.ErrNeedMore) || errors.Is(err, io.EOF) {
+		// wait for the next chunk
+		err = nil
+	}
+	return
+}
+
+// Close marks the end of the input of the push mode lexer and lexes the rest of it.
+// Like Run, it returns io.EOF if all input is processed.
+func (l *Lexer[T]) Close(ctx context.Context) (err error) {
+	common.AssertNotNilPtr(l.push, "not a push mode lexer")
+	l.push.Close()
+	l.closed = true
+	err = l.Run(ctx)
+	return
+}
+
+// Follow feeds the push mode lexer with the data of the followed file until the follower
+// is closed or the context is done. The token at the end of the file is not emitted until
+// it is completed by the next data or the follower is closed. If the file is truncated or
+// rotated, the incomplete token is dropped and the lexer restarts at the new beginning.
+func (l *Lexer[T]) Follow(ctx context.Context, follower *xio.Follower) (err error) {
+	common.AssertNotNilPtr(l.push, "not a push mode lexer")
+	for {
+		data, restart, nextErr := follower.Next(ctx)
+		if errors.Is(nextErr, io.EOF) {
+			err = l.Close(ctx)
+			return
+		}
+		if nextErr != nil {
+			err = nextErr
+			return
+		}
+		if restart {
+			l.restart()
+		}
+		if err = l.Feed(ctx, data); err != nil {
+			return
+		}
+	}
+}
+
+// restart drops the not processed input of the push mode lexer and starts from scratch.
+func (l *Lexer[T]) restart() {
+	l.push = xio.NewPush(l.logger, l.sourceOpts...)
+	l.source = l.push
+	l.closed = false
+	l.eofDone = false
+}
+
+// lookaheadExceeded reports the exceeded lookahead as an error message at the last
+// committed position.
+func (l *Lexer[T]) lookaheadExceeded(ctx context.Context, lookaheadErr error) (err error) {
+	_, pos, err := l.source.Buffer()
+	common.AssertNoError(err, "get buffer error")
+	msg, err := l.factory.Error(ctx, 0, lookaheadErr, nil, int(pos), 0)
+	if err != nil {
+		return
+	}
+	if err = l.receiver.Receive([]*message.Message[T]{msg}); err != nil {
+		return
+	}
+	err = lookaheadErr
 	return
 }
@@ -0,0 +1,94 @@
+package lexer_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func limitedGrammar(b state.Builder[Token]) []state.Update[Token] {
+	return state.AsSlice[state.Update[Token]](
+		b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+		b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+		b.Named("String").Rune('"').UntilRune(state.IsRune('"')).Rune('"').Emit(String),
+	)
+}
+
+func TestLexer_Limits(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	type testCase struct {
+		name      string
+		input     string
+		opts      []lexer.Option[Token]
+		wantCount int
+		wantPos   int
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:      "small tokens in large input",
+			input:     strings.Repeat("abc \"def\" ", 1000),
+			opts:      []lexer.Option[Token]{lexer.WithMaxLookahead[Token](16), lexer.WithMaxBuffered[Token](32)},
+			wantCount: 2000,
+			wantError: io.EOF,
+		},
+		{
+			name:      "unterminated string exceeds lookahead",
+			input:     "abc \"" + strings.Repeat("x", 100),
+			opts:      []lexer.Option[Token]{lexer.WithMaxLookahead[Token](16)},
+			wantCount: 1,
+			wantPos:   4,
+			wantError: xio.ErrLookaheadExceeded,
+		},
+		{
+			name:      "long token exceeds buffer",
+			input:     "abc " + strings.Repeat("x", 100),
+			opts:      []lexer.Option[Token]{lexer.WithMaxBuffered[Token](32)},
+			wantCount: 1,
+			wantPos:   4,
+			wantError: xio.ErrLookaheadExceeded,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			l := lexer.New(
+				logger,
+				bytes.NewBufferString(tc.input),
+				message.DefaultFactory[Token](),
+				receiver,
+				tc.opts...,
+			).With(limitedGrammar)
+			err := l.Run(context.Background())
+			assert.ErrorIs(t, err, tc.wantError)
+			errs := message.GetUserErrors(receiver.Slice)
+			if tc.wantError == io.EOF {
+				assert.Empty(t, errs)
+				assert.Len(t, receiver.Slice, tc.wantCount)
+				return
+			}
+			assert.Len(t, receiver.Slice, tc.wantCount+1)
+			if assert.Len(t, errs, 1) {
+				assert.ErrorIs(t, errs[0].AsError(), xio.ErrLookaheadExceeded)
+				assert.Equal(t, tc.wantPos, errs[0].Pos)
+			}
+		})
+	}
+}
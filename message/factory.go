@@ -11,6 +11,12 @@ type (
 		Error(ctx context.Context, level int, err error, buffer []byte, pos int, width int) (*Message[T], error)
 	}
 
+	// WarningFactory is an optional Factory extension which creates warning messages.
+	WarningFactory[T any] interface {
+		// Warning creates a warning message
+		Warning(ctx context.Context, level int, err error, buffer []byte, pos int, width int) (*Message[T], error)
+	}
+
 	// DefaultFactoryImpl is the default message factory implementation
 	DefaultFactoryImpl[T any] struct{}
 )
@@ -45,3 +51,28 @@ func (f DefaultFactoryImpl[T]) Error(ctx context.Context, level int, userErr err
 	msg.Value = errorValue
 	return
 }
+
+// Warning implements WarningFactory interface.
+func (f DefaultFactoryImpl[T]) Warning(ctx context.Context, level int, userErr error, buffer []byte, pos int, width int) (msg *Message[T], err error) {
+	msg, err = f.Error(ctx, level, userErr, buffer, pos, width)
+	if err != nil {
+		return
+	}
+	msg.Type = Warning
+	return
+}
+
+// MakeWarning creates a warning message by the factory. If the factory doesn't implement
+// WarningFactory, the message is created by Error and its type is changed to Warning.
+func MakeWarning[T any](ctx context.Context, f Factory[T], level int, userErr error, buffer []byte, pos int, width int) (msg *Message[T], err error) {
+	if wf, ok := f.(WarningFactory[T]); ok {
+		msg, err = wf.Warning(ctx, level, userErr, buffer, pos, width)
+		return
+	}
+	msg, err = f.Error(ctx, level, userErr, buffer, pos, width)
+	if err != nil {
+		return
+	}
+	msg.Type = Warning
+	return
+}
@@ -45,3 +45,12 @@ func (h *RememberImpl[T]) Receive(m []*Message[T]) (err error) {
 func (h *RememberImpl[T]) Get() []*Message[T] {
 	return h.messages
 }
+
+// Restore replaces the remembered messages, it is used to restore the history
+// saved before. Only the last keepCount messages are kept.
+func (h *RememberImpl[T]) Restore(m []*Message[T]) {
+	h.messages = append(h.messages[:0], m...)
+	if len(h.messages) > h.keepCount {
+		h.messages = h.messages[len(h.messages)-h.keepCount:]
+	}
+}
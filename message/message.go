@@ -6,7 +6,7 @@ import (
 	"github.com/diakovliev/lexer/common"
 )
 
-// Type represents the type of a message. The types are: Error, Token.
+// Type represents the type of a message. The types are: Error, Token, Warning.
 type Type int
 
 const (
@@ -14,6 +14,8 @@ const (
 	Error Type = iota
 	// Token represents a token
 	Token
+	// Warning represents a warning, unlike Error it does not stop the lexer
+	Warning
 )
 
 // String implements fmt.Stringer interface.
@@ -23,6 +25,8 @@ func (t Type) String() string {
 		return "Error"
 	case Token:
 		return "Token"
+	case Warning:
+		return "Warning"
 	default:
 		panic("unknown message type")
 	}
@@ -88,6 +92,12 @@ func (m Message[TokenType]) String() (ret string) {
 		} else {
 			ret = fmt.Sprintf("Error(%v, %d, %d)", m.Value, m.Pos, m.Width)
 		}
+	case Warning:
+		if err, ok := m.Value.(*ErrorValue); ok {
+			ret = fmt.Sprintf("Warning(%s, %d, %d)", err, m.Pos, m.Width)
+		} else {
+			ret = fmt.Sprintf("Warning(%v, %d, %d)", m.Value, m.Pos, m.Width)
+		}
 	default:
 		common.AssertUnreachable("invalid message type: %d", m.Type)
 	}
@@ -103,6 +113,15 @@ func (m Message[TokenType]) AsError() (value *ErrorValue) {
 	return
 }
 
+// AsWarning returns the value of the message as an ErrorValue. It panics if the message's type is not Warning,
+// or if the Value is not an ErrorValue.
+func (m Message[TokenType]) AsWarning() (value *ErrorValue) {
+	common.AssertTrue(m.Type == Warning, "invalid message type: %s", m.Type)
+	value, ok := m.Value.(*ErrorValue)
+	common.AssertTrue(ok, "value is not an error: %v", m.Value)
+	return
+}
+
 // AsBytes returns the value of the message as a []byte. It panics if the message's type is not Token,
 // or if the Value is not []byte.
 func (m Message[TokenType]) AsBytes() (value []byte) {
@@ -127,3 +146,40 @@ func (m Message[TokenType]) AsString() (value string) {
 	common.AssertUnreachable("invalid value type: %T", m.Value)
 	return
 }
+
+// AsInt64 returns the value of the message as an int64, e.g. converted by the state.ToInt64.
+// It panics if the message's type is not Token, or if the Value is not int64.
+func (m Message[TokenType]) AsInt64() (value int64) {
+	common.AssertTrue(m.Type == Token, "invalid message type: %s", m.Type)
+	value, ok := m.Value.(int64)
+	common.AssertTrue(ok, "value is not int64: %v", m.Value)
+	return
+}
+
+// AsUint64 returns the value of the message as an uint64, e.g. converted by the state.ToUint64.
+// It panics if the message's type is not Token, or if the Value is not uint64.
+func (m Message[TokenType]) AsUint64() (value uint64) {
+	common.AssertTrue(m.Type == Token, "invalid message type: %s", m.Type)
+	value, ok := m.Value.(uint64)
+	common.AssertTrue(ok, "value is not uint64: %v", m.Value)
+	return
+}
+
+// AsFloat64 returns the value of the message as a float64, e.g. converted by the state.ToFloat64.
+// It panics if the message's type is not Token, or if the Value is not float64.
+func (m Message[TokenType]) AsFloat64() (value float64) {
+	common.AssertTrue(m.Type == Token, "invalid message type: %s", m.Type)
+	value, ok := m.Value.(float64)
+	common.AssertTrue(ok, "value is not float64: %v", m.Value)
+	return
+}
+
+// ValueOf returns the value of the token message as V. It returns false if the message's
+// type is not Token, or if the Value is not V.
+func ValueOf[V any, TokenType any](m *Message[TokenType]) (value V, ok bool) {
+	if m.Type != Token {
+		return
+	}
+	value, ok = m.Value.(V)
+	return
+}
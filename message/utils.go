@@ -18,3 +18,13 @@ func GetUserErrors[T any](slice []*Message[T]) (errs []*Message[T]) {
 	}
 	return
 }
+
+// GetUserWarnings returns all warnings messages from the messages slice.
+func GetUserWarnings[T any](slice []*Message[T]) (warnings []*Message[T]) {
+	for _, m := range slice {
+		if m.Type == Warning {
+			warnings = append(warnings, m)
+		}
+	}
+	return
+}
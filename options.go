@@ -1,5 +1,11 @@
 package lexer
 
+import (
+	"context"
+
+	"github.com/diakovliev/lexer/xio"
+)
+
 // Option is a function that modifies the lexer's behavior.
 type Option[T any] func(*Lexer[T])
 
@@ -9,3 +15,95 @@ func WithHistoryDepth[T any](depth int) Option[T] {
 		l.historyDepth = depth
 	}
 }
+
+// WithEncoding sets the input encoding. The default encoding is UTF-8.
+func WithEncoding[T any](enc xio.Encoding) Option[T] {
+	return func(l *Lexer[T]) {
+		l.sourceOpts = append(l.sourceOpts, xio.WithEncoding(enc))
+	}
+}
+
+// WithBOMDetection enables the input encoding detection by the byte order mark.
+func WithBOMDetection[T any]() Option[T] {
+	return func(l *Lexer[T]) {
+		l.sourceOpts = append(l.sourceOpts, xio.WithBOMDetection())
+	}
+}
+
+// WithUTF8Values enables conversion of the token values from the input encoding to UTF-8.
+// Message positions and widths are reported in the original input bytes.
+func WithUTF8Values[T any]() Option[T] {
+	return func(l *Lexer[T]) {
+		l.utf8Values = true
+	}
+}
+
+// WithMaxBuffered sets the maximum count of the input bytes kept in memory. If the grammar
+// requires more, the lexer reports xio.ErrLookaheadExceeded as an error message and stops.
+func WithMaxBuffered[T any](size int64) Option[T] {
+	return func(l *Lexer[T]) {
+		l.sourceOpts = append(l.sourceOpts, xio.WithMaxBuffered(size))
+	}
+}
+
+// WithMaxLookahead sets the maximum count of the input bytes a token can span. If the grammar
+// reads further, the lexer reports xio.ErrLookaheadExceeded as an error message and stops.
+func WithMaxLookahead[T any](size int64) Option[T] {
+	return func(l *Lexer[T]) {
+		l.sourceOpts = append(l.sourceOpts, xio.WithMaxLookahead(size))
+	}
+}
+
+// WithBytes sets the in-memory input. The input is not copied and the token values are
+// sub-slices of it, so it must not be modified while the lexer or the values are in use.
+// Use it with the xio.MappedFile data to lex memory mapped files.
+func WithBytes[T any](data []byte) Option[T] {
+	return func(l *Lexer[T]) {
+		l.input = data
+		l.inMemory = true
+	}
+}
+
+// WithString sets the in-memory input without copying the string. The string memory is
+// read only, so unlike WithBytes the token values are copies.
+func WithString[T any](s string) Option[T] {
+	return func(l *Lexer[T]) {
+		l.inputString = s
+		l.inMemory = true
+		l.fromString = true
+	}
+}
+
+// withPushMode makes the lexer read the input passed by Feed calls.
+func withPushMode[T any]() Option[T] {
+	return func(l *Lexer[T]) {
+		l.pushMode = true
+	}
+}
+
+// WithCommitHook sets the function called after each top level commit, when the lexer
+// progress can be saved by Checkpoint. If the hook returns an error, the lexer stops
+// with this error.
+func WithCommitHook[T any](hook func(ctx context.Context) error) Option[T] {
+	return func(l *Lexer[T]) {
+		l.commitHook = hook
+	}
+}
+
+// WithOnEOF sets the function called once when the whole input is lexed. The emit function
+// sends the zero width token at the end of input, like the explicit EOF token or the dedents
+// closing the open blocks. If the hook returns an error, the lexer stops with this error.
+func WithOnEOF[T any](hook func(ctx context.Context, emit func(token T) error) error) Option[T] {
+	return func(l *Lexer[T]) {
+		l.onEOF = hook
+	}
+}
+
+// WithStartOffset makes the lexer read the input from the given offset, the reader must be
+// positioned at it. The message positions are reported from the beginning of the input and
+// the column of the offset is given to keep the line anchors working.
+func WithStartOffset[T any](offset int64, column int) Option[T] {
+	return func(l *Lexer[T]) {
+		l.sourceOpts = append(l.sourceOpts, xio.WithStartOffset(offset), xio.WithStartColumn(column))
+	}
+}
@@ -0,0 +1,47 @@
+package parallel
+
+import (
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+)
+
+// Option is an option that can be passed to the parallel lexer constructor.
+type Option[T any] func(*Lexer[T])
+
+// WithLogger sets the logger to use for logging. If not set, no logging is done.
+func WithLogger[T any](logger common.Logger) Option[T] {
+	return func(l *Lexer[T]) {
+		l.logger = logger
+	}
+}
+
+// WithFactory sets the message factory. The default is message.DefaultFactory.
+func WithFactory[T any](factory message.Factory[T]) Option[T] {
+	return func(l *Lexer[T]) {
+		l.factory = factory
+	}
+}
+
+// WithWorkers sets the maximum count of the chunks lexed concurrently. The default
+// is runtime.GOMAXPROCS(0).
+func WithWorkers[T any](workers int) Option[T] {
+	return func(l *Lexer[T]) {
+		l.workers = workers
+	}
+}
+
+// WithChunkSize sets the minimum size of the chunk. The chunk is extended up to the
+// next safe boundary. The default is DefaultChunkSize.
+func WithChunkSize[T any](size int64) Option[T] {
+	return func(l *Lexer[T]) {
+		l.chunkSize = size
+	}
+}
+
+// WithLexerOptions sets the options of the lexers created for the chunks.
+func WithLexerOptions[T any](opts ...lexer.Option[T]) Option[T] {
+	return func(l *Lexer[T]) {
+		l.lexerOpts = opts
+	}
+}
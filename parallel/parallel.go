@@ -0,0 +1,206 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"runtime"
+	"slices"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+)
+
+const (
+	// DefaultChunkSize is the default minimum size of the chunk.
+	DefaultChunkSize = 4 << 20
+	// scanSize is the size of the reads used to find the safe boundary.
+	scanSize = 64 << 10
+)
+
+type (
+	// Boundary returns true if the input can be split after the given byte, so the
+	// rest of the input can be lexed from scratch, e.g. after the newline in the
+	// newline delimited records. The chunk after the boundary starts at the column zero.
+	Boundary func(b byte) bool
+
+	// Lexer lexes a single large input in parallel. The input is split into chunks at
+	// the safe boundaries, every chunk is lexed by its own lexer instance built by the
+	// same state provider. The message positions are relative to the beginning of the
+	// input and the messages are delivered in the original order. The history of the
+	// lexer is not shared between the chunks, the OnEOF hook is called at the end of the
+	// last chunk only.
+	Lexer[T any] struct {
+		logger    common.Logger
+		reader    io.ReaderAt
+		size      int64
+		boundary  Boundary
+		factory   message.Factory[T]
+		provider  state.Provider[T]
+		workers   int
+		chunkSize int64
+		lexerOpts []lexer.Option[T]
+	}
+
+	// chunk is the part of the input lexed by one lexer.
+	chunk struct {
+		start int64
+		end   int64
+	}
+
+	// result is the outcome of the chunk lexing.
+	result[T any] struct {
+		messages []*message.Message[T]
+		err      error
+	}
+)
+
+// AfterByte returns the boundary after the given byte.
+func AfterByte(c byte) Boundary {
+	return func(b byte) bool {
+		return b == c
+	}
+}
+
+// New creates a new parallel lexer for the input of the given size.
+func New[T any](reader io.ReaderAt, size int64, boundary Boundary, opts ...Option[T]) (ret *Lexer[T]) {
+	common.AssertNotNil(boundary, "boundary is nil")
+	ret = &Lexer[T]{
+		logger:    logger.Nop(),
+		reader:    reader,
+		size:      size,
+		boundary:  boundary,
+		factory:   message.DefaultFactory[T](),
+		workers:   runtime.GOMAXPROCS(0),
+		chunkSize: DefaultChunkSize,
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	common.AssertTrue(ret.workers > 0, "invalid workers count")
+	common.AssertTrue(ret.chunkSize > 0, "invalid chunk size")
+	return
+}
+
+// With sets the state provider.
+func (l *Lexer[T]) With(fn state.Provider[T]) *Lexer[T] {
+	common.AssertNotNil(fn, "state provider is nil")
+	l.provider = fn
+	return l
+}
+
+// next returns the end of the chunk started at the given position.
+func (l *Lexer[T]) next(start int64, buf []byte) (end int64, err error) {
+	// the chunk is split after the byte at pos
+	pos := start + l.chunkSize - 1
+	for pos < l.size {
+		n, readErr := l.reader.ReadAt(buf[:min(int64(len(buf)), l.size-pos)], pos)
+		for i, b := range buf[:n] {
+			if l.boundary(b) {
+				end = pos + int64(i) + 1
+				return
+			}
+		}
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			err = readErr
+			return
+		}
+		if n == 0 {
+			break
+		}
+		pos += int64(n)
+	}
+	end = l.size
+	return
+}
+
+// split splits the input into chunks at the safe boundaries.
+func (l *Lexer[T]) split() (chunks []chunk, err error) {
+	buf := make([]byte, scanSize)
+	for start := int64(0); start < l.size; {
+		var end int64
+		if end, err = l.next(start, buf); err != nil {
+			return
+		}
+		chunks = append(chunks, chunk{start: start, end: end})
+		start = end
+	}
+	return
+}
+
+// lex lexes the chunk. The chunk lexer reads the input from the chunk start, so the message
+// positions and the input start anchors are the same as of the whole input lexer. The OnEOF
+// hook is called by the last chunk lexer only.
+func (l *Lexer[T]) lex(ctx context.Context, c chunk) (ret result[T]) {
+	receiver := message.Slice[T]()
+	opts := append(slices.Clone(l.lexerOpts), lexer.WithStartOffset[T](c.start, 0))
+	if c.end < l.size {
+		opts = append(opts, lexer.WithOnEOF[T](nil))
+	}
+	ret.err = lexer.New(
+		l.logger,
+		io.NewSectionReader(l.reader, c.start, c.end-c.start),
+		l.factory,
+		receiver,
+		opts...,
+	).With(l.provider).Run(ctx)
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(ret.err, io.EOF) {
+		// the run is interrupted
+		ret.err = ctxErr
+	}
+	ret.messages = receiver.Slice
+	return
+}
+
+// Run lexes the input and sends the messages to the receiver in the original order.
+// It stops at the first chunk which fails, the messages of the failed chunk are delivered.
+// Like lexer.Lexer.Run, it returns io.EOF if all input is processed.
+func (l *Lexer[T]) Run(ctx context.Context, receiver message.Receiver[T]) (err error) {
+	common.AssertNotNil(l.provider, "state provider is nil")
+	chunks, err := l.split()
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make([]chan result[T], len(chunks))
+	for i := range results {
+		results[i] = make(chan result[T], 1)
+	}
+	// the slot is taken by the chunk until its messages are delivered, so the count of
+	// the lexed but not delivered chunks is limited by the workers count
+	sem := make(chan struct{}, l.workers)
+	go func() {
+		for i, c := range chunks {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] <- result[T]{err: ctx.Err()}
+				continue
+			}
+			go func() {
+				results[i] <- l.lex(ctx, c)
+			}()
+		}
+	}()
+	err = io.EOF
+	for i := range chunks {
+		res := <-results[i]
+		if len(res.messages) > 0 {
+			if receiveErr := receiver.Receive(res.messages); receiveErr != nil {
+				err = receiveErr
+				return
+			}
+		}
+		if !errors.Is(res.err, io.EOF) {
+			err = res.err
+			return
+		}
+		// free the slot of the delivered chunk
+		<-sem
+	}
+	return
+}
@@ -0,0 +1,146 @@
+package parallel_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/parallel"
+	"github.com/diakovliev/lexer/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type Token int
+
+const (
+	Identifier Token = iota
+	String
+	Start
+	Line
+	End
+)
+
+func grammar(b state.Builder[Token]) []state.Update[Token] {
+	return state.AsSlice[state.Update[Token]](
+		b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+		b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+		b.Named("String").Rune('"').UntilRune(state.IsRune('"')).Rune('"').Emit(String),
+	)
+}
+
+func TestLexer_Run(t *testing.T) {
+	var buf strings.Builder
+	for i := range 100 {
+		buf.WriteString(strings.Repeat("abc", i%7+1))
+		buf.WriteString(" \"def ghi\"\n")
+	}
+	input := buf.String()
+
+	want := message.Slice[Token]()
+	err := lexer.New(logger.New(), strings.NewReader(input), message.DefaultFactory[Token](), want).
+		With(grammar).
+		Run(context.Background())
+	assert.ErrorIs(t, err, io.EOF)
+
+	got := message.Slice[Token]()
+	err = parallel.New[Token](strings.NewReader(input), int64(len(input)), parallel.AfterByte('\n'),
+		parallel.WithChunkSize[Token](64),
+		parallel.WithWorkers[Token](4),
+	).With(grammar).Run(context.Background(), got)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, want.Slice, got.Slice)
+}
+
+func TestLexer_RunAnchors(t *testing.T) {
+	anchors := func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Start").AtInputStart().Rune('a').Emit(Start),
+			b.Named("Line").AtLineStart().Rune('a').Emit(Line),
+			b.Named("Newline").Rune('\n').Omit(),
+		)
+	}
+	onEOF := func(_ context.Context, emit func(token Token) error) error {
+		return emit(End)
+	}
+	input := "a\na\na\n"
+
+	want := message.Slice[Token]()
+	err := lexer.New(logger.New(), strings.NewReader(input), message.DefaultFactory[Token](), want,
+		lexer.WithOnEOF[Token](onEOF),
+	).With(anchors).Run(context.Background())
+	assert.ErrorIs(t, err, io.EOF)
+
+	got := message.Slice[Token]()
+	err = parallel.New[Token](strings.NewReader(input), int64(len(input)), parallel.AfterByte('\n'),
+		parallel.WithChunkSize[Token](1),
+		parallel.WithLexerOptions[Token](lexer.WithOnEOF[Token](onEOF)),
+	).With(anchors).Run(context.Background(), got)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, want.Slice, got.Slice)
+	// the input start is matched by the first chunk only, the end token is emitted once
+	tokens := []Token{}
+	for _, msg := range got.Slice {
+		tokens = append(tokens, msg.Token)
+	}
+	assert.Equal(t, []Token{Start, Line, Line, End}, tokens)
+}
+
+func TestLexer_RunError(t *testing.T) {
+	input := []byte(strings.Repeat("abc\n", 50) + "+\n" + strings.Repeat("abc\n", 50))
+	receiver := message.Slice[Token]()
+	err := parallel.New[Token](bytes.NewReader(input), int64(len(input)), parallel.AfterByte('\n'),
+		parallel.WithChunkSize[Token](16),
+	).With(grammar).Run(context.Background(), receiver)
+	assert.ErrorIs(t, err, state.ErrIncomplete)
+	if assert.Len(t, receiver.Slice, 50) {
+		for i, msg := range receiver.Slice {
+			assert.Equal(t, i*4, msg.Pos)
+		}
+	}
+}
+
+// blockingReceiver holds the first delivery until it is released.
+type blockingReceiver struct {
+	release chan struct{}
+	got     []*message.Message[Token]
+}
+
+func (r *blockingReceiver) Receive(messages []*message.Message[Token]) (err error) {
+	if r.got == nil {
+		<-r.release
+	}
+	r.got = append(r.got, messages...)
+	return
+}
+
+func TestLexer_RunLimitsUndelivered(t *testing.T) {
+	input := []byte(strings.Repeat("abc\n", 20))
+	var started atomic.Int32
+	counting := func(b state.Builder[Token]) []state.Update[Token] {
+		started.Add(1)
+		return grammar(b)
+	}
+	receiver := &blockingReceiver{release: make(chan struct{})}
+	done := make(chan error)
+	go func() {
+		done <- parallel.New[Token](bytes.NewReader(input), int64(len(input)), parallel.AfterByte('\n'),
+			parallel.WithChunkSize[Token](4),
+			parallel.WithWorkers[Token](2),
+		).With(counting).Run(context.Background(), receiver)
+	}()
+	// the chunks are not lexed ahead while the first one is not delivered
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, started.Load(), int32(2))
+	close(receiver.release)
+	assert.ErrorIs(t, <-done, io.EOF)
+	assert.Len(t, receiver.got, 20)
+	assert.Equal(t, int32(20), started.Load())
+}
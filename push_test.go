@@ -0,0 +1,223 @@
+package lexer_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer_Push(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	type step struct {
+		chunk      string
+		wantValues []string
+	}
+
+	steps := []step{
+		{chunk: "ab", wantValues: []string{}},
+		{chunk: "c \"d\xc3", wantValues: []string{"abc"}},
+		{chunk: "\xa9\" x", wantValues: []string{"abc", "\"dé\""}},
+		{chunk: "yz", wantValues: []string{"abc", "\"dé\""}},
+		{chunk: "", wantValues: []string{"abc", "\"dé\""}},
+	}
+
+	receiver := message.Slice[Token]()
+	l := lexer.NewPush(logger, message.DefaultFactory[Token](), receiver).With(limitedGrammar)
+	values := func() (ret []string) {
+		ret = []string{}
+		for _, msg := range receiver.Slice {
+			ret = append(ret, msg.AsString())
+		}
+		return
+	}
+	for _, s := range steps {
+		assert.NoError(t, l.Feed(context.Background(), []byte(s.chunk)))
+		assert.Equal(t, s.wantValues, values(), "after %q", s.chunk)
+	}
+	assert.ErrorIs(t, l.Close(context.Background()), io.EOF)
+	assert.Equal(t, []string{"abc", "\"dé\"", "xyz"}, values())
+	if assert.Len(t, receiver.Slice, 3) {
+		assert.Equal(t, 10, receiver.Slice[2].Pos)
+	}
+}
+
+func TestLexer_PushUnknownInput(t *testing.T) {
+	receiver := message.Slice[Token]()
+	l := lexer.NewPush(logger.New(), message.DefaultFactory[Token](), receiver).With(limitedGrammar)
+	assert.NoError(t, l.Feed(context.Background(), []byte("abc ")))
+	assert.Error(t, l.Feed(context.Background(), []byte("+")))
+	assert.Len(t, receiver.Slice, 1)
+}
+
+func TestLexer_PushOnPullLexer(t *testing.T) {
+	l := lexer.New(logger.New(), strings.NewReader("abc"), message.DefaultFactory[Token](), message.Slice[Token]()).
+		With(limitedGrammar)
+	want := "ASSERTION FAILED: not a push mode lexer"
+	assert.PanicsWithError(t, want, func() { _ = l.Feed(context.Background(), []byte("abc")) })
+	assert.PanicsWithError(t, want, func() { _ = l.Close(context.Background()) })
+}
+
+// assertPushLikePull checks that the input fed byte by byte to the push mode lexer
+// gives the same messages as the pull mode lexer.
+func assertPushLikePull(t *testing.T, provider state.Provider[Token], input string) {
+	t.Helper()
+	want := message.Slice[Token]()
+	wantErr := lexer.New(logger.New(), strings.NewReader(input), message.DefaultFactory[Token](), want).
+		With(provider).
+		Run(context.Background())
+
+	got := message.Slice[Token]()
+	l := lexer.NewPush(logger.New(), message.DefaultFactory[Token](), got).With(provider)
+	var err error
+	for i := range len(input) {
+		if err = l.Feed(context.Background(), []byte(input[i:i+1])); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = l.Close(context.Background())
+	}
+	assert.Equal(t, wantErr == nil, err == nil, "pull error %v, push error %v", wantErr, err)
+	if wantErr != nil && err != nil {
+		assert.Equal(t, wantErr.Error(), err.Error())
+	}
+	assert.Equal(t, want.Slice, got.Slice)
+}
+
+func TestLexer_PushMedialJoiner(t *testing.T) {
+	grammar := func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+			b.Named("Identifier").Identifier(state.XIDIdentifier.WithMedialJoinControls()).Emit(Identifier),
+		)
+	}
+	assertPushLikePull(t, grammar, "ab\u200Dc d")
+}
+
+func TestLexer_PushStatefulStates(t *testing.T) {
+	type testCase struct {
+		name    string
+		grammar state.Provider[Token]
+		input   string
+	}
+
+	tokens := state.InterpolationTokens[Token]{Start: Bra, Part: String, InterpStart: Plus, InterpEnd: Minus, End: Ket}
+	var expression state.Provider[Token]
+	expression = func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+			b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+			b.Named("Braces").RuneCheck(state.Or(state.IsRune('{'), state.IsRune('}'))).Emit(Comma),
+			b.Named("Template").Interpolated(state.TemplateString, tokens, b, expression),
+		)
+	}
+	layoutRules := state.LayoutRules[Token]{
+		Newline: Comma,
+		Indent:  Bra,
+		Dedent:  Ket,
+		Open:    func(token Token) bool { return token == Mul },
+		Close:   func(token Token) bool { return token == Div },
+	}
+	line := func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Spaces").WhileRune(state.IsRune(' ')).Omit(),
+			b.Named("Identifier").Identifier(state.GoIdentifier).Emit(Identifier),
+			b.Named("Open").Rune('(').Emit(Mul),
+			b.Named("Close").Rune(')').Emit(Div),
+		)
+	}
+	item := func(b state.Builder[Token]) []state.Update[Token] {
+		return state.AsSlice[state.Update[Token]](
+			b.Named("Item").Rune('x').Emit(Identifier).Break(),
+		)
+	}
+
+	tests := []testCase{
+		{
+			name: "interpolated",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+					b.Named("Template").Interpolated(state.TemplateString, tokens, b, expression),
+				)
+			},
+			input: "`a ${ x {y} } b` `c${`d${e}`}`",
+		},
+		{
+			name: "layout",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Layout").Layout(layoutRules, b, line),
+				)
+			},
+			input: "a\n  b (c\n d)\n    e\nf\n",
+		},
+		{
+			name: "layout crlf",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Layout").Layout(layoutRules, b, line),
+				)
+			},
+			input: "a\r\n  b\r\n    c\r\nd",
+		},
+		{
+			name: "counted",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Counted").Counted(state.U8, 0, b, item).Emit(String),
+				)
+			},
+			input: "\x03xxx\x00\x02xx",
+		},
+		{
+			name: "longest",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+					b.Named("Longest").Longest(b, func(b state.Builder[Token]) []state.Update[Token] {
+						return state.AsSlice[state.Update[Token]](
+							b.String("ab").Emit(Identifier),
+							b.String("abcd").Emit(String),
+							b.Rune('a').Emit(Comma),
+						)
+					}),
+				)
+			},
+			input: "abcd ab a abcd",
+		},
+		{
+			name: "capture",
+			grammar: func(b state.Builder[Token]) []state.Update[Token] {
+				return state.AsSlice[state.Update[Token]](
+					b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+					b.Named("Heredoc").String("<<").
+						Mark("tag").Identifier(state.GoIdentifier).Capture("tag").
+						Rune('\n').
+						UntilCapture(state.UntilInclude, "tag").
+						Emit(String),
+				)
+			},
+			input: "<<END\nabc\nEND <<X\nyX",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assertPushLikePull(t, tc.grammar, tc.input)
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package lexer_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/diakovliev/lexer"
+	"github.com/diakovliev/lexer/logger"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLexer_InMemorySource(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	input := []byte(`abc "def" ghi`)
+	path := filepath.Join(t.TempDir(), "input.txt")
+	assert.NoError(t, os.WriteFile(path, input, 0o644))
+	mapped, err := xio.Mmap(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer mapped.Close()
+
+	type testCase struct {
+		name  string
+		input []byte
+		opt   lexer.Option[Token]
+	}
+
+	tests := []testCase{
+		{
+			name:  "bytes",
+			input: input,
+			opt:   lexer.WithBytes[Token](input),
+		},
+		{
+			name:  "mapped file",
+			input: mapped.Bytes(),
+			opt:   lexer.WithBytes[Token](mapped.Bytes()),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			err := lexer.New(logger, nil, message.DefaultFactory[Token](), receiver, tc.opt).
+				With(limitedGrammar).
+				Run(context.Background())
+			assert.ErrorIs(t, err, io.EOF)
+			if !assert.Len(t, receiver.Slice, 3) {
+				return
+			}
+			assert.Equal(t, []string{"abc", `"def"`, "ghi"}, []string{
+				receiver.Slice[0].AsString(),
+				receiver.Slice[1].AsString(),
+				receiver.Slice[2].AsString(),
+			})
+			for _, msg := range receiver.Slice {
+				// the values share the memory with the input
+				assert.Same(t, &tc.input[msg.Pos], &msg.AsBytes()[0])
+			}
+		})
+	}
+}
+
+func TestLexer_StringSource(t *testing.T) {
+	input := "abc def"
+	receiver := message.Slice[Token]()
+	err := lexer.New(logger.New(), nil, message.DefaultFactory[Token](), receiver, lexer.WithString[Token](input)).
+		With(limitedGrammar).
+		Run(context.Background())
+	assert.ErrorIs(t, err, io.EOF)
+	if assert.Len(t, receiver.Slice, 2) {
+		assert.Equal(t, "def", receiver.Slice[1].AsString())
+		// the string memory is read only, so the values are copies
+		assert.NotSame(t, unsafe.StringData(input[4:]), &receiver.Slice[1].AsBytes()[0])
+	}
+}
+
+func TestLexer_InMemorySourceWithReader(t *testing.T) {
+	assert.Panics(t, func() {
+		lexer.New(logger.New(), strings.NewReader("abc"), message.DefaultFactory[Token](), message.Slice[Token](),
+			lexer.WithBytes[Token]([]byte("abc")))
+	})
+	assert.Panics(t, func() {
+		lexer.New(logger.New(), strings.NewReader("abc"), message.DefaultFactory[Token](), message.Slice[Token](),
+			lexer.WithString[Token]("abc"))
+	})
+}
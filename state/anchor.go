@@ -0,0 +1,114 @@
+package state
+
+import (
+	"context"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// anchorFn checks the position of the state.
+	anchorFn func(tx xio.State) (ok bool, err error)
+
+	// Anchor is a zero width state that checks the position, like the start of the line
+	// or the end of input. It doesn't read anything.
+	Anchor[T any] struct {
+		logger common.Logger
+		check  anchorFn
+	}
+)
+
+// newAnchor creates a new instance of the Anchor state.
+func newAnchor[T any](logger common.Logger, check anchorFn) *Anchor[T] {
+	return &Anchor[T]{
+		logger: logger,
+		check:  check,
+	}
+}
+
+// Update implements the Update interface. It rolls back the chain if the position
+// doesn't match the anchor.
+func (a Anchor[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	ok, err := a.check(tx)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	err = ErrChainNext
+	return
+}
+
+// atColumn returns the anchor matching the given column.
+func atColumn(column int) anchorFn {
+	return func(tx xio.State) (ok bool, err error) {
+		ok = xio.AsPosition(tx).Column() == column
+		return
+	}
+}
+
+// atLineEnd matches the position before the line break or at the end of input.
+func atLineEnd(tx xio.State) (ok bool, err error) {
+	r, has, err := peekRune(tx)
+	ok = err == nil && (!has || r == '\n' || r == '\r')
+	return
+}
+
+// atInputStart matches the start of input, past the byte order mark if any.
+func atInputStart(tx xio.State) (ok bool, err error) {
+	ok = xio.AsOffset(tx).Offset() == xio.AsPosition(tx).Origin()
+	return
+}
+
+// atEOF matches the end of input.
+func atEOF(tx xio.State) (ok bool, err error) {
+	_, has, err := peekRune(tx)
+	ok = err == nil && !has
+	return
+}
+
+// AtLineStart adds a zero width state that matches the start of the line, like the
+// Makefile recipes or the diff hunks which are recognized only at the column 0.
+func (b Builder[T]) AtLineStart() (tail *Chain[T]) {
+	tail = b.append("AtLineStart", func() Update[T] { return newAnchor[T](b.logger, atColumn(0)) })
+	return
+}
+
+// AtLineEnd adds a zero width state that matches the position before the line break
+// ("\n" or "\r\n") or at the end of input.
+func (b Builder[T]) AtLineEnd() (tail *Chain[T]) {
+	tail = b.append("AtLineEnd", func() Update[T] { return newAnchor[T](b.logger, atLineEnd) })
+	return
+}
+
+// AtColumn adds a zero width state that matches the given zero based column, like the
+// fields of the fixed form formats. The column is the count of runes since the line start.
+func (b Builder[T]) AtColumn(column int) (tail *Chain[T]) {
+	common.AssertTrue(column >= 0, "invalid grammar: negative column")
+	tail = b.append("AtColumn", func() Update[T] { return newAnchor[T](b.logger, atColumn(column)) })
+	return
+}
+
+// AtInputStart adds a zero width state that matches the start of input, like the
+// shebang or the front matter. The byte order mark is skipped.
+func (b Builder[T]) AtInputStart() (tail *Chain[T]) {
+	tail = b.append("AtInputStart", func() Update[T] { return newAnchor[T](b.logger, atInputStart) })
+	return
+}
+
+// AtEOF adds a zero width state that matches the end of input. The chain which reads nothing
+// would match again at the same position, so the final tokens must be emitted by the OnEOF
+// lexer hook.
+func (b Builder[T]) AtEOF() (tail *Chain[T]) {
+	tail = b.append("AtEOF", func() Update[T] { return newAnchor[T](b.logger, atEOF) })
+	return
+}
+
+// isAnchor returns true if the state is Anchor.
+func isAnchor[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Anchor[T])
+	return
+}
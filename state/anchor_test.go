@@ -0,0 +1,107 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnchors(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		skip      int
+		check     anchorFn
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:      "line start at input start",
+			input:     "abc",
+			check:     atColumn(0),
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "line start after line break",
+			input:     "a\nb",
+			skip:      2,
+			check:     atColumn(0),
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "not line start",
+			input:     "ab",
+			skip:      1,
+			check:     atColumn(0),
+			wantError: ErrRollback,
+		},
+		{
+			name:      "line end before crlf",
+			input:     "a\r\n",
+			skip:      1,
+			check:     atLineEnd,
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "line end at eof",
+			input:     "a",
+			skip:      1,
+			check:     atLineEnd,
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "not line end",
+			input:     "ab",
+			skip:      1,
+			check:     atLineEnd,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "column counts runes",
+			input:     "x\nдж",
+			skip:      6,
+			check:     atColumn(2),
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "not input start",
+			input:     "ab",
+			skip:      1,
+			check:     atInputStart,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "eof",
+			input:     "ab",
+			skip:      2,
+			check:     atEOF,
+			wantError: ErrChainNext,
+		},
+		{
+			name:      "not eof",
+			input:     "ab",
+			skip:      1,
+			check:     atEOF,
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			builder := makeTestBuilder(message.Slice[Token]())
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			tx := source.Begin().Deref()
+			_, err := tx.Read(make([]byte, tc.skip))
+			assert.NoError(t, err)
+			err = newAnchor[Token](builder.logger, tc.check).Update(context.Background(), tx)
+			assert.ErrorIs(t, err, tc.wantError)
+			// the anchors don't read anything
+			assert.Equal(t, int64(tc.skip), xio.AsOffset(tx).Offset())
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package state
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// BinaryFormat decodes a binary field.
+	BinaryFormat interface {
+		// Name returns the format name.
+		Name() string
+		// Decode decodes the value from the state. It returns ok=false if the input
+		// does not contain a valid encoded value, the state is rolled back by the caller.
+		Decode(tx xio.State) (value any, ok bool, err error)
+	}
+
+	// fixedFormat is a fixed width binary format.
+	fixedFormat struct {
+		name   string
+		size   int
+		decode func([]byte) any
+	}
+
+	// varintFormat is a LEB128 variable width binary format.
+	varintFormat struct {
+		name   string
+		signed bool
+		zigzag bool
+	}
+
+	// Binary is a state that decodes a binary field. The decoded value is emitted
+	// as the message value.
+	Binary[T any] struct {
+		logger common.Logger
+		format BinaryFormat
+	}
+)
+
+// maxVarintLen is the maximum length of the 64 bit LEB128 value.
+const maxVarintLen = binary.MaxVarintLen64
+
+var (
+	// U8 is an unsigned 8 bit integer, decoded as uint8.
+	U8 BinaryFormat = fixedFormat{name: "u8", size: 1, decode: func(p []byte) any { return p[0] }}
+	// I8 is a signed 8 bit integer, decoded as int8.
+	I8 BinaryFormat = fixedFormat{name: "i8", size: 1, decode: func(p []byte) any { return int8(p[0]) }}
+
+	// U16BE is a big endian unsigned 16 bit integer, decoded as uint16.
+	U16BE BinaryFormat = fixedFormat{name: "u16be", size: 2, decode: func(p []byte) any { return binary.BigEndian.Uint16(p) }}
+	// U16LE is a little endian unsigned 16 bit integer, decoded as uint16.
+	U16LE BinaryFormat = fixedFormat{name: "u16le", size: 2, decode: func(p []byte) any { return binary.LittleEndian.Uint16(p) }}
+	// I16BE is a big endian signed 16 bit integer, decoded as int16.
+	I16BE BinaryFormat = fixedFormat{name: "i16be", size: 2, decode: func(p []byte) any { return int16(binary.BigEndian.Uint16(p)) }}
+	// I16LE is a little endian signed 16 bit integer, decoded as int16.
+	I16LE BinaryFormat = fixedFormat{name: "i16le", size: 2, decode: func(p []byte) any { return int16(binary.LittleEndian.Uint16(p)) }}
+
+	// U32BE is a big endian unsigned 32 bit integer, decoded as uint32.
+	U32BE BinaryFormat = fixedFormat{name: "u32be", size: 4, decode: func(p []byte) any { return binary.BigEndian.Uint32(p) }}
+	// U32LE is a little endian unsigned 32 bit integer, decoded as uint32.
+	U32LE BinaryFormat = fixedFormat{name: "u32le", size: 4, decode: func(p []byte) any { return binary.LittleEndian.Uint32(p) }}
+	// I32BE is a big endian signed 32 bit integer, decoded as int32.
+	I32BE BinaryFormat = fixedFormat{name: "i32be", size: 4, decode: func(p []byte) any { return int32(binary.BigEndian.Uint32(p)) }}
+	// I32LE is a little endian signed 32 bit integer, decoded as int32.
+	I32LE BinaryFormat = fixedFormat{name: "i32le", size: 4, decode: func(p []byte) any { return int32(binary.LittleEndian.Uint32(p)) }}
+
+	// U64BE is a big endian unsigned 64 bit integer, decoded as uint64.
+	U64BE BinaryFormat = fixedFormat{name: "u64be", size: 8, decode: func(p []byte) any { return binary.BigEndian.Uint64(p) }}
+	// U64LE is a little endian unsigned 64 bit integer, decoded as uint64.
+	U64LE BinaryFormat = fixedFormat{name: "u64le", size: 8, decode: func(p []byte) any { return binary.LittleEndian.Uint64(p) }}
+	// I64BE is a big endian signed 64 bit integer, decoded as int64.
+	I64BE BinaryFormat = fixedFormat{name: "i64be", size: 8, decode: func(p []byte) any { return int64(binary.BigEndian.Uint64(p)) }}
+	// I64LE is a little endian signed 64 bit integer, decoded as int64.
+	I64LE BinaryFormat = fixedFormat{name: "i64le", size: 8, decode: func(p []byte) any { return int64(binary.LittleEndian.Uint64(p)) }}
+
+	// F32BE is a big endian IEEE-754 single precision float, decoded as float32.
+	F32BE BinaryFormat = fixedFormat{name: "f32be", size: 4, decode: func(p []byte) any { return math.Float32frombits(binary.BigEndian.Uint32(p)) }}
+	// F32LE is a little endian IEEE-754 single precision float, decoded as float32.
+	F32LE BinaryFormat = fixedFormat{name: "f32le", size: 4, decode: func(p []byte) any { return math.Float32frombits(binary.LittleEndian.Uint32(p)) }}
+	// F64BE is a big endian IEEE-754 double precision float, decoded as float64.
+	F64BE BinaryFormat = fixedFormat{name: "f64be", size: 8, decode: func(p []byte) any { return math.Float64frombits(binary.BigEndian.Uint64(p)) }}
+	// F64LE is a little endian IEEE-754 double precision float, decoded as float64.
+	F64LE BinaryFormat = fixedFormat{name: "f64le", size: 8, decode: func(p []byte) any { return math.Float64frombits(binary.LittleEndian.Uint64(p)) }}
+
+	// ULEB128 is an unsigned LEB128 varint (protobuf varint), decoded as uint64.
+	ULEB128 BinaryFormat = varintFormat{name: "uleb128"}
+	// SLEB128 is a signed LEB128 varint (DWARF, WebAssembly), decoded as int64.
+	SLEB128 BinaryFormat = varintFormat{name: "sleb128", signed: true}
+	// ZigZag is a zig-zag encoded unsigned LEB128 varint (protobuf sint64), decoded as int64.
+	ZigZag BinaryFormat = varintFormat{name: "zigzag", zigzag: true}
+)
+
+// Name implements BinaryFormat interface.
+func (f fixedFormat) Name() string {
+	return f.name
+}
+
+// Decode implements BinaryFormat interface.
+func (f fixedFormat) Decode(tx xio.State) (value any, ok bool, err error) {
+	buffer := make([]byte, f.size)
+	n, err := tx.Read(buffer)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	err = nil
+	if n < f.size {
+		return
+	}
+	value = f.decode(buffer)
+	ok = true
+	return
+}
+
+// Name implements BinaryFormat interface.
+func (f varintFormat) Name() string {
+	return f.name
+}
+
+// Decode implements BinaryFormat interface.
+func (f varintFormat) Decode(tx xio.State) (value any, ok bool, err error) {
+	buffer := make([]byte, 0, maxVarintLen)
+	for len(buffer) < maxVarintLen {
+		var b byte
+		b, err = tx.NextByte()
+		if errors.Is(err, io.EOF) {
+			// incomplete varint
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		buffer = append(buffer, b)
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	if buffer[len(buffer)-1]&0x80 != 0 {
+		// too long varint
+		return
+	}
+	switch {
+	case f.signed:
+		value, ok = decodeSLEB128(buffer)
+	case f.zigzag:
+		var u uint64
+		if u, ok = decodeULEB128(buffer); ok {
+			value = int64(u>>1) ^ -int64(u&1)
+		}
+	default:
+		value, ok = decodeULEB128(buffer)
+	}
+	return
+}
+
+// decodeULEB128 decodes unsigned LEB128 value. It returns ok=false on overflow.
+func decodeULEB128(buffer []byte) (value uint64, ok bool) {
+	for i, b := range buffer {
+		if i == maxVarintLen-1 && b > 1 {
+			return
+		}
+		value |= uint64(b&0x7F) << (7 * i)
+	}
+	ok = true
+	return
+}
+
+// decodeSLEB128 decodes signed LEB128 value. It returns ok=false on overflow.
+func decodeSLEB128(buffer []byte) (value int64, ok bool) {
+	shift := uint(0)
+	for i, b := range buffer {
+		if i == maxVarintLen-1 && b != 0 && b != 0x7F {
+			return
+		}
+		value |= int64(b&0x7F) << shift
+		shift += 7
+	}
+	if shift < 64 && buffer[len(buffer)-1]&0x40 != 0 {
+		// sign extend
+		value |= -1 << shift
+	}
+	ok = true
+	return
+}
+
+// newBinary creates a new instance of the Binary state.
+func newBinary[T any](logger common.Logger, format BinaryFormat) *Binary[T] {
+	return &Binary[T]{
+		logger: logger,
+		format: format,
+	}
+}
+
+// Update implements the Update interface. It decodes the binary field and stores the
+// decoded value to be emitted.
+func (b Binary[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	sub := xio.AsSource(tx).Begin().Deref()
+	subTx := xio.AsTx(sub)
+	value, ok, err := b.format.Decode(sub)
+	if err != nil || !ok {
+		common.AssertNoError(subTx.Rollback(), "rollback error")
+		if err == nil {
+			err = ErrRollback
+		}
+		return
+	}
+	common.AssertNoError(subTx.Commit(), "commit error")
+	setValue(ctx, value)
+	err = ErrChainNext
+	return
+}
+
+// Binary adds a state that decodes the binary field of the given format. The decoded value
+// is emitted as the message value instead of the raw bytes.
+func (b Builder[T]) Binary(format BinaryFormat) (tail *Chain[T]) {
+	common.AssertNotNil(format, "invalid grammar: nil binary format")
+	tail = b.append("Binary("+format.Name()+")", func() Update[T] { return newBinary[T](b.logger, format) })
+	return
+}
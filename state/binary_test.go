@@ -0,0 +1,90 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinary(t *testing.T) {
+	type testCase struct {
+		name      string
+		format    BinaryFormat
+		input     []byte
+		wantValue any
+		wantWidth int
+		wantError error
+	}
+
+	f32 := math.Float32bits(1.5)
+	f64 := math.Float64bits(-2.25)
+
+	tests := []testCase{
+		{name: "u8", format: U8, input: []byte{0xFF}, wantValue: uint8(0xFF), wantWidth: 1, wantError: ErrCommit},
+		{name: "i8", format: I8, input: []byte{0xFF}, wantValue: int8(-1), wantWidth: 1, wantError: ErrCommit},
+		{name: "u16be", format: U16BE, input: []byte{0x01, 0x02}, wantValue: uint16(0x0102), wantWidth: 2, wantError: ErrCommit},
+		{name: "u16le", format: U16LE, input: []byte{0x01, 0x02}, wantValue: uint16(0x0201), wantWidth: 2, wantError: ErrCommit},
+		{name: "i16be", format: I16BE, input: []byte{0xFF, 0xFE}, wantValue: int16(-2), wantWidth: 2, wantError: ErrCommit},
+		{name: "i16le", format: I16LE, input: []byte{0xFE, 0xFF}, wantValue: int16(-2), wantWidth: 2, wantError: ErrCommit},
+		{name: "u32be", format: U32BE, input: []byte{0x01, 0x02, 0x03, 0x04}, wantValue: uint32(0x01020304), wantWidth: 4, wantError: ErrCommit},
+		{name: "u32le", format: U32LE, input: []byte{0x01, 0x02, 0x03, 0x04}, wantValue: uint32(0x04030201), wantWidth: 4, wantError: ErrCommit},
+		{name: "i32be", format: I32BE, input: []byte{0xFF, 0xFF, 0xFF, 0xFD}, wantValue: int32(-3), wantWidth: 4, wantError: ErrCommit},
+		{name: "i32le", format: I32LE, input: []byte{0xFD, 0xFF, 0xFF, 0xFF}, wantValue: int32(-3), wantWidth: 4, wantError: ErrCommit},
+		{name: "u64be", format: U64BE, input: []byte{0, 0, 0, 0, 0, 0, 0x01, 0x00}, wantValue: uint64(256), wantWidth: 8, wantError: ErrCommit},
+		{name: "u64le", format: U64LE, input: []byte{0x00, 0x01, 0, 0, 0, 0, 0, 0}, wantValue: uint64(256), wantWidth: 8, wantError: ErrCommit},
+		{name: "i64be", format: I64BE, input: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, wantValue: int64(-1), wantWidth: 8, wantError: ErrCommit},
+		{name: "i64le", format: I64LE, input: []byte{0xFE, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, wantValue: int64(-2), wantWidth: 8, wantError: ErrCommit},
+		{name: "f32be", format: F32BE, input: []byte{byte(f32 >> 24), byte(f32 >> 16), byte(f32 >> 8), byte(f32)}, wantValue: float32(1.5), wantWidth: 4, wantError: ErrCommit},
+		{name: "f32le", format: F32LE, input: []byte{byte(f32), byte(f32 >> 8), byte(f32 >> 16), byte(f32 >> 24)}, wantValue: float32(1.5), wantWidth: 4, wantError: ErrCommit},
+		{name: "f64be", format: F64BE, input: []byte{byte(f64 >> 56), byte(f64 >> 48), byte(f64 >> 40), byte(f64 >> 32), byte(f64 >> 24), byte(f64 >> 16), byte(f64 >> 8), byte(f64)}, wantValue: float64(-2.25), wantWidth: 8, wantError: ErrCommit},
+		{name: "f64le", format: F64LE, input: []byte{byte(f64), byte(f64 >> 8), byte(f64 >> 16), byte(f64 >> 24), byte(f64 >> 32), byte(f64 >> 40), byte(f64 >> 48), byte(f64 >> 56)}, wantValue: float64(-2.25), wantWidth: 8, wantError: ErrCommit},
+		{name: "uleb128", format: ULEB128, input: []byte{0xE5, 0x8E, 0x26, 0xFF}, wantValue: uint64(624485), wantWidth: 3, wantError: ErrCommit},
+		{name: "uleb128 max", format: ULEB128, input: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, wantValue: uint64(math.MaxUint64), wantWidth: 10, wantError: ErrCommit},
+		{name: "uleb128 overflow", format: ULEB128, input: []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02}, wantError: ErrRollback},
+		{name: "uleb128 too long", format: ULEB128, input: bytes.Repeat([]byte{0x80}, 11), wantError: ErrRollback},
+		{name: "uleb128 incomplete", format: ULEB128, input: []byte{0x80, 0x80}, wantError: ErrRollback},
+		{name: "sleb128", format: SLEB128, input: []byte{0xC0, 0xBB, 0x78}, wantValue: int64(-123456), wantWidth: 3, wantError: ErrCommit},
+		{name: "sleb128 positive", format: SLEB128, input: []byte{0x3F}, wantValue: int64(63), wantWidth: 1, wantError: ErrCommit},
+		{name: "sleb128 min", format: SLEB128, input: []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x7F}, wantValue: int64(math.MinInt64), wantWidth: 10, wantError: ErrCommit},
+		{name: "zigzag negative", format: ZigZag, input: []byte{0x03}, wantValue: int64(-2), wantWidth: 1, wantError: ErrCommit},
+		{name: "zigzag positive", format: ZigZag, input: []byte{0x80, 0x01}, wantValue: int64(64), wantWidth: 2, wantError: ErrCommit},
+		{name: "not enough data", format: U32LE, input: []byte{0x01, 0x02}, wantError: ErrRollback},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBuffer(tc.input))
+			err := builder.Binary(tc.format).Emit(Token1).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == nil {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].Value)
+				assert.Equal(t, tc.wantWidth, receiver.Slice[0].Width)
+			}
+		})
+	}
+}
+
+func TestBinary_Record(t *testing.T) {
+	receiver := message.Slice[Token]()
+	builder := makeTestBuilder(receiver)
+	// magic followed by u16le length
+	source := xio.New(builder.logger, bytes.NewBuffer([]byte{'B', 'N', 0x02, 0x00, 0xAA, 0xBB}))
+	err := builder.String("BN").Binary(U16LE).Emit(Token1).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	assert.ErrorIs(t, err, ErrCommit)
+	if assert.Len(t, receiver.Slice, 1) {
+		assert.Equal(t, uint16(2), receiver.Slice[0].Value)
+		assert.Equal(t, 0, receiver.Slice[0].Pos)
+		assert.Equal(t, 4, receiver.Slice[0].Width)
+	}
+}
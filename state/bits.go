@@ -0,0 +1,107 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// Bits is a state that reads a bit field and checks its value by the predicate.
+	// The read value is emitted as the message value.
+	Bits[T any] struct {
+		logger common.Logger
+		n      int
+		order  xio.BitOrder
+		pred   BitsPredicate
+	}
+
+	// AlignBits is a state that skips the rest of the partially read byte.
+	AlignBits[T any] struct {
+		logger common.Logger
+	}
+)
+
+// newBits creates a new instance of the Bits state.
+func newBits[T any](logger common.Logger, n int, order xio.BitOrder, pred BitsPredicate) *Bits[T] {
+	return &Bits[T]{
+		logger: logger,
+		n:      n,
+		order:  order,
+		pred:   pred,
+	}
+}
+
+// Update implements the Update interface. It reads the bit field.
+func (bs Bits[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	value, err := xio.AsBits(tx).NextBits(bs.n, bs.order)
+	if errors.Is(err, io.EOF) {
+		err = ErrRollback
+		return
+	}
+	if err != nil {
+		return
+	}
+	if !bs.pred(value) {
+		_, err = tx.Unread()
+		common.AssertNoError(err, "unread error")
+		err = ErrRollback
+		return
+	}
+	setValue(ctx, value)
+	err = ErrChainNext
+	return
+}
+
+// newAlignBits creates a new instance of the AlignBits state.
+func newAlignBits[T any](logger common.Logger) *AlignBits[T] {
+	return &AlignBits[T]{
+		logger: logger,
+	}
+}
+
+// Update implements the Update interface. It aligns the state to the byte boundary.
+func (a AlignBits[T]) Update(_ context.Context, tx xio.State) (err error) {
+	xio.AsBits(tx).AlignBits()
+	err = ErrChainNext
+	return
+}
+
+// BitsCheck adds a state that reads n bits (1..64) in the given order and checks the
+// value by the predicate. The value is emitted as the message value.
+func (b Builder[T]) BitsCheck(n int, order xio.BitOrder, pred BitsPredicate) (tail *Chain[T]) {
+	common.AssertTrue(n > 0 && n <= xio.MaxBits, "invalid grammar: invalid bits count: %d", n)
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	tail = b.append("BitsCheck", func() Update[T] { return newBits[T](b.logger, n, order, pred) })
+	return
+}
+
+// Bits adds a state that reads n bits (1..64) in the given order. The value is emitted
+// as the message value.
+func (b Builder[T]) Bits(n int, order xio.BitOrder) (tail *Chain[T]) {
+	tail = b.BitsCheck(n, order, True[uint64]())
+	return
+}
+
+// BitsValue adds a state that reads n bits (1..64) in the given order and checks that
+// the value is equal to the sample.
+func (b Builder[T]) BitsValue(n int, order xio.BitOrder, sample uint64) (tail *Chain[T]) {
+	tail = b.BitsCheck(n, order, IsBits(sample))
+	return
+}
+
+// AlignBits adds a state that skips the rest of the partially read byte. Byte level
+// states and emitting align the state automatically.
+func (b Builder[T]) AlignBits() (tail *Chain[T]) {
+	tail = b.append("AlignBits", func() Update[T] { return newAlignBits[T](b.logger) })
+	return
+}
+
+// isAlignBits returns true if the state is AlignBits.
+func isAlignBits[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*AlignBits[T])
+	return
+}
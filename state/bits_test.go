@@ -0,0 +1,99 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBits(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     []byte
+		state     func(b Builder[Token]) *Chain[Token]
+		wantValue any
+		wantWidth int
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:  "version and flags",
+			input: []byte{0b101_00110, 0xFF},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.BitsValue(3, xio.MSBFirst, 0b101).Bits(5, xio.MSBFirst).Emit(Token1)
+			},
+			wantValue: uint64(0b00110),
+			wantWidth: 1,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "version mismatch",
+			input: []byte{0b100_00110},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.BitsValue(3, xio.MSBFirst, 0b101).Bits(5, xio.MSBFirst).Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+		{
+			name:  "repeated flags",
+			input: []byte{0b1110_0000},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.BitsValue(1, xio.MSBFirst, 1).Repeat(CountBetween(1, 8)).Bits(2, xio.MSBFirst).Emit(Token1)
+			},
+			wantValue: uint64(0),
+			wantWidth: 1,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "lsb first check",
+			input: []byte{0b0000_0110},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.BitsCheck(3, xio.LSBFirst, func(v uint64) bool { return v == 0b110 }).Emit(Token1)
+			},
+			wantValue: uint64(0b110),
+			wantWidth: 1,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "align before byte",
+			input: []byte{0b1000_0000, 'a'},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Bits(1, xio.MSBFirst).AlignBits().Byte('a').Emit(Token1)
+			},
+			wantValue: uint64(1),
+			wantWidth: 2,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "not enough bits",
+			input: []byte{0xFF},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Bits(9, xio.MSBFirst).Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBuffer(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == nil {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].Value)
+				assert.Equal(t, tc.wantWidth, receiver.Slice[0].Width)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"unicode/utf8"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// Mark is a state that marks the start of the capture.
+	Mark[T any] struct {
+		logger common.Logger
+		name   string
+	}
+
+	// Capture is a state that captures the data read by the chain since the mark.
+	Capture[T any] struct {
+		logger common.Logger
+		name   string
+	}
+
+	// MatchCapture is a state that matches the captured data.
+	MatchCapture[T any] struct {
+		logger common.Logger
+		name   string
+	}
+
+	// UntilCapture is a state that reads until the captured data.
+	UntilCapture[T any] struct {
+		logger common.Logger
+		name   string
+		flags  UntilFlags
+	}
+
+	// RepeatCapture is a state that repeats the previous state as many times as there
+	// are runes in the captured data.
+	RepeatCapture[T any] struct {
+		logger common.Logger
+		name   string
+	}
+)
+
+// Update implements the Update interface. It stores the current position as the mark.
+func (m Mark[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	setMark(ctx, m.name, xio.AsOffset(tx).Offset())
+	err = ErrChainNext
+	return
+}
+
+// Update implements the Update interface. It stores the data between the mark, or the
+// chain start if there is no mark, and the current position as the capture.
+func (c Capture[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	from, ok := getMark(ctx, c.name)
+	if !ok || from < pos {
+		from = pos
+	}
+	setCapture(ctx, c.name, data[from-pos:])
+	err = ErrChainNext
+	return
+}
+
+// Update implements the Update interface. It matches the captured data, the state is
+// rolled back if there is no such capture.
+func (mc MatchCapture[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	sample, ok := GetCapture(ctx, mc.name)
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	data := make([]byte, len(sample))
+	n, readErr := tx.Read(data)
+	if readErr != nil && !errors.Is(readErr, io.EOF) {
+		err = readErr
+		return
+	}
+	if !bytes.Equal(data[:n], sample) {
+		_, err = tx.Unread()
+		common.AssertNoError(err, "unread error")
+		err = ErrRollback
+		return
+	}
+	err = ErrChainNext
+	return
+}
+
+// Update implements the Update interface. It reads until the captured data like
+// UntilBytes, the state is rolled back if there is no such capture or it is empty.
+func (uc UntilCapture[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	sample, ok := GetCapture(ctx, uc.name)
+	if !ok || len(sample) == 0 {
+		err = ErrRollback
+		return
+	}
+	err = newUntilBytes[T](uc.logger, uc.flags, [][]byte{sample}).Update(ctx, tx)
+	return
+}
+
+// Update implements the Update interface. It works as Repeat with the count of runes
+// in the captured data, the state is rolled back if there is no such capture.
+func (rc RepeatCapture[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	sample, ok := GetCapture(ctx, rc.name)
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	err = newRepeat[T](rc.logger, Count(uint(utf8.RuneCount(sample)))).Update(ctx, tx)
+	return
+}
+
+// Mark adds a state that marks the start of the named capture. It doesn't read anything.
+func (b Builder[T]) Mark(name string) (tail *Chain[T]) {
+	tail = b.append("Mark("+name+")", func() Update[T] { return &Mark[T]{logger: b.logger, name: name} })
+	return
+}
+
+// Capture adds a state that captures the data read by the chain since the named mark, or
+// since the chain start if there is no mark. The capture is visible to the rest of the chain
+// and to its sub states. It is discarded with the chain, so the captures of the rolled back
+// chains are never visible.
+func (b Builder[T]) Capture(name string) (tail *Chain[T]) {
+	tail = b.append("Capture("+name+")", func() Update[T] { return &Capture[T]{logger: b.logger, name: name} })
+	return
+}
+
+// MatchCapture adds a state that matches the named capture, like the closing delimiter
+// of the Rust raw strings or the PostgreSQL dollar quoted strings.
+func (b Builder[T]) MatchCapture(name string) (tail *Chain[T]) {
+	tail = b.append("MatchCapture("+name+")", func() Update[T] { return &MatchCapture[T]{logger: b.logger, name: name} })
+	return
+}
+
+// UntilCapture adds a state that reads until the named capture, like the body of the
+// heredocs. The flags are the same as for UntilBytes.
+func (b Builder[T]) UntilCapture(flags UntilFlags, name string) (tail *Chain[T]) {
+	tail = b.append("UntilCapture("+name+")", func() Update[T] {
+		return &UntilCapture[T]{logger: b.logger, name: name, flags: flags}
+	})
+	return
+}
+
+// RepeatCapture adds a state that repeats the previous state as many times as there are
+// runes in the named capture. Like Repeat, the previous state has been applied once already,
+// so the empty capture rolls back the chain.
+func (b Builder[T]) RepeatCapture(name string) (tail *Chain[T]) {
+	common.AssertNotNilPtr(b.last, "invalid grammar: repeat can't be the first state in chain")
+	common.AssertTrue(isRepeatable[T](b.last.deref()), "invalid grammar: previous state '%s' is not repeatable", b.last.name())
+	tail = b.append("RepeatCapture("+name+")", func() Update[T] { return &RepeatCapture[T]{logger: b.logger, name: name} })
+	return
+}
+
+// isNotRepeatableCapture returns true if the state is Mark, Capture or RepeatCapture.
+func isNotRepeatableCapture[T any](s Update[T]) (ret bool) {
+	switch s.(type) {
+	case *Mark[T], *Capture[T], *RepeatCapture[T]:
+		ret = true
+	}
+	return
+}
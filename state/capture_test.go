@@ -0,0 +1,172 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	type testCase struct {
+		name      string
+		state     func(b Builder[Token]) *Chain[Token]
+		input     string
+		wantValue string
+		wantError error
+	}
+
+	heredoc := func(b Builder[Token]) *Chain[Token] {
+		return b.String("<<").
+			Mark("tag").Identifier(GoIdentifier).Capture("tag").
+			Rune('\n').
+			UntilCapture(UntilInclude, "tag").
+			Emit(Token1)
+	}
+	rawString := func(b Builder[Token]) *Chain[Token] {
+		return b.Rune('r').
+			Mark("hashes").WhileRune(IsRune('#')).Optional().Capture("hashes").
+			Rune('"').UntilRune(IsRune('"')).Rune('"').
+			MatchCapture("hashes").
+			Emit(Token1)
+	}
+	dollarQuoted := func(b Builder[Token]) *Chain[Token] {
+		return b.Rune('$').WhileRune(unicode.IsLetter).Optional().Rune('$').Capture("tag").
+			UntilCapture(UntilInclude, "tag").
+			Emit(Token1)
+	}
+	counted := func(b Builder[Token]) *Chain[Token] {
+		return b.WhileRune(IsRune('=')).Capture("eq").
+			Rune('[').UntilRune(IsRune(']')).Rune(']').
+			Rune('=').RepeatCapture("eq").
+			Emit(Token1)
+	}
+	nested := func(b Builder[Token]) *Chain[Token] {
+		return b.Identifier(GoIdentifier).Capture("id").
+			Rune(':').
+			State(b, func(b Builder[Token]) []Update[Token] {
+				return AsSlice[Update[Token]](
+					b.MatchCapture("id").Break(),
+				)
+			}).
+			Emit(Token1)
+	}
+
+	tests := []testCase{
+		{
+			name:      "heredoc",
+			state:     heredoc,
+			input:     "<<END\nline one\nEND rest",
+			wantValue: "<<END\nline one\nEND",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "unterminated heredoc",
+			state:     heredoc,
+			input:     "<<END\nline one\n",
+			wantError: ErrRollback,
+		},
+		{
+			name:      "raw string",
+			state:     rawString,
+			input:     `r##"abc"## rest`,
+			wantValue: `r##"abc"##`,
+			wantError: ErrCommit,
+		},
+		{
+			name:      "raw string without hashes",
+			state:     rawString,
+			input:     `r"abc"`,
+			wantValue: `r"abc"`,
+			wantError: ErrCommit,
+		},
+		{
+			name:      "raw string with less hashes",
+			state:     rawString,
+			input:     `r##"abc"#`,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "dollar quoted",
+			state:     dollarQuoted,
+			input:     "$fn$ select $1 $fn$;",
+			wantValue: "$fn$ select $1 $fn$",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "dollar quoted without tag",
+			state:     dollarQuoted,
+			input:     "$$a$b$$",
+			wantValue: "$$a$b$$",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "counted",
+			state:     counted,
+			input:     "==[x]===",
+			wantValue: "==[x]==",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "counted with less",
+			state:     counted,
+			input:     "==[x]=",
+			wantError: ErrRollback,
+		},
+		{
+			name:      "visible to sub state",
+			state:     nested,
+			input:     "abc:abc",
+			wantValue: "abc:abc",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "sub state mismatch",
+			state:     nested,
+			input:     "abc:abd",
+			wantValue: "abc:",
+			wantError: ErrCommit,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := tc.state(builder)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantError != ErrCommit {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
+
+func TestCapture_Scope(t *testing.T) {
+	receiver := message.Slice[Token]()
+	builder := makeTestBuilder(receiver)
+	var inner []byte
+	// the capture of the sub state is not visible to the outer chain
+	state := builder.State(builder, func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.Identifier(GoIdentifier).Capture("id").Tap(func(ctx context.Context, _ xio.State) error {
+				inner, _ = GetCapture(ctx, "id")
+				return nil
+			}).Break(),
+		)
+	}).Rune(':').MatchCapture("id").Emit(Token1)
+	source := xio.New(builder.logger, bytes.NewBufferString("abc:abc"))
+	err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	assert.ErrorIs(t, err, ErrRollback)
+	assert.Equal(t, "abc", string(inner))
+}
@@ -97,6 +97,14 @@ func (c *Chain[T]) forwardMessages() (err error) {
 
 // Update implements State interface
 func (c *Chain[T]) Update(ctx context.Context, ioState xio.State) (err error) {
+	defer func() {
+		if errors.Is(err, ErrRollback) {
+			// the transaction will be rolled back, so the collected messages, like the
+			// warnings, are dropped, otherwise they will be sent with the next commit
+			c.head().receiver.Reset()
+		}
+	}()
+	ctx = withCaptureScope(withValueSlot(ctx))
 	current := c.head()
 	for current != nil {
 		next := current.next()
@@ -138,7 +146,14 @@ func (c *Chain[T]) Update(ctx context.Context, ioState xio.State) (err error) {
 			}
 			err = ErrChainNext
 		case errors.Is(err, errStateBreak):
-			common.AssertNilPtr(next, "invalid grammar: next can't be from last in chain")
+			// only states reporting errors can break the chain before its end
+			common.AssertTrue(next == nil || isBreaking[T](current.deref()), "invalid grammar: next can't be from last in chain")
+			if action, ok := getBreakAction(err); ok && errors.Is(action, xio.ErrNeedMore) {
+				// the transaction will be rolled back to wait for more input, so the collected
+				// messages are dropped, otherwise they will be duplicated by the next feed
+				c.head().receiver.Reset()
+				return
+			}
 			if forwardErr := c.forwardMessages(); forwardErr != nil {
 				err = MakeErrBreak(forwardErr)
 			}
@@ -146,6 +161,9 @@ func (c *Chain[T]) Update(ctx context.Context, ioState xio.State) (err error) {
 		case errors.Is(err, ErrIncomplete), errors.Is(err, ErrInvalidInput):
 			// pass known errors as is
 		default:
+			// the transaction will be rolled back, so the collected messages are dropped,
+			// otherwise they will be duplicated if the input is processed again
+			c.head().receiver.Reset()
 			// wrap all other errors with state break error
 			err = MakeErrBreak(err)
 			return
@@ -3,6 +3,7 @@ package state
 import (
 	"context"
 
+	"github.com/diakovliev/lexer/common"
 	"github.com/diakovliev/lexer/message"
 )
 
@@ -14,8 +15,25 @@ const (
 	historyKey    keyType = "history"
 	factoryKey    keyType = "factory"
 	receiverKey   keyType = "receiver"
+	utf8ValuesKey keyType = "utf8-values"
+	valueKey      keyType = "value"
+	captureKey    keyType = "capture"
 )
 
+// valueSlot holds the decoded value of the chain.
+type valueSlot struct {
+	value any
+	ok    bool
+}
+
+// captureScope holds the marks and the captures of the chain. The lookups fall back
+// to the scopes of the enclosing chains.
+type captureScope struct {
+	parent   *captureScope
+	marks    map[string]int64
+	captures map[string][]byte
+}
+
 // WithHistoryProvider sets the history provider to the context.
 func WithHistoryProvider[T any](ctx context.Context, history message.History[T]) context.Context {
 	return context.WithValue(ctx, historyKey, history)
@@ -95,3 +113,85 @@ func GetStateName(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithUTF8Values enables conversion of the emitted token values to UTF-8 from
+// the input encoding. Positions and widths are still reported in input bytes.
+func WithUTF8Values(ctx context.Context) context.Context {
+	return context.WithValue(ctx, utf8ValuesKey, true)
+}
+
+// IsUTF8Values returns true if the emitted token values must be converted to UTF-8.
+func IsUTF8Values(ctx context.Context) bool {
+	v, ok := ctx.Value(utf8ValuesKey).(bool)
+	return ok && v
+}
+
+// withValueSlot sets the new empty value slot to the context.
+func withValueSlot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, valueKey, &valueSlot{})
+}
+
+// setValue stores the decoded value to the value slot of the context. It does nothing
+// if there is no value slot in the context.
+func setValue(ctx context.Context, value any) {
+	if slot, ok := ctx.Value(valueKey).(*valueSlot); ok {
+		slot.value = value
+		slot.ok = true
+	}
+}
+
+// GetValue returns the last value decoded by the current chain, e.g. by the Binary state.
+// If there is no decoded value, it will return nil, false.
+func GetValue(ctx context.Context) (any, bool) {
+	if slot, ok := ctx.Value(valueKey).(*valueSlot); ok && slot.ok {
+		return slot.value, true
+	}
+	return nil, false
+}
+
+// withCaptureScope sets the new capture scope nested in the current one to the context.
+func withCaptureScope(ctx context.Context) context.Context {
+	parent, _ := ctx.Value(captureKey).(*captureScope)
+	return context.WithValue(ctx, captureKey, &captureScope{parent: parent})
+}
+
+// setMark stores the mark position to the capture scope of the context.
+func setMark(ctx context.Context, name string, offset int64) {
+	scope, ok := ctx.Value(captureKey).(*captureScope)
+	common.AssertTrue(ok, "no capture scope in context")
+	if scope.marks == nil {
+		scope.marks = make(map[string]int64)
+	}
+	scope.marks[name] = offset
+}
+
+// getMark returns the mark position from the capture scope of the context. Only the
+// marks of the current chain are visible.
+func getMark(ctx context.Context, name string) (offset int64, ok bool) {
+	if scope, isScope := ctx.Value(captureKey).(*captureScope); isScope {
+		offset, ok = scope.marks[name]
+	}
+	return
+}
+
+// setCapture stores the captured data to the capture scope of the context.
+func setCapture(ctx context.Context, name string, data []byte) {
+	scope, ok := ctx.Value(captureKey).(*captureScope)
+	common.AssertTrue(ok, "no capture scope in context")
+	if scope.captures == nil {
+		scope.captures = make(map[string][]byte)
+	}
+	scope.captures[name] = data
+}
+
+// GetCapture returns the data captured by the Capture state of the current chain or of
+// the enclosing chains. If there is no such capture, it will return nil, false.
+func GetCapture(ctx context.Context, name string) ([]byte, bool) {
+	scope, _ := ctx.Value(captureKey).(*captureScope)
+	for ; scope != nil; scope = scope.parent {
+		if data, ok := scope.captures[name]; ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,48 @@
+package state
+
+import (
+	"strconv"
+)
+
+// Converter converts the token data to the token value at emit time.
+type Converter func(data []byte) (value any, err error)
+
+// Convert makes the Converter from the typed conversion function.
+func Convert[V any](fn func(data []byte) (V, error)) Converter {
+	return func(data []byte) (value any, err error) {
+		value, err = fn(data)
+		return
+	}
+}
+
+// ToInt64 returns the converter which parses the token as an int64 number in the given
+// base. Base 0 means that the base is implied by the prefix, like in Go literals.
+func ToInt64(base int) Converter {
+	return Convert(func(data []byte) (int64, error) {
+		return strconv.ParseInt(string(data), base, 64)
+	})
+}
+
+// ToUint64 returns the converter which parses the token as an uint64 number in the given
+// base. Base 0 means that the base is implied by the prefix, like in Go literals.
+func ToUint64(base int) Converter {
+	return Convert(func(data []byte) (uint64, error) {
+		return strconv.ParseUint(string(data), base, 64)
+	})
+}
+
+// ToFloat64 is the converter which parses the token as a float64 number.
+var ToFloat64 = Convert(func(data []byte) (float64, error) {
+	return strconv.ParseFloat(string(data), 64)
+})
+
+// ToUnquoted is the converter which decodes the token as a Go quoted string or
+// character literal to a string.
+var ToUnquoted = Convert(func(data []byte) (string, error) {
+	return strconv.Unquote(string(data))
+})
+
+// ToString is the converter which converts the token to a string.
+var ToString = Convert(func(data []byte) (string, error) {
+	return string(data), nil
+})
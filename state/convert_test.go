@@ -0,0 +1,108 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitAs(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		state     func(b Builder[Token]) *Chain[Token]
+		wantType  message.Type
+		wantValue any
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:  "int64",
+			input: "0x1f",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rest().EmitAs(Token1, ToInt64(0))
+			},
+			wantType:  message.Token,
+			wantValue: int64(31),
+			wantError: ErrCommit,
+		},
+		{
+			name:  "float64",
+			input: "1.5e3",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rest().EmitAs(Token1, ToFloat64)
+			},
+			wantType:  message.Token,
+			wantValue: float64(1500),
+			wantError: ErrCommit,
+		},
+		{
+			name:  "unquoted",
+			input: `"a\tb"`,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rest().EmitAs(Token1, ToUnquoted)
+			},
+			wantType:  message.Token,
+			wantValue: "a\tb",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "conversion error",
+			input: "99999999999999999999",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rest().EmitAs(Token1, ToInt64(10))
+			},
+			wantType:  message.Error,
+			wantError: strconv.ErrRange,
+		},
+		{
+			name:  "conversion error before the chain end",
+			input: "12x",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.RuneCheck(unicode.IsDigit).Repeat(CountBetween(1, 10)).
+					EmitAs(Token1, ToUint64(2)).Rune('x').Emit(Token2)
+			},
+			wantType:  message.Error,
+			wantError: strconv.ErrSyntax,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			if !assert.Len(t, receiver.Slice, 1) {
+				return
+			}
+			msg := receiver.Slice[0]
+			assert.Equal(t, tc.wantType, msg.Type)
+			assert.Equal(t, 0, msg.Pos)
+			if tc.wantType == message.Error {
+				assert.ErrorIs(t, err, errStateBreak)
+				assert.ErrorIs(t, msg.AsError(), tc.wantError)
+				return
+			}
+			assert.ErrorIs(t, err, tc.wantError)
+			assert.Equal(t, tc.wantValue, msg.Value)
+		})
+	}
+}
+
+func TestValueOf(t *testing.T) {
+	msg := &message.Message[Token]{Type: message.Token, Value: int64(42)}
+	assert.Equal(t, int64(42), msg.AsInt64())
+	value, ok := message.ValueOf[int64](msg)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), value)
+	_, ok = message.ValueOf[string](msg)
+	assert.False(t, ok)
+}
@@ -0,0 +1,212 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrUnterminatedDelimited indicates that the delimited region is not closed.
+	ErrUnterminatedDelimited = errors.New("unterminated delimited region")
+	// ErrMaxDepth indicates that the nesting depth of the delimited region exceeds the maximum.
+	ErrMaxDepth = errors.New("nesting depth exceeds maximum")
+)
+
+type (
+	// DelimitedRules describes the region between the open and the close delimiters.
+	DelimitedRules struct {
+		// Open is the open delimiter, e.g. "/*".
+		Open string
+		// Close is the close delimiter, e.g. "*/".
+		Close string
+		// Nested enables the nested regions, the region ends at the matching close.
+		Nested bool
+		// Escape is the escape rune, the escaped rune is not a delimiter. Zero means no escapes.
+		Escape rune
+		// MaxDepth is the maximum nesting depth. Zero means no limit.
+		MaxDepth int
+		// Level is the rune repeated between the two runes of the Open and the Close,
+		// e.g. '=' in Lua long brackets [==[ ]==]. The close must repeat it the same number
+		// of times as the open. Zero means no level.
+		Level rune
+	}
+
+	// Delimited is a state that matches the region between the open and the close
+	// delimiters, optionally nested.
+	Delimited[T any] struct {
+		logger   common.Logger
+		rules    DelimitedRules
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+)
+
+var (
+	// CComment is the C block comment, it is not nested.
+	CComment = DelimitedRules{Open: "/*", Close: "*/"}
+	// NestedComment is the nested block comment, e.g. in Rust.
+	NestedComment = DelimitedRules{Open: "/*", Close: "*/", Nested: true}
+	// HaskellComment is the nested Haskell block comment.
+	HaskellComment = DelimitedRules{Open: "{-", Close: "-}", Nested: true}
+	// OCamlComment is the nested OCaml comment.
+	OCamlComment = DelimitedRules{Open: "(*", Close: "*)", Nested: true}
+	// LuaLongBracket is the Lua long bracket, e.g. [==[ ... ]==].
+	LuaLongBracket = DelimitedRules{Open: "[[", Close: "]]", Level: '='}
+	// BraceBlock is the block of balanced braces.
+	BraceBlock = DelimitedRules{Open: "{", Close: "}", Nested: true}
+)
+
+// WithMaxDepth returns a copy of the rules with the given maximum nesting depth.
+func (dr DelimitedRules) WithMaxDepth(depth int) DelimitedRules {
+	dr.MaxDepth = depth
+	return dr
+}
+
+// newDelimited creates a new instance of the Delimited state.
+func newDelimited[T any](logger common.Logger, factory message.Factory[T], rules DelimitedRules) *Delimited[T] {
+	return &Delimited[T]{
+		logger:  logger,
+		rules:   rules,
+		factory: factory,
+	}
+}
+
+// setReceiver sets the receiver of the state.
+func (d *Delimited[T]) setReceiver(receiver message.Receiver[T]) {
+	d.receiver = receiver
+}
+
+// open matches the open delimiter and returns the close delimiter. The level runes
+// of the open are repeated in the returned close.
+func (d Delimited[T]) open(tx xio.State) (closeDelim string, ok bool, err error) {
+	if d.rules.Level == 0 {
+		closeDelim = d.rules.Close
+		ok, err = lookahead(tx, d.rules.Open, true)
+		return
+	}
+	first, firstSize := utf8.DecodeRuneInString(d.rules.Open)
+	closeFirst, closeSize := utf8.DecodeRuneInString(d.rules.Close)
+	if ok, err = lookahead(tx, string(first), true); err != nil || !ok {
+		return
+	}
+	level := 0
+	for {
+		var isLevel bool
+		if isLevel, err = lookahead(tx, string(d.rules.Level), true); err != nil {
+			return
+		}
+		if !isLevel {
+			break
+		}
+		level++
+	}
+	if ok, err = lookahead(tx, d.rules.Open[firstSize:], true); err != nil || !ok {
+		return
+	}
+	closeDelim = string(closeFirst) + strings.Repeat(string(d.rules.Level), level) + d.rules.Close[closeSize:]
+	return
+}
+
+// reportError emits the error message for the data between from and the current
+// position of the tx and returns the break error.
+func (d Delimited[T]) reportError(ctx context.Context, tx xio.State, from int64, userErr error) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	err = emitErrorAt(ctx, d.factory, d.receiver, userErr, data[from-pos:], int(from))
+	return
+}
+
+// Update implements the Update interface. It matches the whole delimited region,
+// including the delimiters.
+func (d Delimited[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(d.receiver, "receiver is not set")
+	offset := xio.AsOffset(tx)
+	start := offset.Offset()
+	sub := xio.AsSource(tx).Begin().Deref()
+	subTx := xio.AsTx(sub)
+	closeDelim, ok, err := d.open(sub)
+	if err != nil || !ok {
+		common.AssertNoError(subTx.Rollback(), "rollback error")
+		if err == nil {
+			err = ErrRollback
+		}
+		return
+	}
+	common.AssertNoError(subTx.Commit(), "commit error")
+	nested := d.rules.Nested && d.rules.Level == 0
+	for depth := 1; depth > 0; {
+		current := offset.Offset()
+		var matched bool
+		if matched, err = lookahead(tx, closeDelim, true); err != nil {
+			return
+		}
+		if matched {
+			depth--
+			continue
+		}
+		if nested {
+			if matched, err = lookahead(tx, d.rules.Open, true); err != nil {
+				return
+			}
+			if matched {
+				depth++
+				if d.rules.MaxDepth > 0 && depth > d.rules.MaxDepth {
+					err = d.reportError(ctx, tx, current, fmt.Errorf("%w: %d", ErrMaxDepth, d.rules.MaxDepth))
+					return
+				}
+				continue
+			}
+		}
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 {
+			err = d.reportError(ctx, tx, start, ErrUnterminatedDelimited)
+			return
+		}
+		if d.rules.Escape == 0 || r != d.rules.Escape {
+			continue
+		}
+		if _, rw, nextErr = tx.NextRune(); nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 {
+			err = d.reportError(ctx, tx, start, ErrUnterminatedDelimited)
+			return
+		}
+	}
+	err = ErrChainNext
+	return
+}
+
+// Delimited adds a state that matches the region between the open and the close delimiters,
+// like the block comments, the Lua long brackets or the blocks of balanced braces. If the
+// rules allow nesting, the region ends at the matching close. Unterminated regions and
+// regions nested deeper than the maximum depth are reported as error messages.
+func (b Builder[T]) Delimited(rules DelimitedRules) (tail *Chain[T]) {
+	common.AssertTrue(rules.Open != "" && rules.Close != "", "invalid grammar: empty delimiters")
+	common.AssertTrue(rules.Level == 0 || utf8.RuneCountInString(rules.Open) == 2 && utf8.RuneCountInString(rules.Close) == 2,
+		"invalid grammar: level delimiters must be two runes")
+	newNode := newDelimited(b.logger, b.factory, rules)
+	tail = b.append("Delimited", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isDelimited returns true if the state is Delimited.
+func isDelimited[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Delimited[T])
+	return
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelimited(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		rules     DelimitedRules
+		wantValue string
+		wantError error
+		wantPos   int
+		wantData  string
+	}
+
+	tests := []testCase{
+		{
+			name:      "c comment is not nested",
+			input:     "/* a /* b */ c */",
+			rules:     CComment,
+			wantValue: "/* a /* b */",
+		},
+		{
+			name:      "nested comment",
+			input:     "/* a /* b */ c */ tail",
+			rules:     NestedComment,
+			wantValue: "/* a /* b */ c */",
+		},
+		{
+			name:      "haskell comment",
+			input:     "{- a {- b -} -}-}",
+			rules:     HaskellComment,
+			wantValue: "{- a {- b -} -}",
+		},
+		{
+			name:      "lua long bracket",
+			input:     "[==[ a ]] ]=] ]==] tail",
+			rules:     LuaLongBracket,
+			wantValue: "[==[ a ]] ]=] ]==]",
+		},
+		{
+			name:      "lua long bracket without level",
+			input:     "[[a]]",
+			rules:     LuaLongBracket,
+			wantValue: "[[a]]",
+		},
+		{
+			name:      "escaped close",
+			input:     `{ a \} { b } }`,
+			rules:     DelimitedRules{Open: "{", Close: "}", Nested: true, Escape: '\\'},
+			wantValue: `{ a \} { b } }`,
+		},
+		{
+			name:      "no open",
+			input:     "[=a",
+			rules:     LuaLongBracket,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "unterminated",
+			input:     "/* a /* b */",
+			rules:     NestedComment,
+			wantError: ErrUnterminatedDelimited,
+			wantPos:   0,
+			wantData:  "/* a /* b */",
+		},
+		{
+			name:      "lua close with other level",
+			input:     "[=[ a ]]",
+			rules:     LuaLongBracket,
+			wantError: ErrUnterminatedDelimited,
+			wantPos:   0,
+			wantData:  "[=[ a ]]",
+		},
+		{
+			name:      "max depth",
+			input:     "{{{}}}",
+			rules:     BraceBlock.WithMaxDepth(2),
+			wantError: ErrMaxDepth,
+			wantPos:   2,
+			wantData:  "{",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.Delimited(tc.rules).Emit(Token1)
+			// the state is re-entrant, so it gives the same result on retry
+			for range 2 {
+				receiver.Reset()
+				source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+				err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+				switch {
+				case tc.wantError == ErrRollback:
+					assert.ErrorIs(t, err, ErrRollback)
+					assert.Empty(t, receiver.Slice)
+				case tc.wantError != nil:
+					assert.ErrorIs(t, err, errStateBreak)
+					if assert.Len(t, receiver.Slice, 1) {
+						msg := receiver.Slice[0]
+						assert.ErrorIs(t, msg.AsError(), tc.wantError)
+						assert.Equal(t, tc.wantPos, msg.Pos)
+						assert.Equal(t, tc.wantData, string(msg.AsError().Value.([]byte)))
+					}
+				default:
+					assert.ErrorIs(t, err, ErrCommit)
+					if assert.Len(t, receiver.Slice, 1) {
+						assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+					}
+				}
+			}
+		})
+	}
+}
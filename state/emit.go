@@ -12,6 +12,7 @@ import (
 type Emit[T any] struct {
 	logger   common.Logger
 	fn       func() T
+	convert  Converter
 	factory  message.Factory[T]
 	receiver message.Receiver[T]
 }
@@ -41,11 +42,27 @@ func (e Emit[T]) Update(ctx context.Context, tx xio.State) (err error) {
 	common.AssertFalse(len(data) == 0, "nothing to emit")
 	level, ok := GetTokenLevel(ctx)
 	common.AssertTrue(ok, "no token level in context")
-	msg, err := e.factory.Token(ctx, level, e.fn(), data, int(pos), len(data))
+	value := data
+	if IsUTF8Values(ctx) {
+		value = xio.ToUTF8(xio.EncodingOf(tx), data)
+	}
+	msg, err := e.factory.Token(ctx, level, e.fn(), value, int(pos), len(data))
 	if err != nil {
 		err = MakeErrBreak(err)
 		return
 	}
+	if decoded, ok := GetValue(ctx); ok {
+		// the value decoded by the chain, e.g. by the Binary state, replaces the raw data
+		msg.Value = decoded
+	}
+	if e.convert != nil {
+		converted, convertErr := e.convert(value)
+		if convertErr != nil {
+			err = e.convertError(ctx, level, convertErr, value, int(pos), len(data))
+			return
+		}
+		msg.Value = converted
+	}
 	err = e.receiver.Receive(AsSlice(msg))
 	if err != nil {
 		err = MakeErrBreak(err)
@@ -55,9 +72,25 @@ func (e Emit[T]) Update(ctx context.Context, tx xio.State) (err error) {
 	return
 }
 
-func (b Builder[T]) emitState(name string, token func() T) (tail *Chain[T]) {
+// convertError reports the conversion error as an error message at the token position.
+func (e Emit[T]) convertError(ctx context.Context, level int, convertErr error, data []byte, pos int, width int) (err error) {
+	msg, err := e.factory.Error(ctx, level, convertErr, data, pos, width)
+	if err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	if err = e.receiver.Receive(AsSlice(msg)); err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	err = MakeErrBreak(msg.AsError())
+	return
+}
+
+func (b Builder[T]) emitState(name string, token func() T, convert Converter) (tail *Chain[T]) {
 	common.AssertNotNilPtr(b.last, "invalid grammar: emit can't be the first state in chain")
 	newNode := newEmit(b.logger, b.factory, token)
+	newNode.convert = convert
 	tail = b.append(name, func() Update[T] { return newNode })
 	// sent all messages to the the first node receiver
 	newNode.setReceiver(tail.head().receiver)
@@ -66,13 +99,28 @@ func (b Builder[T]) emitState(name string, token func() T) (tail *Chain[T]) {
 
 // Emit emits given token.
 func (b Builder[T]) Emit(token T) (tail *Chain[T]) {
-	tail = b.emitState("Emit", func() T { return token })
+	tail = b.emitState("Emit", func() T { return token }, nil)
+	return
+}
+
+// EmitAs emits given token with the value converted from the token data by the converter.
+// If the conversion fails, the error message is emitted at the token position instead.
+func (b Builder[T]) EmitAs(token T, convert Converter) (tail *Chain[T]) {
+	common.AssertNotNil(convert, "invalid grammar: nil converter")
+	tail = b.emitState("EmitAs", func() T { return token }, convert)
 	return
 }
 
 // EmitFn emits token received from the given function.
 func (b Builder[T]) EmitFn(fn func() T) (tail *Chain[T]) {
-	tail = b.emitState("EmitFn", fn)
+	tail = b.emitState("EmitFn", fn, nil)
+	return
+}
+
+// isConvertingEmit checks if the state is Emit with the converter, it can fail.
+func isConvertingEmit[T any](s Update[T]) (ret bool) {
+	e, ok := s.(*Emit[T])
+	ret = ok && e.convert != nil
 	return
 }
 
@@ -0,0 +1,80 @@
+package state
+
+import (
+	"context"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// EncodingFn is a function that returns the encoding to switch to.
+	// It can be used to pick the encoding from the already lexed data, e.g. from the history.
+	EncodingFn func(context.Context) (xio.Encoding, error)
+
+	// Encoding is a state that switches the rune decoder of the current transaction.
+	// The encoding stays active for the remainder of the current sub State or until
+	// it is switched again.
+	Encoding[T any] struct {
+		logger common.Logger
+		fn     EncodingFn
+	}
+)
+
+// newEncoding creates a new instance of the Encoding state.
+func newEncoding[T any](logger common.Logger, fn EncodingFn) *Encoding[T] {
+	return &Encoding[T]{
+		logger: logger,
+		fn:     fn,
+	}
+}
+
+// Update implements the Update interface. It switches the rune decoder of the given transaction.
+// If the function returns nil encoding the state rollbacks.
+func (e Encoding[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	enc, err := e.fn(ctx)
+	if err != nil {
+		return
+	}
+	if enc == nil {
+		err = ErrRollback
+		return
+	}
+	xio.AsReencode(tx).SetEncoding(enc)
+	err = ErrChainNext
+	return
+}
+
+// Encoding adds a state that switches the rune decoder to the given encoding.
+func (b Builder[T]) Encoding(enc xio.Encoding) (tail *Chain[T]) {
+	common.AssertNotNil(enc, "invalid grammar: nil encoding")
+	tail = b.EncodingFn(func(context.Context) (xio.Encoding, error) { return enc, nil })
+	return
+}
+
+// EncodingFn adds a state that switches the rune decoder to the encoding returned
+// by the given function.
+func (b Builder[T]) EncodingFn(fn EncodingFn) (tail *Chain[T]) {
+	common.AssertNotNil(fn, "invalid grammar: nil encoding function")
+	tail = b.append("Encoding", func() Update[T] { return newEncoding[T](b.logger, fn) })
+	return
+}
+
+// isEncoding checks if the given state is an encoding state.
+func isEncoding[T any](s Update[T]) bool {
+	_, ok := s.(*Encoding[T])
+	return ok
+}
+
+// saveEncoding returns the function which restores the current encoding of the
+// given transaction. It does nothing if the transaction has no encoding.
+func saveEncoding(tx xio.State) (restore func()) {
+	reencode, ok := tx.(xio.Reencode)
+	if !ok {
+		restore = func() {}
+		return
+	}
+	enc := xio.EncodingOf(tx)
+	restore = func() { reencode.SetEncoding(enc) }
+	return
+}
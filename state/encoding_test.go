@@ -0,0 +1,99 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		state     func(b Builder[Token]) *Chain[Token]
+		wantValue string
+		wantError error
+	}
+
+	utf16le := "a\x00b\x00"
+
+	tests := []testCase{
+		{
+			name:  "switch encoding",
+			input: "[" + utf16le,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rune('[').Encoding(xio.UTF16LE).Rune('a').Rune('b').Emit(Token1)
+			},
+			wantValue: "[" + utf16le,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "encoding is restored after sub state",
+			input: "[" + utf16le + "]",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rune('[').State(b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.Encoding(xio.UTF16LE).RuneCheck(unicode.IsLetter).Repeat(CountBetween(1, 10)).Omit(),
+					)
+				}).Rune(']').Emit(Token1)
+			},
+			wantValue: "[" + utf16le + "]",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "encoding function",
+			input: utf16le,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.EncodingFn(func(context.Context) (xio.Encoding, error) {
+					enc, _ := xio.LookupEncoding("utf-16le")
+					return enc, nil
+				}).Rune('a').Emit(Token1)
+			},
+			wantValue: "a\x00",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "unknown encoding",
+			input: utf16le,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.EncodingFn(func(context.Context) (xio.Encoding, error) {
+					return nil, nil
+				}).Rune('a').Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+		{
+			name:  "encoding function error",
+			input: utf16le,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.EncodingFn(func(context.Context) (xio.Encoding, error) {
+					return nil, errors.New("unknown charset")
+				}).Rune('a').Emit(Token1)
+			},
+			wantError: errStateBreak,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == "" {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
@@ -81,3 +81,49 @@ func isError[T any](s Update[T]) (ret bool) {
 	_, ret = s.(*Error[T])
 	return
 }
+
+// emitErrorMessage creates the error message for the data read by the chain so far,
+// sends it to the receiver and returns the break error.
+func emitErrorMessage[T any](
+	ctx context.Context,
+	factory message.Factory[T],
+	receiver message.Receiver[T],
+	tx xio.State,
+	userErr error,
+) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	err = emitErrorAt(ctx, factory, receiver, userErr, data, int(pos))
+	return
+}
+
+// emitErrorAt creates the error message for the given data at the given position,
+// sends it to the receiver and returns the break error.
+func emitErrorAt[T any](
+	ctx context.Context,
+	factory message.Factory[T],
+	receiver message.Receiver[T],
+	userErr error,
+	data []byte,
+	pos int,
+) (err error) {
+	level, ok := GetTokenLevel(ctx)
+	common.AssertTrue(ok, "no token level in context")
+	msg, err := factory.Error(ctx, level, userErr, data, pos, len(data))
+	if err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	if err = receiver.Receive(AsSlice(msg)); err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	err = MakeErrBreak(msg.AsError())
+	return
+}
+
+// isBreaking returns true if the state can break the chain before its end by reporting an error.
+func isBreaking[T any](s Update[T]) (ret bool) {
+	ret = Or(isCheck[T], isSized[T], isCounted[T], isConvertingEmit[T], isStringLiteral[T], isNumberLiteral[T], isInterpolated[T], isDelimited[T], isLayout[T])(s)
+	return
+}
@@ -3,6 +3,8 @@ package state
 // EscapeCondition is a condition that checks if the input rune is escaped by another rune.
 // It is designed to be used in Until state to parse strings with escape characters.
 // See: grammar_test.go: stringState for an example of using Escape in Until state.
+// The condition is stateful and does not decode escapes, see StringLiteral for the
+// re-entrant state which decodes them.
 type EscapeCondition struct {
 	escape  func(r rune) bool
 	cond    func(r rune) bool
@@ -0,0 +1,120 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+// FnGrapheme is a state that checks if the next extended grapheme cluster matches the predicate.
+type FnGrapheme[T any] struct {
+	logger common.Logger
+	pred   GraphemePredicate
+	mode   fnMode
+}
+
+// newFnGrapheme creates a new state that checks if the next grapheme cluster matches the predicate.
+func newFnGrapheme[T any](logger common.Logger, pred GraphemePredicate, mode fnMode) *FnGrapheme[T] {
+	return &FnGrapheme[T]{
+		logger: logger,
+		pred:   pred,
+		mode:   mode,
+	}
+}
+
+// Update implements the Update interface. It checks if the next grapheme cluster matches
+// the predicate and returns an error if it doesn't match.
+func (fg FnGrapheme[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	g, gw, err := tx.NextGrapheme()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	if errors.Is(err, io.EOF) && gw == 0 {
+		err = ErrRollback
+		return
+	}
+	result := fg.pred(g)
+	switch fg.mode {
+	case fnAccept:
+		if !result {
+			_, err = tx.Unread()
+			common.AssertNoError(err, "unread error")
+			err = ErrRollback
+			return
+		}
+	case fnLook:
+		_, err = tx.Unread()
+		common.AssertNoError(err, "unread error")
+	}
+	if result {
+		err = ErrChainNext
+	} else {
+		err = ErrRollback
+	}
+	return
+}
+
+// isNotRepeatableFnGrapheme returns true if the state is not repeatable
+func isNotRepeatableFnGrapheme[T any](s Update[T]) bool {
+	i, ok := s.(*FnGrapheme[T])
+	if !ok {
+		return false
+	}
+	ok = i.mode == fnLook
+	return ok
+}
+
+// GraphemeCheck is a state that matches extended grapheme cluster by the given function.
+func (b Builder[T]) GraphemeCheck(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	tail = b.append("GraphemeCheck", func() Update[T] { return newFnGrapheme[T](b.logger, pred, fnAccept) })
+	return
+}
+
+// FollowedByGraphemeCheck is a state that matches grapheme cluster by the given function and then rollbacks if it fails.
+func (b Builder[T]) FollowedByGraphemeCheck(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	tail = b.append("FollowedByGraphemeCheck", func() Update[T] { return newFnGrapheme[T](b.logger, pred, fnLook) })
+	return
+}
+
+// NotGraphemeCheck is a state that matches grapheme cluster by the given function and returns an error if it does match.
+func (b Builder[T]) NotGraphemeCheck(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	tail = b.append("NotGraphemeCheck", func() Update[T] { return newFnGrapheme[T](b.logger, Not(pred), fnAccept) })
+	return
+}
+
+// FollowedByNotGraphemeCheck is a state that matches grapheme cluster by the given function and rollbacks if it does match.
+func (b Builder[T]) FollowedByNotGraphemeCheck(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	tail = b.append("FollowedByNotGraphemeCheck", func() Update[T] { return newFnGrapheme[T](b.logger, Not(pred), fnLook) })
+	return
+}
+
+// Grapheme is a state that matches the given grapheme cluster.
+func (b Builder[T]) Grapheme(sample string) (tail *Chain[T]) {
+	tail = b.append("Grapheme", func() Update[T] { return newFnGrapheme[T](b.logger, IsGrapheme(sample), fnAccept) })
+	return
+}
+
+// NotGrapheme is a state that matches all grapheme clusters except the given one.
+func (b Builder[T]) NotGrapheme(sample string) (tail *Chain[T]) {
+	tail = b.append("NotGrapheme", func() Update[T] { return newFnGrapheme[T](b.logger, Not(IsGrapheme(sample)), fnAccept) })
+	return
+}
+
+// AnyGrapheme is a state that matches any grapheme cluster.
+func (b Builder[T]) AnyGrapheme() (tail *Chain[T]) {
+	tail = b.append("AnyGrapheme", func() Update[T] { return newFnGrapheme[T](b.logger, True[string](), fnAccept) })
+	return
+}
+
+// FollowedByAnyGrapheme is a state that matches any grapheme cluster and rollbacks if it does not match.
+func (b Builder[T]) FollowedByAnyGrapheme() (tail *Chain[T]) {
+	tail = b.append("FollowedByAnyGrapheme", func() Update[T] { return newFnGrapheme[T](b.logger, True[string](), fnLook) })
+	return
+}
@@ -0,0 +1,113 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrapheme(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		state     func(b Builder[Token]) *Chain[Token]
+		wantValue string
+		wantError error
+	}
+
+	thumbsUp := "\U0001F44D\U0001F3FD"
+
+	tests := []testCase{
+		{
+			name:  "any grapheme count",
+			input: "e\u0301" + thumbsUp + "ab",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.AnyGrapheme().Repeat(Count(3)).Emit(Token1)
+			},
+			wantValue: "e\u0301" + thumbsUp + "a",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "any rune splits grapheme",
+			input: thumbsUp,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.AnyRune().Emit(Token1)
+			},
+			wantValue: "\U0001F44D",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "grapheme sample",
+			input: thumbsUp + "!",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Grapheme(thumbsUp).Rune('!').Emit(Token1)
+			},
+			wantValue: thumbsUp + "!",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "grapheme sample does not match base",
+			input: thumbsUp,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Grapheme("\U0001F44D").Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+		{
+			name:  "followed by grapheme",
+			input: "a" + thumbsUp,
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Rune('a').FollowedByGraphemeCheck(IsGrapheme(thumbsUp)).Emit(Token1)
+			},
+			wantValue: "a",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "until grapheme",
+			input: "ab" + thumbsUp + "c",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.UntilGrapheme(IsGrapheme(thumbsUp)).Emit(Token1)
+			},
+			wantValue: "ab",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "graphemes width limited field",
+			input: "e\u0301e\u0301e\u0301e\u0301",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Graphemes(CountBetween(1, 3)).Emit(Token1)
+			},
+			wantValue: "e\u0301e\u0301e\u0301",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "graphemes not enough",
+			input: "e\u0301",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Graphemes(Count(2)).Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == "" {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"unicode"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/diakovliev/lexer/xunicode"
+)
+
+type (
+	// IdentifierRules describes runes allowed in identifiers.
+	IdentifierRules struct {
+		// Start accepts the first rune of the identifier.
+		Start RunePredicate
+		// Continue accepts all subsequent runes of the identifier.
+		Continue RunePredicate
+		// Medial accepts runes allowed only between two Continue runes,
+		// e.g. ZWJ and ZWNJ. It is optional.
+		Medial RunePredicate
+	}
+
+	// Identifier is a state that matches an identifier by the given rules.
+	Identifier[T any] struct {
+		logger common.Logger
+		rules  IdentifierRules
+	}
+)
+
+var (
+	// XIDIdentifier is the UAX #31 default identifier: XID_Start XID_Continue*.
+	// Since Unicode 15.1 XID_Continue includes ZWJ and ZWNJ, see WithMedialJoinControls.
+	XIDIdentifier = IdentifierRules{
+		Start:    xunicode.IsXIDStart,
+		Continue: xunicode.IsXIDContinue,
+	}
+
+	// GoIdentifier is the Go identifier: (letter | '_') (letter | '_' | digit)*.
+	GoIdentifier = IdentifierRules{
+		Start:    Or(unicode.IsLetter, IsRune('_')),
+		Continue: Or(unicode.IsLetter, IsRune('_'), unicode.IsDigit),
+	}
+
+	// RustIdentifier is the Rust identifier: (XID_Start | '_') XID_Continue*.
+	RustIdentifier = IdentifierRules{
+		Start:    Or(xunicode.IsXIDStart, IsRune('_')),
+		Continue: xunicode.IsXIDContinue,
+	}
+
+	// PythonIdentifier is the Python identifier: (XID_Start | '_') XID_Continue*.
+	PythonIdentifier = IdentifierRules{
+		Start:    Or(xunicode.IsXIDStart, IsRune('_')),
+		Continue: xunicode.IsXIDContinue,
+	}
+
+	// JavaScriptIdentifier is the ECMAScript identifier:
+	// (ID_Start | '$' | '_') (ID_Continue | '$' | ZWNJ | ZWJ)*.
+	JavaScriptIdentifier = IdentifierRules{
+		Start:    Or(xunicode.IsIDStart, IsRune('$'), IsRune('_')),
+		Continue: Or(xunicode.IsIDContinue, IsRune('$'), xunicode.IsJoinControl),
+	}
+)
+
+// WithMedialJoinControls returns a copy of the rules that allows ZWJ and ZWNJ
+// only between two Continue runes, so an identifier can't end with an invisible
+// join control.
+func (ir IdentifierRules) WithMedialJoinControls() IdentifierRules {
+	ir.Continue = And(ir.Continue, Not(xunicode.IsJoinControl))
+	ir.Medial = xunicode.IsJoinControl
+	return ir
+}
+
+// WithDollar returns a copy of the rules that allows '$' in any position of the identifier.
+func (ir IdentifierRules) WithDollar() IdentifierRules {
+	ir.Start = Or(ir.Start, IsRune('$'))
+	ir.Continue = Or(ir.Continue, IsRune('$'))
+	return ir
+}
+
+// newIdentifier creates a new instance of the Identifier state.
+func newIdentifier[T any](logger common.Logger, rules IdentifierRules) *Identifier[T] {
+	return &Identifier[T]{
+		logger: logger,
+		rules:  rules,
+	}
+}
+
+// medial checks if the medial rune is followed by the continue rune. It advances
+// the tx only if the check is passed.
+func (id Identifier[T]) medial(tx xio.State) (ret bool, err error) {
+	ahead := xio.AsSource(tx).Begin().Deref()
+	lookTx := xio.AsTx(ahead)
+	// skip medial rune
+	_, _, err = ahead.NextRune()
+	common.AssertNoError(err, "next rune error")
+	r, rw, err := ahead.NextRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		common.AssertNoError(lookTx.Rollback(), "rollback error")
+		return
+	}
+	err = nil
+	if rw == 0 || !id.rules.Continue(r) {
+		common.AssertNoError(lookTx.Rollback(), "rollback error")
+		return
+	}
+	common.AssertNoError(lookTx.Commit(), "commit error")
+	ret = true
+	return
+}
+
+// Update implements the Update interface. It reads the identifier runes.
+func (id Identifier[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	r, rw, err := tx.NextRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	if rw == 0 || !id.rules.Start(r) {
+		if rw != 0 {
+			_, err = tx.Unread()
+			common.AssertNoError(err, "unread error")
+		}
+		err = ErrRollback
+		return
+	}
+	for {
+		r, rw, err = tx.NextRune()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+		if rw == 0 {
+			break
+		}
+		if id.rules.Continue(r) {
+			continue
+		}
+		_, err = tx.Unread()
+		common.AssertNoError(err, "unread error")
+		if id.rules.Medial == nil || !id.rules.Medial(r) {
+			break
+		}
+		var ok bool
+		if ok, err = id.medial(tx); err != nil {
+			return
+		}
+		if !ok {
+			break
+		}
+	}
+	err = ErrChainNext
+	return
+}
+
+// Identifier adds a state that matches an identifier by the given rules.
+func (b Builder[T]) Identifier(rules IdentifierRules) (tail *Chain[T]) {
+	common.AssertNotNil(rules.Start, "invalid grammar: nil identifier start predicate")
+	common.AssertNotNil(rules.Continue, "invalid grammar: nil identifier continue predicate")
+	tail = b.append("Identifier", func() Update[T] { return newIdentifier[T](b.logger, rules) })
+	return
+}
@@ -0,0 +1,137 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentifier(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		rules     IdentifierRules
+		wantValue string
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:      "go ascii",
+			input:     "_foo1 bar",
+			rules:     GoIdentifier,
+			wantValue: "_foo1",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "go unicode",
+			input:     "héllo+",
+			rules:     GoIdentifier,
+			wantValue: "héllo",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "go starts with digit",
+			input:     "1foo",
+			rules:     GoIdentifier,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "xid no underscore start",
+			input:     "_foo",
+			rules:     XIDIdentifier,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "xid combining mark",
+			input:     "cafe\u0301 ",
+			rules:     XIDIdentifier,
+			wantValue: "cafe\u0301",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "rust underscore",
+			input:     "_x",
+			rules:     RustIdentifier,
+			wantValue: "_x",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "python stops at dollar",
+			input:     "a$b",
+			rules:     PythonIdentifier,
+			wantValue: "a",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "javascript dollar",
+			input:     "$a$b",
+			rules:     JavaScriptIdentifier,
+			wantValue: "$a$b",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "javascript zwj",
+			input:     "a\u200db",
+			rules:     JavaScriptIdentifier,
+			wantValue: "a\u200db",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "xid join control",
+			input:     "ab\u200c ",
+			rules:     XIDIdentifier,
+			wantValue: "ab\u200c",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "go without join controls",
+			input:     "a\u200cb",
+			rules:     GoIdentifier,
+			wantValue: "a",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "xid medial join control",
+			input:     "a\u200cb",
+			rules:     XIDIdentifier.WithMedialJoinControls(),
+			wantValue: "a\u200cb",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "xid trailing join control",
+			input:     "ab\u200c ",
+			rules:     XIDIdentifier.WithMedialJoinControls(),
+			wantValue: "ab",
+			wantError: ErrCommit,
+		},
+		{
+			name:      "xid with dollar",
+			input:     "$ab",
+			rules:     XIDIdentifier.WithDollar(),
+			wantValue: "$ab",
+			wantError: ErrCommit,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := builder.Identifier(tc.rules).Emit(Token1).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == "" {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrUnterminatedInterpolation indicates that the embedded expression is not closed.
+	ErrUnterminatedInterpolation = errors.New("unterminated interpolation")
+
+	// errInterpolationEnd stops the embedded expression run at the interpolation close.
+	errInterpolationEnd = errors.New("interpolation end")
+)
+
+type (
+	// InterpolationRules describes the interpolated string syntax.
+	InterpolationRules struct {
+		// Prefix is the optional prefix before the opening quote, e.g. "f" for f-strings.
+		Prefix string
+		// Quote is the opening and closing quote.
+		Quote string
+		// Escape is the escape rune, the escaped rune is a part of the text. Zero means no escapes.
+		Escape rune
+		// Open opens the embedded expression, e.g. "${".
+		Open string
+		// Close closes the embedded expression, e.g. "}".
+		Close string
+		// BraceOpen and BraceClose are the tokens balanced inside the embedded expression,
+		// the expression is closed only by the Close outside of them. The braces are counted
+		// in the sub state tokens which consist of the braces only, e.g. "{" or "{{", so
+		// the braces inside the other tokens, like the string literals, are ignored.
+		BraceOpen  string
+		BraceClose string
+		// Doubled makes the doubled Open and Close a part of the text, e.g. "{{" in f-strings.
+		Doubled bool
+		// Multiline allows new lines in the text.
+		Multiline bool
+	}
+
+	// InterpolationTokens are the tokens emitted for the interpolated string.
+	InterpolationTokens[T any] struct {
+		// Start is emitted for the prefix and the opening quote.
+		Start T
+		// Part is emitted for each text part, the value is the raw text.
+		Part T
+		// InterpStart is emitted for the expression open.
+		InterpStart T
+		// InterpEnd is emitted for the expression close.
+		InterpEnd T
+		// End is emitted for the closing quote.
+		End T
+	}
+
+	// Interpolated is a state that matches the string with the embedded expressions.
+	// The text parts and the delimiters are emitted by the state, the expressions
+	// are lexed by the sub state.
+	Interpolated[T any] struct {
+		logger   common.Logger
+		rules    InterpolationRules
+		tokens   InterpolationTokens[T]
+		builder  Builder[T]
+		provider Provider[T]
+		run      *Run[T]
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+)
+
+var (
+	// TemplateString is the JavaScript template string, e.g. `Hello ${user.name}!`.
+	TemplateString = InterpolationRules{
+		Quote:      "`",
+		Escape:     '\\',
+		Open:       "${",
+		Close:      "}",
+		BraceOpen:  "{",
+		BraceClose: "}",
+		Multiline:  true,
+	}
+
+	// PythonFString is the Python f-string, e.g. f"Hello {user.name}!".
+	PythonFString = InterpolationRules{
+		Prefix:     "f",
+		Quote:      `"`,
+		Escape:     '\\',
+		Open:       "{",
+		Close:      "}",
+		BraceOpen:  "{",
+		BraceClose: "}",
+		Doubled:    true,
+	}
+)
+
+// lookahead checks if the sample is next in the input. It advances the tx only if
+// the sample is matched and consume is true.
+func lookahead(tx xio.State, sample string, consume bool) (ret bool, err error) {
+	if sample == "" {
+		return
+	}
+	ahead := xio.AsSource(tx).Begin().Deref()
+	lookTx := xio.AsTx(ahead)
+	for _, s := range sample {
+		var r rune
+		var rw int
+		r, rw, err = ahead.NextRune()
+		if err != nil && !errors.Is(err, io.EOF) {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+		err = nil
+		if rw == 0 || r != s {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+	}
+	if consume {
+		common.AssertNoError(lookTx.Commit(), "commit error")
+	} else {
+		common.AssertNoError(lookTx.Rollback(), "rollback error")
+	}
+	ret = true
+	return
+}
+
+// newInterpolated creates a new instance of the Interpolated state.
+func newInterpolated[T any](
+	logger common.Logger,
+	factory message.Factory[T],
+	rules InterpolationRules,
+	tokens InterpolationTokens[T],
+	builder Builder[T],
+	provider Provider[T],
+) *Interpolated[T] {
+	return &Interpolated[T]{
+		logger:   logger,
+		factory:  factory,
+		rules:    rules,
+		tokens:   tokens,
+		builder:  builder,
+		provider: provider,
+	}
+}
+
+// setReceiver sets the receiver of the state. The expressions messages are sent to
+// the same receiver, so they are ordered with the text parts.
+func (i *Interpolated[T]) setReceiver(receiver message.Receiver[T]) {
+	i.receiver = receiver
+	builder := i.builder
+	builder.receiver = receiver
+	i.run = NewRun(i.logger, builder, i.provider, ErrInvalidInput)
+}
+
+// emit emits the token for the data between from and the current position of the tx.
+func (i Interpolated[T]) emit(ctx context.Context, tx xio.State, token T, from int64) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	value := data[from-pos:]
+	if len(value) == 0 {
+		return
+	}
+	if IsUTF8Values(ctx) {
+		value = xio.ToUTF8(xio.EncodingOf(tx), value)
+	}
+	level, ok := GetTokenLevel(ctx)
+	common.AssertTrue(ok, "no token level in context")
+	msg, err := i.factory.Token(ctx, level, token, value, int(from), len(data)-int(from-pos))
+	if err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	if err = i.receiver.Receive(AsSlice(msg)); err != nil {
+		err = MakeErrBreak(err)
+	}
+	return
+}
+
+// reportError emits the error message for the data between from and the current
+// position of the tx and returns the break error.
+func (i Interpolated[T]) reportError(ctx context.Context, tx xio.State, from int64, userErr error) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	err = emitErrorAt(ctx, i.factory, i.receiver, userErr, data[from-pos:], int(from))
+	return
+}
+
+// doubled checks if the doubled sample is next in the input and consumes it.
+func (i Interpolated[T]) doubled(tx xio.State, sample string) (ret bool, err error) {
+	if !i.rules.Doubled {
+		return
+	}
+	ret, err = lookahead(tx, sample+sample, true)
+	return
+}
+
+// braces returns the depth change made by the committed text if it consists of the
+// braces only, otherwise zero.
+func (i Interpolated[T]) braces(text string) (delta int) {
+	if i.rules.BraceOpen == "" || i.rules.BraceClose == "" {
+		return
+	}
+	for text != "" {
+		switch {
+		case strings.HasPrefix(text, i.rules.BraceOpen):
+			delta++
+			text = text[len(i.rules.BraceOpen):]
+		case strings.HasPrefix(text, i.rules.BraceClose):
+			delta--
+			text = text[len(i.rules.BraceClose):]
+		default:
+			delta = 0
+			return
+		}
+	}
+	return
+}
+
+// expression lexes the embedded expression by the sub state until the balanced close.
+// It returns true if the close is reached.
+func (i Interpolated[T]) expression(ctx context.Context, tx xio.State) (closed bool, err error) {
+	offset := xio.AsOffset(tx)
+	if closed, err = lookahead(tx, i.rules.Close, false); err != nil || closed {
+		return
+	}
+	depth := 0
+	last := offset.Offset()
+	i.run.WithCommitHook(func(context.Context) (hookErr error) {
+		data, pos, hookErr := xio.AsPending(tx).Pending()
+		common.AssertNoError(hookErr, "pending data error")
+		current := offset.Offset()
+		depth += i.braces(string(data[last-pos : current-pos]))
+		last = current
+		if depth > 0 {
+			return
+		}
+		end, hookErr := lookahead(tx, i.rules.Close, false)
+		if hookErr == nil && end {
+			hookErr = errInterpolationEnd
+		}
+		return
+	})
+	defer i.run.Reset()
+	err = i.run.Run(ctx, xio.AsSource(tx))
+	switch {
+	case errors.Is(err, errInterpolationEnd):
+		closed, err = true, nil
+	case errors.Is(err, ErrCommit):
+		// the sub state is terminated by Break
+		closed, err = lookahead(tx, i.rules.Close, false)
+	case errors.Is(err, ErrIncomplete), errors.Is(err, ErrInvalidInput):
+		err = nil
+	default:
+		// the sub state reported an error, the messages must be forwarded
+		err = MakeErrBreak(err)
+	}
+	return
+}
+
+// Update implements the Update interface. It emits the tokens for the quotes, the text
+// parts and the expression delimiters, the expressions are lexed by the sub state.
+func (i Interpolated[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(i.receiver, "receiver is not set")
+	offset := xio.AsOffset(tx)
+	start := offset.Offset()
+	if i.rules.Prefix != "" {
+		if ok, prefixErr := lookahead(tx, i.rules.Prefix, true); prefixErr != nil || !ok {
+			err = ErrRollback
+			return
+		}
+	}
+	if ok, quoteErr := lookahead(tx, i.rules.Quote, true); quoteErr != nil || !ok {
+		err = ErrRollback
+		return
+	}
+	if err = i.emit(ctx, tx, i.tokens.Start, start); err != nil {
+		return
+	}
+	part := offset.Offset()
+	for {
+		current := offset.Offset()
+		var ok bool
+		if ok, err = lookahead(tx, i.rules.Quote, false); err != nil {
+			return
+		}
+		if ok {
+			if err = i.emit(ctx, tx, i.tokens.Part, part); err != nil {
+				return
+			}
+			_, err = lookahead(tx, i.rules.Quote, true)
+			common.AssertNoError(err, "lookahead error")
+			if err = i.emit(ctx, tx, i.tokens.End, current); err != nil {
+				return
+			}
+			err = ErrCommit
+			return
+		}
+		if ok, err = i.doubled(tx, i.rules.Open); err != nil {
+			return
+		}
+		if ok {
+			continue
+		}
+		if ok, err = i.doubled(tx, i.rules.Close); err != nil {
+			return
+		}
+		if ok {
+			continue
+		}
+		if ok, err = lookahead(tx, i.rules.Open, false); err != nil {
+			return
+		}
+		if ok {
+			if err = i.emit(ctx, tx, i.tokens.Part, part); err != nil {
+				return
+			}
+			_, err = lookahead(tx, i.rules.Open, true)
+			common.AssertNoError(err, "lookahead error")
+			if err = i.emit(ctx, tx, i.tokens.InterpStart, current); err != nil {
+				return
+			}
+			var closed bool
+			if closed, err = i.expression(ctx, tx); err != nil {
+				return
+			}
+			if !closed {
+				err = i.reportError(ctx, tx, current, ErrUnterminatedInterpolation)
+				return
+			}
+			end := offset.Offset()
+			_, err = lookahead(tx, i.rules.Close, true)
+			common.AssertNoError(err, "lookahead error")
+			if err = i.emit(ctx, tx, i.tokens.InterpEnd, end); err != nil {
+				return
+			}
+			part = offset.Offset()
+			continue
+		}
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 || r == '\n' && !i.rules.Multiline {
+			err = i.reportError(ctx, tx, start, ErrUnterminatedString)
+			return
+		}
+		if i.rules.Escape != 0 && r == i.rules.Escape {
+			if _, rw, nextErr = tx.NextRune(); nextErr != nil && !errors.Is(nextErr, io.EOF) {
+				err = nextErr
+				return
+			}
+			if rw == 0 {
+				err = i.reportError(ctx, tx, start, ErrUnterminatedString)
+				return
+			}
+		}
+	}
+}
+
+// Interpolated adds a state that matches the string with the embedded expressions, like
+// the template strings or the f-strings. It emits the Start, Part, InterpStart, InterpEnd
+// and End tokens, the expressions are lexed by the sub state produced by the provider
+// until the Close outside of the balanced braces. The expression tokens are emitted on
+// the next level. The sub state can contain the Interpolated state itself, so the
+// interpolations can be nested to any depth.
+func (b Builder[T]) Interpolated(
+	rules InterpolationRules,
+	tokens InterpolationTokens[T],
+	builder Builder[T],
+	provider Provider[T],
+) (tail *Chain[T]) {
+	common.AssertTrue(rules.Quote != "" && rules.Open != "" && rules.Close != "", "invalid grammar: empty interpolation delimiters")
+	common.AssertNotNil(provider, "invalid grammar: nil provider")
+	newNode := newInterpolated(b.logger, b.factory, rules, tokens, builder, provider)
+	tail = b.append("Interpolated", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isInterpolated returns true if the state is Interpolated.
+func isInterpolated[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Interpolated[T])
+	return
+}
@@ -0,0 +1,212 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterpolated(t *testing.T) {
+	type wantMessage struct {
+		token Token
+		value string
+		level int
+	}
+
+	type testCase struct {
+		name      string
+		input     string
+		rules     InterpolationRules
+		want      []wantMessage
+		wantError error
+		wantPos   int
+		wantData  string
+	}
+
+	tokens := InterpolationTokens[Token]{
+		Start:       Token1,
+		Part:        Token2,
+		InterpStart: Token3,
+		InterpEnd:   Token4,
+		End:         Token5,
+	}
+
+	var expression Provider[Token]
+	expression = func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.Named("Spaces").WhileRune(unicode.IsSpace).Omit(),
+			b.Named("Identifier").Identifier(GoIdentifier).Emit(Token6),
+			b.Named("Braces").String("{{").Emit(Token6),
+			b.Named("Punct").RuneCheck(Or(IsRune('.'), IsRune('{'), IsRune('}'), IsRune(':'))).Emit(Token6),
+			b.Named("String").StringLiteral(GoString).Emit(Token6),
+			b.Named("Template").Interpolated(TemplateString, tokens, b, expression),
+		)
+	}
+
+	tests := []testCase{
+		{
+			name:  "template string",
+			input: "`Hello ${user.name}!` tail",
+			rules: TemplateString,
+			want: []wantMessage{
+				{Token1, "`", 0},
+				{Token2, "Hello ", 0},
+				{Token3, "${", 0},
+				{Token6, "user", 1},
+				{Token6, ".", 1},
+				{Token6, "name", 1},
+				{Token4, "}", 0},
+				{Token2, "!", 0},
+				{Token5, "`", 0},
+			},
+		},
+		{
+			name:  "balanced braces and nested strings",
+			input: "`a${ {k: \"v}\"} }b`",
+			rules: TemplateString,
+			want: []wantMessage{
+				{Token1, "`", 0},
+				{Token2, "a", 0},
+				{Token3, "${", 0},
+				{Token6, "{", 1},
+				{Token6, "k", 1},
+				{Token6, ":", 1},
+				{Token6, "v}", 1},
+				{Token6, "}", 1},
+				{Token4, "}", 0},
+				{Token2, "b", 0},
+				{Token5, "`", 0},
+			},
+		},
+		{
+			name:  "several braces in one token",
+			input: "`a${ {{k} } }b`",
+			rules: TemplateString,
+			want: []wantMessage{
+				{Token1, "`", 0},
+				{Token2, "a", 0},
+				{Token3, "${", 0},
+				{Token6, "{{", 1},
+				{Token6, "k", 1},
+				{Token6, "}", 1},
+				{Token6, "}", 1},
+				{Token4, "}", 0},
+				{Token2, "b", 0},
+				{Token5, "`", 0},
+			},
+		},
+		{
+			name:  "nested template",
+			input: "`a${`b${c}`}`",
+			rules: TemplateString,
+			want: []wantMessage{
+				{Token1, "`", 0},
+				{Token2, "a", 0},
+				{Token3, "${", 0},
+				{Token1, "`", 1},
+				{Token2, "b", 1},
+				{Token3, "${", 1},
+				{Token6, "c", 2},
+				{Token4, "}", 1},
+				{Token5, "`", 1},
+				{Token4, "}", 0},
+				{Token5, "`", 0},
+			},
+		},
+		{
+			name:  "escapes and empty expression",
+			input: "`\\${x}${}`",
+			rules: TemplateString,
+			want: []wantMessage{
+				{Token1, "`", 0},
+				{Token2, "\\${x}", 0},
+				{Token3, "${", 0},
+				{Token4, "}", 0},
+				{Token5, "`", 0},
+			},
+		},
+		{
+			name:  "f-string doubled braces",
+			input: `f"{{{x}}}"`,
+			rules: PythonFString,
+			want: []wantMessage{
+				{Token1, `f"`, 0},
+				{Token2, "{{", 0},
+				{Token3, "{", 0},
+				{Token6, "x", 1},
+				{Token4, "}", 0},
+				{Token2, "}}", 0},
+				{Token5, `"`, 0},
+			},
+		},
+		{
+			name:      "not a template",
+			input:     `"abc"`,
+			rules:     TemplateString,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "unterminated interpolation",
+			input:     "`a${b",
+			rules:     TemplateString,
+			wantError: ErrUnterminatedInterpolation,
+			wantPos:   2,
+			wantData:  "${b",
+		},
+		{
+			name:      "error in expression",
+			input:     "`a${b`",
+			rules:     TemplateString,
+			wantError: ErrUnterminatedString,
+			wantPos:   5,
+			wantData:  "`",
+		},
+		{
+			name:      "unterminated string",
+			input:     `f"a{b}`,
+			rules:     PythonFString,
+			wantError: ErrUnterminatedString,
+			wantPos:   0,
+			wantData:  `f"a{b}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.Interpolated(tc.rules, tokens, builder, expression)
+			// the state is re-entrant, so it gives the same result on retry
+			for range 2 {
+				receiver.Reset()
+				source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+				err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+				switch {
+				case tc.wantError == ErrRollback:
+					assert.ErrorIs(t, err, ErrRollback)
+					assert.Empty(t, receiver.Slice)
+				case tc.wantError != nil:
+					assert.ErrorIs(t, err, errStateBreak)
+					if assert.NotEmpty(t, receiver.Slice) {
+						msg := receiver.Slice[len(receiver.Slice)-1]
+						assert.ErrorIs(t, msg.AsError(), tc.wantError)
+						assert.Equal(t, tc.wantPos, msg.Pos)
+						assert.Equal(t, tc.wantData, string(msg.AsError().Value.([]byte)))
+					}
+				default:
+					assert.ErrorIs(t, err, ErrCommit)
+					got := make([]wantMessage, 0, len(receiver.Slice))
+					for _, msg := range receiver.Slice {
+						got = append(got, wantMessage{msg.Token, msg.AsString(), msg.Level})
+					}
+					assert.Equal(t, tc.want, got)
+				}
+			}
+		})
+	}
+}
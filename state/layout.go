@@ -0,0 +1,409 @@
+package state
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrMixedIndentation indicates that the indentation mixes tabs and spaces.
+	ErrMixedIndentation = errors.New("indentation mixes tabs and spaces")
+	// ErrInconsistentDedent indicates that the dedent does not match any outer indentation level.
+	ErrInconsistentDedent = errors.New("dedent does not match any outer indentation level")
+
+	// errLayoutLineEnd stops the line run at the line break.
+	errLayoutLineEnd = errors.New("line end")
+)
+
+// defaultTabWidth is the tab width used if the rules don't set it.
+const defaultTabWidth = 8
+
+type (
+	// LayoutRules describes the indentation sensitive layout.
+	LayoutRules[T any] struct {
+		// Newline is emitted at the end of each logical line.
+		Newline T
+		// Indent is emitted before the first token of the line indented deeper than the previous one.
+		Indent T
+		// Dedent is emitted for each indentation level closed by the line.
+		Dedent T
+		// TabWidth is the count of columns the tab advances to, the default is 8.
+		TabWidth int
+		// MixedError makes the indentation which mixes tabs and spaces an error.
+		MixedError bool
+		// Comment is the prefix of the comment lines, they are ignored like the blank lines.
+		Comment string
+		// Open and Close check the bracket tokens, the layout is suspended inside the brackets.
+		Open  func(token T) bool
+		Close func(token T) bool
+	}
+
+	// Layout is a state that lexes the input line by line by the sub state and emits
+	// the synthetic Newline, Indent and Dedent tokens.
+	Layout[T any] struct {
+		logger   common.Logger
+		rules    LayoutRules[T]
+		builder  Builder[T]
+		provider Provider[T]
+		run      *Run[T]
+		// stack holds the indentation levels of the enclosing blocks
+		stack []int
+		// depth is the nesting depth of the brackets of the current line
+		depth    int
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+
+	// layoutReceiver tracks the brackets emitted by the sub state.
+	layoutReceiver[T any] struct {
+		layout *Layout[T]
+	}
+)
+
+// Receive implements message.Receiver interface.
+func (lr layoutReceiver[T]) Receive(msgs []*message.Message[T]) error {
+	l := lr.layout
+	for _, msg := range msgs {
+		if msg.Type != message.Token {
+			continue
+		}
+		switch {
+		case l.rules.Open != nil && l.rules.Open(msg.Token):
+			l.depth++
+		case l.rules.Close != nil && l.rules.Close(msg.Token) && l.depth > 0:
+			l.depth--
+		}
+	}
+	return l.receiver.Receive(msgs)
+}
+
+// newLayout creates a new instance of the Layout state.
+func newLayout[T any](
+	logger common.Logger,
+	factory message.Factory[T],
+	rules LayoutRules[T],
+	builder Builder[T],
+	provider Provider[T],
+) *Layout[T] {
+	if rules.TabWidth <= 0 {
+		rules.TabWidth = defaultTabWidth
+	}
+	return &Layout[T]{
+		logger:   logger,
+		factory:  factory,
+		rules:    rules,
+		builder:  builder,
+		provider: provider,
+	}
+}
+
+// setReceiver sets the receiver of the state. The sub state messages are sent to the
+// same receiver through the brackets tracker.
+func (l *Layout[T]) setReceiver(receiver message.Receiver[T]) {
+	l.receiver = receiver
+	builder := l.builder
+	builder.receiver = layoutReceiver[T]{layout: l}
+	l.run = NewRun(l.logger, builder, l.provider, ErrInvalidInput)
+}
+
+// emit emits the synthetic token at the given position.
+func (l *Layout[T]) emit(ctx context.Context, token T, pos int64) (err error) {
+	level, ok := GetTokenLevel(ctx)
+	common.AssertTrue(ok, "no token level in context")
+	msg, err := l.factory.Token(ctx, level, token, []byte{}, int(pos), 0)
+	if err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	if err = l.receiver.Receive(AsSlice(msg)); err != nil {
+		err = MakeErrBreak(err)
+	}
+	return
+}
+
+// peekRune returns the next rune without advancing, ok is false at the end of input.
+func peekRune(tx xio.State) (r rune, ok bool, err error) {
+	r, rw, err := tx.NextRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	err = nil
+	if rw == 0 {
+		return
+	}
+	_, err = tx.Unread()
+	common.AssertNoError(err, "unread error")
+	ok = true
+	return
+}
+
+// lineBreak returns the width in runes of the line break ("\n" or "\r\n") at the current
+// position without advancing, it is zero if there is no line break.
+func lineBreak(tx xio.State) (width int, err error) {
+	r, ok, err := peekRune(tx)
+	if err != nil || !ok {
+		return
+	}
+	switch r {
+	case '\n':
+		width = 1
+	case '\r':
+		var crlf bool
+		if crlf, err = lookahead(tx, "\r\n", false); err == nil && crlf {
+			width = 2
+		}
+	}
+	return
+}
+
+// skipRunes advances the tx by count runes.
+func skipRunes(tx xio.State, count int) {
+	for range count {
+		_, _, err := tx.NextRune()
+		common.AssertNoError(err, "next rune error")
+	}
+}
+
+// indentation reads the leading whitespace and returns its width in columns.
+func (l *Layout[T]) indentation(ctx context.Context, tx xio.State) (width int, err error) {
+	start := xio.AsOffset(tx).Offset()
+	tabs, spaces := false, false
+	for {
+		r, ok, peekErr := peekRune(tx)
+		if peekErr != nil {
+			err = peekErr
+			return
+		}
+		if !ok || r != ' ' && r != '\t' {
+			break
+		}
+		_, _, err = tx.NextRune()
+		common.AssertNoError(err, "next rune error")
+		if r == '\t' {
+			tabs = true
+			width += l.rules.TabWidth - width%l.rules.TabWidth
+		} else {
+			spaces = true
+			width++
+		}
+	}
+	if l.rules.MixedError && tabs && spaces {
+		data, pos, pendingErr := xio.AsPending(tx).Pending()
+		common.AssertNoError(pendingErr, "pending data error")
+		err = emitErrorAt(ctx, l.factory, l.receiver, ErrMixedIndentation, data[start-pos:], int(start))
+	}
+	return
+}
+
+// indent emits the Indent or the Dedent tokens for the line indented by width.
+// It returns the new indentation stack.
+func (l *Layout[T]) indent(ctx context.Context, tx xio.State, stack []int, width int) (ret []int, err error) {
+	ret = stack
+	pos := xio.AsOffset(tx).Offset()
+	top := 0
+	if len(ret) > 0 {
+		top = ret[len(ret)-1]
+	}
+	if width > top {
+		ret = append(ret, width)
+		err = l.emit(ctx, l.rules.Indent, pos)
+		return
+	}
+	for len(ret) > 0 && ret[len(ret)-1] > width {
+		ret = ret[:len(ret)-1]
+		if err = l.emit(ctx, l.rules.Dedent, pos); err != nil {
+			return
+		}
+	}
+	if len(ret) > 0 && ret[len(ret)-1] != width || len(ret) == 0 && width != 0 {
+		err = emitErrorAt(ctx, l.factory, l.receiver, ErrInconsistentDedent, []byte{}, int(pos))
+	}
+	return
+}
+
+// line lexes the line content by the sub state until the line break outside of the brackets.
+// It returns true if the line break is reached.
+func (l *Layout[T]) line(ctx context.Context, tx xio.State) (lineEnd bool, err error) {
+	l.run.WithCommitHook(func(context.Context) (hookErr error) {
+		width, hookErr := lineBreak(tx)
+		if hookErr == nil && width > 0 {
+			hookErr = errLayoutLineEnd
+		}
+		return
+	})
+	// the layout is transparent, so the sub state tokens keep the current level
+	level, _ := GetTokenLevel(ctx)
+	for {
+		_, ok, peekErr := peekRune(tx)
+		if peekErr != nil {
+			err = peekErr
+			return
+		}
+		if !ok {
+			return
+		}
+		width, breakErr := lineBreak(tx)
+		switch {
+		case breakErr != nil:
+			err = breakErr
+			return
+		case width > 0 && l.depth == 0:
+			lineEnd = true
+			return
+		case width > 0:
+			// the line continues inside the brackets
+			skipRunes(tx, width)
+			continue
+		}
+		err = l.run.Run(withTokenLevel(ctx, level-1), xio.AsSource(tx))
+		l.run.Reset()
+		switch {
+		case errors.Is(err, errLayoutLineEnd), errors.Is(err, ErrCommit):
+			err = nil
+		case errors.Is(err, ErrIncomplete):
+			var width int
+			if width, err = lineBreak(tx); err != nil || width == 0 {
+				// the sub state can't lex the rest of the line
+				err = ErrIncomplete
+				return
+			}
+		case errors.Is(err, ErrInvalidInput):
+			err = nil
+			return
+		default:
+			err = MakeErrBreak(err)
+			return
+		}
+	}
+}
+
+// Update implements the Update interface. It lexes one logical line: emits the indentation
+// tokens, the line content tokens and the Newline token. The blank and the comment lines
+// don't affect the indentation. At the end of input the Dedent tokens are emitted for all
+// open blocks.
+func (l *Layout[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(l.receiver, "receiver is not set")
+	offset := xio.AsOffset(tx)
+	if offset.Offset() == xio.AsPosition(tx).Origin() {
+		// the new input, e.g. after the lexer reset or the followed file restart
+		l.stack = l.stack[:0]
+	}
+	stack := l.stack
+	l.depth = 0
+	width, err := l.indentation(ctx, tx)
+	if err != nil {
+		return
+	}
+	_, ok, err := peekRune(tx)
+	if err != nil {
+		return
+	}
+	if !ok {
+		// the end of input closes all blocks
+		if len(stack) == 0 {
+			err = ErrRollback
+			return
+		}
+		if err = l.emitDedents(ctx, offset.Offset(), len(stack)); err != nil {
+			return
+		}
+		l.stack = stack[:0]
+		err = ErrCommit
+		return
+	}
+	lineBreakWidth, err := lineBreak(tx)
+	if err != nil {
+		return
+	}
+	blank := lineBreakWidth > 0
+	if !blank && l.rules.Comment != "" {
+		if blank, err = lookahead(tx, l.rules.Comment, false); err != nil {
+			return
+		}
+	}
+	if !blank {
+		if stack, err = l.indent(ctx, tx, append([]int(nil), stack...), width); err != nil {
+			return
+		}
+	}
+	lineEnd, err := l.line(ctx, tx)
+	if err != nil {
+		return
+	}
+	if !blank {
+		if err = l.emit(ctx, l.rules.Newline, offset.Offset()); err != nil {
+			return
+		}
+	}
+	if lineEnd {
+		lineBreakWidth, err = lineBreak(tx)
+		common.AssertNoError(err, "line break error")
+		skipRunes(tx, lineBreakWidth)
+	}
+	l.stack = stack
+	err = ErrCommit
+	return
+}
+
+// Snapshot implements Snapshotter interface. It returns the indentation levels of the open blocks.
+func (l *Layout[T]) Snapshot() (data []byte, err error) {
+	for _, level := range l.stack {
+		data = binary.AppendUvarint(data, uint64(level))
+	}
+	return
+}
+
+// Restore implements Snapshotter interface. It restores the indentation levels of the open blocks.
+func (l *Layout[T]) Restore(data []byte) (err error) {
+	stack := []int{}
+	for len(data) > 0 {
+		level, n := binary.Uvarint(data)
+		if n <= 0 {
+			err = fmt.Errorf("%w: invalid layout levels", ErrSnapshotMismatch)
+			return
+		}
+		stack = append(stack, int(level))
+		data = data[n:]
+	}
+	l.stack = stack
+	return
+}
+
+// emitDedents emits count Dedent tokens at the given position.
+func (l *Layout[T]) emitDedents(ctx context.Context, pos int64, count int) (err error) {
+	for range count {
+		if err = l.emit(ctx, l.rules.Dedent, pos); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Layout adds a state that lexes the input line by line by the sub state and emits the
+// synthetic Newline, Indent and Dedent tokens with zero width, like the Python or the YAML
+// lexers. The line break is "\n" or "\r\n", the sub state must not consume it. The blank
+// and the comment lines don't affect the indentation, and the layout is suspended inside
+// the brackets. The state keeps the indentation levels between the lines, so it must be
+// the top level state. The levels are reset at the input start, they are saved by the
+// lexer checkpoint.
+func (b Builder[T]) Layout(rules LayoutRules[T], builder Builder[T], provider Provider[T]) (tail *Chain[T]) {
+	common.AssertNotNil(provider, "invalid grammar: nil provider")
+	newNode := newLayout(b.logger, b.factory, rules, builder, provider)
+	tail = b.append("Layout", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isLayout returns true if the state is Layout.
+func isLayout[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Layout[T])
+	return
+}
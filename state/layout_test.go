@@ -0,0 +1,231 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"unicode"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayout(t *testing.T) {
+	type wantMessage struct {
+		token Token
+		value string
+		pos   int
+		width int
+	}
+
+	type testCase struct {
+		name      string
+		input     string
+		rules     LayoutRules[Token]
+		want      []wantMessage
+		wantError error
+		wantPos   int
+	}
+
+	rules := LayoutRules[Token]{
+		Newline: Token1,
+		Indent:  Token2,
+		Dedent:  Token3,
+		Comment: "#",
+		Open:    func(token Token) bool { return token == Token5 },
+		Close:   func(token Token) bool { return token == Token6 },
+	}
+	mixed := rules
+	mixed.MixedError = true
+	tabs := rules
+	tabs.TabWidth = 4
+
+	grammar := func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.Named("Spaces").WhileRune(IsRune(' ')).Omit(),
+			b.Named("Comment").Rune('#').UntilRune(Or(IsRune('\n'), IsRune('\r'))).Omit(),
+			b.Named("Word").WhileRune(unicode.IsLetter).Emit(Token4),
+			b.Named("Open").Rune('(').Emit(Token5),
+			b.Named("Close").Rune(')').Emit(Token6),
+		)
+	}
+
+	tests := []testCase{
+		{
+			name:  "flat lines",
+			input: "a b\nc",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token4, "b", 2, 1},
+				{Token1, "", 3, 0},
+				{Token4, "c", 4, 1},
+				{Token1, "", 5, 0},
+			},
+		},
+		{
+			name:  "indent and dedent",
+			input: "a\n  b\n    c\nd\n",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token1, "", 1, 0},
+				{Token2, "", 4, 0},
+				{Token4, "b", 4, 1},
+				{Token1, "", 5, 0},
+				{Token2, "", 10, 0},
+				{Token4, "c", 10, 1},
+				{Token1, "", 11, 0},
+				{Token3, "", 12, 0},
+				{Token3, "", 12, 0},
+				{Token4, "d", 12, 1},
+				{Token1, "", 13, 0},
+			},
+		},
+		{
+			name:  "dedent at the end of input",
+			input: "a\n  b",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token1, "", 1, 0},
+				{Token2, "", 4, 0},
+				{Token4, "b", 4, 1},
+				{Token1, "", 5, 0},
+				{Token3, "", 5, 0},
+			},
+		},
+		{
+			name:  "blank and comment lines",
+			input: "a\n\n      \n  # comment\n  b\n",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token1, "", 1, 0},
+				{Token2, "", 24, 0},
+				{Token4, "b", 24, 1},
+				{Token1, "", 25, 0},
+				{Token3, "", 26, 0},
+			},
+		},
+		{
+			name:  "brackets suspend layout",
+			input: "a (b\n      c\n)\nd",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token5, "(", 2, 1},
+				{Token4, "b", 3, 1},
+				{Token4, "c", 11, 1},
+				{Token6, ")", 13, 1},
+				{Token1, "", 14, 0},
+				{Token4, "d", 15, 1},
+				{Token1, "", 16, 0},
+			},
+		},
+		{
+			name:  "tab width",
+			input: "a\n\tb\n    c",
+			rules: tabs,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token1, "", 1, 0},
+				{Token2, "", 3, 0},
+				{Token4, "b", 3, 1},
+				{Token1, "", 4, 0},
+				{Token4, "c", 9, 1},
+				{Token1, "", 10, 0},
+				{Token3, "", 10, 0},
+			},
+		},
+		{
+			name:  "crlf line breaks",
+			input: "a\r\n  b # c\r\n\r\nd\r\n",
+			rules: rules,
+			want: []wantMessage{
+				{Token4, "a", 0, 1},
+				{Token1, "", 1, 0},
+				{Token2, "", 5, 0},
+				{Token4, "b", 5, 1},
+				{Token1, "", 10, 0},
+				{Token3, "", 14, 0},
+				{Token4, "d", 14, 1},
+				{Token1, "", 15, 0},
+			},
+		},
+		{
+			name:      "inconsistent dedent",
+			input:     "a\n    b\n  c",
+			rules:     rules,
+			wantError: ErrInconsistentDedent,
+			wantPos:   10,
+		},
+		{
+			name:      "mixed indentation",
+			input:     "a\n \tb",
+			rules:     mixed,
+			wantError: ErrMixedIndentation,
+			wantPos:   2,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.Layout(tc.rules, builder, grammar)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			run := NewRun(builder.logger, builder, func(Builder[Token]) []Update[Token] {
+				return AsSlice[Update[Token]](state)
+			}, nil)
+			err := run.Run(context.Background(), source)
+			if tc.wantError != nil {
+				assert.ErrorIs(t, err, tc.wantError)
+				if assert.NotEmpty(t, receiver.Slice) {
+					msg := receiver.Slice[len(receiver.Slice)-1]
+					assert.ErrorIs(t, msg.AsError(), tc.wantError)
+					assert.Equal(t, tc.wantPos, msg.Pos)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			got := make([]wantMessage, 0, len(receiver.Slice))
+			for _, msg := range receiver.Slice {
+				got = append(got, wantMessage{msg.Token, msg.AsString(), msg.Pos, msg.Width})
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestLayout_NewInput(t *testing.T) {
+	rules := LayoutRules[Token]{Newline: Token1, Indent: Token2, Dedent: Token3}
+	grammar := func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.Named("Word").WhileRune(unicode.IsLetter).Emit(Token4),
+		)
+	}
+	receiver := message.Slice[Token]()
+	builder := makeTestBuilder(receiver)
+	state := builder.Layout(rules, builder, grammar)
+	run := NewRun(builder.logger, builder, func(Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](state)
+	}, nil)
+
+	// the input is stopped inside the block
+	source := xio.New(builder.logger, bytes.NewBufferString("a\n  b\n  +"))
+	assert.ErrorIs(t, run.Run(context.Background(), source), ErrIncomplete)
+	run.Reset()
+
+	// the blocks of the previous input are not closed by the next one, even if it
+	// starts with the byte order mark
+	receiver.Reset()
+	source = xio.New(builder.logger, bytes.NewBufferString("\uFEFFa\n"), xio.WithBOMDetection())
+	assert.NoError(t, run.Run(context.Background(), source))
+	tokens := make([]Token, 0, len(receiver.Slice))
+	for _, msg := range receiver.Slice {
+		tokens = append(tokens, msg.Token)
+	}
+	assert.Equal(t, []Token{Token4, Token1}, tokens)
+}
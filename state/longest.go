@@ -0,0 +1,142 @@
+package state
+
+import (
+	"context"
+	"errors"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// Longest is a state that tries all alternatives side by side, each on its own
+	// snapshot of the input, and accepts the one which consumed the most input.
+	// If several alternatives consumed the same amount of input, the first one wins.
+	Longest[T any] struct {
+		logger       common.Logger
+		builder      Builder[T]
+		provider     Provider[T]
+		alternatives []Update[T]
+		// target receives the messages of the currently running alternative
+		target   *switchReceiver[T]
+		receiver message.Receiver[T]
+	}
+
+	// switchReceiver forwards messages to the switchable target receiver.
+	switchReceiver[T any] struct {
+		target message.Receiver[T]
+	}
+)
+
+// Receive implements message.Receiver interface.
+func (sr *switchReceiver[T]) Receive(msgs []*message.Message[T]) error {
+	return sr.target.Receive(msgs)
+}
+
+// newLongest creates a new instance of the Longest state.
+func newLongest[T any](logger common.Logger, builder Builder[T], provider Provider[T]) *Longest[T] {
+	return &Longest[T]{
+		logger:   logger,
+		builder:  builder,
+		provider: provider,
+		target:   &switchReceiver[T]{},
+	}
+}
+
+// setReceiver sets the receiver of the state. The alternatives send their messages
+// to the buffers, only the messages of the accepted alternative are forwarded.
+func (l *Longest[T]) setReceiver(receiver message.Receiver[T]) {
+	l.receiver = receiver
+}
+
+// getAlternatives returns the alternatives, they are created on first use.
+func (l *Longest[T]) getAlternatives() []Update[T] {
+	if l.alternatives == nil {
+		builder := l.builder
+		builder.receiver = l.target
+		l.alternatives = l.provider(builder)
+		common.AssertTrue(len(l.alternatives) > 0, "invalid grammar: no alternatives")
+	}
+	return l.alternatives
+}
+
+// isAccepted returns true if the alternative result means that the input was consumed.
+func isAccepted(err error) bool {
+	if errors.Is(err, ErrCommit) {
+		return true
+	}
+	action, ok := getBreakAction(err)
+	return ok && errors.Is(action, ErrCommit)
+}
+
+// Update implements the Update interface. It runs all alternatives and accepts the longest one.
+func (l *Longest[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(l.receiver, "receiver is not set")
+	alternatives := l.getAlternatives()
+	ctx = WithNextTokenLevel(ctx)
+	source := xio.AsSource(tx)
+	snapshots := make([]xio.State, len(alternatives))
+	buffers := make([]*message.SliceReceiver[T], len(alternatives))
+	best := -1
+	bestOffset := int64(-1)
+	for i, alternative := range alternatives {
+		snapshots[i] = source.Begin().Deref()
+		buffers[i] = message.Slice[T]()
+		l.target.target = buffers[i]
+		result := alternative.Update(ctx, snapshots[i])
+		if action, ok := getBreakAction(result); ok && (errors.Is(action, xio.ErrNeedMore) || errors.Is(action, xio.ErrLookaheadExceeded)) {
+			// the longest alternative can't be chosen until more data are available, or
+			// if the alternative reached the lookahead limit
+			best = -1
+			err = result
+			break
+		}
+		if !isAccepted(result) {
+			continue
+		}
+		if offset := xio.AsOffset(snapshots[i]).Offset(); offset > bestOffset {
+			best = i
+			bestOffset = offset
+		}
+	}
+	l.target.target = nil
+	for i, snapshot := range snapshots {
+		if snapshot != nil && i != best {
+			common.AssertNoError(xio.AsTx(snapshot).Rollback(), "rollback error")
+		}
+	}
+	if err != nil {
+		return
+	}
+	if best == -1 {
+		err = ErrRollback
+		return
+	}
+	common.AssertNoError(xio.AsTx(snapshots[best]).Commit(), "commit error")
+	if err = l.receiver.Receive(buffers[best].Slice); err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	err = ErrCommit
+	return
+}
+
+// Longest adds a state that tries all alternatives produced by the provider on the same
+// input and accepts the one which consumed the most input, like the longest match rule
+// of the traditional lexers. Ties are resolved in favor of the first alternative. Only
+// the messages of the accepted alternative are emitted.
+func (b Builder[T]) Longest(builder Builder[T], provider Provider[T]) (tail *Chain[T]) {
+	common.AssertNotNil(provider, "invalid grammar: nil provider")
+	newNode := newLongest(b.logger, builder, provider)
+	tail = b.append("Longest", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isLongest returns true if the state is Longest.
+func isLongest[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Longest[T])
+	return
+}
@@ -0,0 +1,120 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongest(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		state     func(b Builder[Token]) *Chain[Token]
+		wantToken Token
+		wantValue string
+		wantError error
+	}
+
+	tests := []testCase{
+		{
+			name:  "longest alternative wins",
+			input: "abc",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Longest(b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.String("a").Emit(Token1),
+						b.String("ab").Emit(Token2),
+					)
+				})
+			},
+			wantToken: Token2,
+			wantValue: "ab",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "first alternative wins the tie",
+			input: "ab",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Longest(b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.String("ab").Emit(Token1),
+						b.Rune('a').Rune('b').Emit(Token2),
+					)
+				})
+			},
+			wantToken: Token1,
+			wantValue: "ab",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "failed alternatives are ignored",
+			input: "ab",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Longest(b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.String("abc").Emit(Token1),
+						b.String("a").Emit(Token2),
+					)
+				})
+			},
+			wantToken: Token2,
+			wantValue: "a",
+			wantError: ErrCommit,
+		},
+		{
+			name:  "no alternatives match",
+			input: "xyz",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Longest(b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.String("a").Emit(Token1),
+						b.String("ab").Emit(Token2),
+					)
+				})
+			},
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantValue == "" {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantToken, receiver.Slice[0].Token)
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
+
+func TestLongest_LookaheadExceeded(t *testing.T) {
+	receiver := message.Slice[Token]()
+	builder := makeTestBuilder(receiver)
+	state := builder.Longest(builder, func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.String("a").Emit(Token1),
+			b.String("abcd").Emit(Token2),
+		)
+	})
+	source := xio.New(builder.logger, bytes.NewBufferString("abcd"), xio.WithMaxLookahead(2))
+	err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	// the shorter alternative doesn't win silently
+	action, ok := getBreakAction(err)
+	if assert.True(t, ok, "not a break: %v", err) {
+		assert.ErrorIs(t, action, xio.ErrLookaheadExceeded)
+	}
+	assert.Empty(t, receiver.Slice)
+}
@@ -0,0 +1,636 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrInvalidDigit indicates that the numeric literal contains a digit not allowed by its base.
+	ErrInvalidDigit = errors.New("invalid digit")
+	// ErrInvalidSeparator indicates that the digit separator does not separate successive digits.
+	ErrInvalidSeparator = errors.New("invalid digit separator")
+	// ErrMissingDigits indicates that the numeric literal or its exponent has no digits.
+	ErrMissingDigits = errors.New("missing digits")
+	// ErrMissingExponent indicates that the hexadecimal float has no 'p' exponent.
+	ErrMissingExponent = errors.New("missing exponent")
+	// ErrInvalidSuffix indicates that the numeric literal is followed by an unknown suffix.
+	ErrInvalidSuffix = errors.New("invalid suffix")
+	// ErrNumberRange indicates that the numeric literal value is out of range.
+	ErrNumberRange = errors.New("number out of range")
+)
+
+type (
+	// NumberRules describes the numeric literal syntax.
+	NumberRules struct {
+		// Prefixes maps the radix prefixes to the bases, e.g. "0x" to 16. Prefixes are case insensitive.
+		Prefixes map[string]int
+		// LeadingZeroOctal makes the integers with the leading zero octal, e.g. 017.
+		LeadingZeroOctal bool
+		// Separator is the digit separator, e.g. '_'. Zero means no separator.
+		Separator rune
+		// Fraction enables the decimal point.
+		Fraction bool
+		// Exponent enables the decimal 'e' exponent.
+		Exponent bool
+		// HexFloat enables the hexadecimal floats with the 'p' exponent, e.g. 0x1.8p3.
+		HexFloat bool
+		// Suffixes are the allowed type suffixes, e.g. "u", "L" or "f32".
+		Suffixes []string
+		// FoldSuffixes makes the suffixes case insensitive.
+		FoldSuffixes bool
+		// Imaginary are the imaginary suffixes, e.g. "i" or "j".
+		Imaginary []string
+		// Sign allows the leading '+' or '-'.
+		Sign bool
+	}
+
+	// NumberValue is the decoded numeric literal.
+	NumberValue struct {
+		// Value is int64, uint64 if the integer doesn't fit int64, float64 or complex128
+		// for the imaginary literals.
+		Value any
+		// Base is the base of the literal.
+		Base int
+		// Suffix is the type suffix as written in the literal.
+		Suffix string
+	}
+
+	// NumberLiteral is a state that matches a numeric literal by the given rules and
+	// decodes its value. The NumberValue is emitted as the message value.
+	NumberLiteral[T any] struct {
+		logger   common.Logger
+		rules    NumberRules
+		prefixes []string
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+
+	// numberScanner reads the numeric literal runes.
+	numberScanner[T any] struct {
+		ctx context.Context
+		nl  NumberLiteral[T]
+		tx  xio.State
+	}
+
+	// numberParts are the parts of the numeric literal without separators.
+	numberParts struct {
+		sign     string
+		base     int
+		integer  string
+		fraction string
+		exponent string
+		isFloat  bool
+	}
+)
+
+var (
+	// GoNumber is the Go numeric literal.
+	GoNumber = NumberRules{
+		Prefixes:         map[string]int{"0b": 2, "0o": 8, "0x": 16},
+		LeadingZeroOctal: true,
+		Separator:        '_',
+		Fraction:         true,
+		Exponent:         true,
+		HexFloat:         true,
+		Imaginary:        []string{"i"},
+	}
+
+	// CNumber is the C numeric literal.
+	CNumber = NumberRules{
+		Prefixes:         map[string]int{"0b": 2, "0x": 16},
+		LeadingZeroOctal: true,
+		Fraction:         true,
+		Exponent:         true,
+		HexFloat:         true,
+		Suffixes:         []string{"u", "l", "ul", "lu", "ll", "ull", "llu", "f"},
+		FoldSuffixes:     true,
+	}
+
+	// PythonNumber is the Python numeric literal.
+	PythonNumber = NumberRules{
+		Prefixes:  map[string]int{"0b": 2, "0o": 8, "0x": 16},
+		Separator: '_',
+		Fraction:  true,
+		Exponent:  true,
+		Imaginary: []string{"j", "J"},
+	}
+
+	// RustNumber is the Rust numeric literal.
+	RustNumber = NumberRules{
+		Prefixes:  map[string]int{"0b": 2, "0o": 8, "0x": 16},
+		Separator: '_',
+		Fraction:  true,
+		Exponent:  true,
+		Suffixes: []string{
+			"i8", "i16", "i32", "i64", "i128", "isize",
+			"u8", "u16", "u32", "u64", "u128", "usize",
+			"f32", "f64",
+		},
+	}
+)
+
+// WithSign returns a copy of the rules that allows the leading sign.
+func (nr NumberRules) WithSign() NumberRules {
+	nr.Sign = true
+	return nr
+}
+
+// baseName returns the name of the base used in the error messages.
+func baseName(base int) string {
+	switch base {
+	case 2:
+		return "binary"
+	case 8:
+		return "octal"
+	case 16:
+		return "hexadecimal"
+	default:
+		return "decimal"
+	}
+}
+
+// digitValue returns the value of the digit in bases up to 36.
+func digitValue(r rune) (value int, ok bool) {
+	switch {
+	case '0' <= r && r <= '9':
+		value, ok = int(r-'0'), true
+	case 'a' <= r && r <= 'z':
+		value, ok = int(r-'a'+10), true
+	case 'A' <= r && r <= 'Z':
+		value, ok = int(r-'A'+10), true
+	}
+	return
+}
+
+// isDecimalDigit returns true if the rune is an ASCII decimal digit.
+func isDecimalDigit(r rune) bool {
+	return '0' <= r && r <= '9'
+}
+
+// newNumberLiteral creates a new instance of the NumberLiteral state.
+func newNumberLiteral[T any](logger common.Logger, factory message.Factory[T], rules NumberRules) *NumberLiteral[T] {
+	prefixes := make([]string, 0, len(rules.Prefixes))
+	for prefix := range rules.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	// the longest prefix first
+	slices.SortFunc(prefixes, func(a, b string) int { return len(b) - len(a) })
+	return &NumberLiteral[T]{
+		logger:   logger,
+		rules:    rules,
+		prefixes: prefixes,
+		factory:  factory,
+	}
+}
+
+// setReceiver sets the receiver of the state.
+func (nl *NumberLiteral[T]) setReceiver(receiver message.Receiver[T]) {
+	nl.receiver = receiver
+}
+
+// offset returns the current position.
+func (s numberScanner[T]) offset() int64 {
+	return xio.AsOffset(s.tx).Offset()
+}
+
+// peek returns the next rune without advancing.
+func (s numberScanner[T]) peek() (r rune, ok bool, err error) {
+	r, rw, err := s.tx.NextRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	err = nil
+	if rw == 0 {
+		return
+	}
+	_, err = s.tx.Unread()
+	common.AssertNoError(err, "unread error")
+	ok = true
+	return
+}
+
+// peek2 returns the rune after the next one without advancing.
+func (s numberScanner[T]) peek2() (r rune, ok bool, err error) {
+	ahead := xio.AsSource(s.tx).Begin().Deref()
+	defer func() { common.AssertNoError(xio.AsTx(ahead).Rollback(), "rollback error") }()
+	for range 2 {
+		var rw int
+		r, rw, err = ahead.NextRune()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+		err = nil
+		if rw == 0 {
+			return
+		}
+	}
+	ok = true
+	return
+}
+
+// skip advances over the next rune.
+func (s numberScanner[T]) skip() {
+	_, rw, err := s.tx.NextRune()
+	common.AssertTrue(rw > 0 && (err == nil || errors.Is(err, io.EOF)), "next rune error")
+}
+
+// prefix matches the radix prefix case insensitively. It advances the tx only if the
+// prefix is matched.
+func (s numberScanner[T]) prefix(prefix string) (ok bool, err error) {
+	ahead := xio.AsSource(s.tx).Begin().Deref()
+	lookTx := xio.AsTx(ahead)
+	for _, want := range prefix {
+		r, rw, nextErr := ahead.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+		if rw == 0 || unicode.ToLower(r) != unicode.ToLower(want) {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+	}
+	common.AssertNoError(lookTx.Commit(), "commit error")
+	ok = true
+	return
+}
+
+// fail reports the error for the data between from and the current position.
+func (s numberScanner[T]) fail(from int64, userErr error) (err error) {
+	data, pos, err := xio.AsPending(s.tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	err = emitErrorAt(s.ctx, s.nl.factory, s.nl.receiver, userErr, data[from-pos:], int(from))
+	return
+}
+
+// digits reads the digits of the base with separators and returns them without separators.
+// The separator is allowed before the first digit if leadingSep is true. If octal is true,
+// the position of the first non octal digit is returned, otherwise it is -1.
+func (s numberScanner[T]) digits(base int, leadingSep bool, octal bool) (digits string, nonOctal int64, err error) {
+	var builder strings.Builder
+	nonOctal = -1
+	separator := s.nl.rules.Separator
+	lastSep := false
+	for {
+		offset := s.offset()
+		r, ok, peekErr := s.peek()
+		if peekErr != nil {
+			err = peekErr
+			return
+		}
+		if !ok {
+			break
+		}
+		if separator != 0 && r == separator {
+			if lastSep || (builder.Len() == 0 && !leadingSep) {
+				s.skip()
+				err = s.fail(offset, fmt.Errorf("%w: '%c' must separate successive digits", ErrInvalidSeparator, r))
+				return
+			}
+			s.skip()
+			lastSep = true
+			continue
+		}
+		value, isDigit := digitValue(r)
+		if !isDigit || value >= base {
+			if isDecimalDigit(r) && base < 10 {
+				s.skip()
+				err = s.fail(offset, fmt.Errorf("%w '%c' in %s literal", ErrInvalidDigit, r, baseName(base)))
+				return
+			}
+			break
+		}
+		if octal && nonOctal == -1 && value >= 8 {
+			nonOctal = offset
+		}
+		s.skip()
+		builder.WriteRune(r)
+		lastSep = false
+	}
+	if lastSep {
+		err = s.fail(s.offset()-int64(len(string(separator))), fmt.Errorf("%w: '%c' must separate successive digits", ErrInvalidSeparator, separator))
+		return
+	}
+	digits = builder.String()
+	return
+}
+
+// isFractionPoint checks if the next rune is the decimal point of the number in the given base.
+func (s numberScanner[T]) isFractionPoint(base int) (ret bool, err error) {
+	r, ok, err := s.peek()
+	if err != nil || !ok || r != '.' {
+		return
+	}
+	next, ok, err := s.peek2()
+	if err != nil {
+		return
+	}
+	if !ok {
+		ret = true
+		return
+	}
+	value, isDigit := digitValue(next)
+	switch {
+	case isDigit && value < base:
+		ret = true
+	case s.exponentRune(base, next):
+		ret = true
+	default:
+		// e.g. method call or range operator
+		ret = !unicode.IsLetter(next) && next != '_' && next != '.'
+	}
+	return
+}
+
+// exponentRune checks if the rune starts the exponent of the number in the given base.
+func (s numberScanner[T]) exponentRune(base int, r rune) bool {
+	switch {
+	case base == 10 && s.nl.rules.Exponent:
+		return r == 'e' || r == 'E'
+	case base == 16 && s.nl.rules.HexFloat:
+		return r == 'p' || r == 'P'
+	default:
+		return false
+	}
+}
+
+// tail reads the identifier runes following the number.
+func (s numberScanner[T]) tail() (tail string, err error) {
+	var builder strings.Builder
+	for {
+		r, ok, peekErr := s.peek()
+		if peekErr != nil {
+			err = peekErr
+			return
+		}
+		if !ok || !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_') {
+			break
+		}
+		s.skip()
+		builder.WriteRune(r)
+	}
+	tail = builder.String()
+	return
+}
+
+// scan reads the numeric literal parts.
+func (s numberScanner[T]) scan(start int64) (parts numberParts, ok bool, err error) {
+	parts.base = 10
+	r, has, err := s.peek()
+	if err != nil || !has {
+		return
+	}
+	if s.nl.rules.Sign && (r == '+' || r == '-') {
+		s.skip()
+		parts.sign = string(r)
+		if r, has, err = s.peek(); err != nil || !has {
+			return
+		}
+	}
+	switch {
+	case isDecimalDigit(r):
+	case r == '.' && s.nl.rules.Fraction:
+		var next rune
+		if next, has, err = s.peek2(); err != nil || !has || !isDecimalDigit(next) {
+			return
+		}
+	default:
+		return
+	}
+	ok = true
+	prefixed := false
+	if r == '0' {
+		for _, prefix := range s.nl.prefixes {
+			if prefixed, err = s.prefix(prefix); err != nil {
+				return
+			}
+			if prefixed {
+				parts.base = s.nl.rules.Prefixes[prefix]
+				break
+			}
+		}
+	}
+	legacyOctal := !prefixed && r == '0' && s.nl.rules.LeadingZeroOctal
+	integer, nonOctal, err := s.digits(parts.base, prefixed, legacyOctal)
+	if err != nil {
+		return
+	}
+	parts.integer = integer
+	if s.nl.rules.Fraction && (parts.base == 10 || parts.base == 16 && s.nl.rules.HexFloat) {
+		var point bool
+		if point, err = s.isFractionPoint(parts.base); err != nil {
+			return
+		}
+		if point {
+			s.skip()
+			parts.isFloat = true
+			if parts.fraction, _, err = s.digits(parts.base, false, false); err != nil {
+				return
+			}
+		}
+	}
+	if prefixed && parts.integer == "" && parts.fraction == "" {
+		err = s.fail(start, fmt.Errorf("%w: %s literal has no digits", ErrMissingDigits, baseName(parts.base)))
+		return
+	}
+	if err = s.scanExponent(&parts, start); err != nil {
+		return
+	}
+	if legacyOctal && !parts.isFloat && len(parts.integer) > 1 {
+		parts.base = 8
+		if nonOctal >= 0 {
+			err = s.failAt(nonOctal, func(digit byte) error {
+				return fmt.Errorf("%w '%c' in octal literal", ErrInvalidDigit, digit)
+			})
+			return
+		}
+	}
+	return
+}
+
+// failAt reports the error made by userErr for the single byte at the given position.
+func (s numberScanner[T]) failAt(at int64, userErr func(b byte) error) (err error) {
+	data, pos, err := xio.AsPending(s.tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	b := data[at-pos : at-pos+1]
+	err = emitErrorAt(s.ctx, s.nl.factory, s.nl.receiver, userErr(b[0]), b, int(at))
+	return
+}
+
+// scanExponent reads the exponent.
+func (s numberScanner[T]) scanExponent(parts *numberParts, start int64) (err error) {
+	from := s.offset()
+	r, ok, err := s.peek()
+	if err != nil {
+		return
+	}
+	if !ok || !s.exponentRune(parts.base, r) {
+		if parts.base == 16 && parts.isFloat {
+			err = s.fail(start, fmt.Errorf("%w: hexadecimal mantissa requires a 'p' exponent", ErrMissingExponent))
+		}
+		return
+	}
+	s.skip()
+	parts.isFloat = true
+	sign := ""
+	if r, ok, err = s.peek(); err != nil {
+		return
+	}
+	if ok && (r == '+' || r == '-') {
+		s.skip()
+		sign = string(r)
+	}
+	digits, _, err := s.digits(10, false, false)
+	if err != nil {
+		return
+	}
+	if digits == "" {
+		err = s.fail(from, fmt.Errorf("%w: exponent has no digits", ErrMissingDigits))
+		return
+	}
+	parts.exponent = sign + digits
+	return
+}
+
+// value converts the parts to the value.
+func (parts numberParts) value() (value any, err error) {
+	if !parts.isFloat {
+		if value, err = strconv.ParseInt(parts.sign+parts.integer, parts.base, 64); err == nil {
+			return
+		}
+		if parts.sign != "-" {
+			value, err = strconv.ParseUint(parts.integer, parts.base, 64)
+		}
+		return
+	}
+	var text strings.Builder
+	text.WriteString(parts.sign)
+	if parts.base == 16 {
+		text.WriteString("0x")
+	}
+	text.WriteString(parts.integer)
+	if parts.integer == "" {
+		text.WriteString("0")
+	}
+	if parts.fraction != "" {
+		text.WriteString(".")
+		text.WriteString(parts.fraction)
+	}
+	if parts.exponent != "" {
+		if parts.base == 16 {
+			text.WriteString("p")
+		} else {
+			text.WriteString("e")
+		}
+		text.WriteString(parts.exponent)
+	}
+	value, err = strconv.ParseFloat(text.String(), 64)
+	return
+}
+
+// suffix checks the tail of the numeric literal.
+func (nl NumberLiteral[T]) suffix(tail string) (suffix string, imaginary bool, ok bool) {
+	if tail == "" {
+		ok = true
+		return
+	}
+	if slices.Contains(nl.rules.Imaginary, tail) {
+		imaginary, ok = true, true
+		return
+	}
+	for _, allowed := range nl.rules.Suffixes {
+		if tail == allowed || nl.rules.FoldSuffixes && strings.EqualFold(tail, allowed) {
+			suffix, ok = tail, true
+			return
+		}
+	}
+	return
+}
+
+// Update implements the Update interface. It matches the numeric literal and stores
+// the decoded NumberValue to be emitted.
+func (nl NumberLiteral[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(nl.receiver, "receiver is not set")
+	sub := xio.AsSource(tx).Begin().Deref()
+	subTx := xio.AsTx(sub)
+	value, ok, err := nl.decode(ctx, sub)
+	if err != nil || !ok {
+		common.AssertNoError(subTx.Rollback(), "rollback error")
+		if err == nil {
+			err = ErrRollback
+		}
+		return
+	}
+	common.AssertNoError(subTx.Commit(), "commit error")
+	setValue(ctx, value)
+	err = ErrChainNext
+	return
+}
+
+// decode reads and decodes the numeric literal.
+func (nl NumberLiteral[T]) decode(ctx context.Context, tx xio.State) (ret NumberValue, ok bool, err error) {
+	s := numberScanner[T]{ctx: ctx, nl: nl, tx: tx}
+	start := s.offset()
+	parts, ok, err := s.scan(start)
+	if err != nil || !ok {
+		return
+	}
+	tailStart := s.offset()
+	tail, err := s.tail()
+	if err != nil {
+		return
+	}
+	suffix, imaginary, valid := nl.suffix(tail)
+	if !valid {
+		err = s.fail(tailStart, fmt.Errorf("%w '%s' on %s literal", ErrInvalidSuffix, tail, baseName(parts.base)))
+		return
+	}
+	value, convErr := parts.value()
+	if convErr != nil {
+		err = s.fail(start, fmt.Errorf("%w: %s literal", ErrNumberRange, baseName(parts.base)))
+		return
+	}
+	if imaginary {
+		var imag float64
+		switch v := value.(type) {
+		case int64:
+			imag = float64(v)
+		case uint64:
+			imag = float64(v)
+		case float64:
+			imag = v
+		}
+		value = complex(0, imag)
+	}
+	ret = NumberValue{Value: value, Base: parts.base, Suffix: suffix}
+	return
+}
+
+// NumberLiteral adds a state that matches the numeric literal by the given rules. The decoded
+// NumberValue is emitted as the message value. Malformed literals are reported as error
+// messages pointing at the offending part.
+func (b Builder[T]) NumberLiteral(rules NumberRules) (tail *Chain[T]) {
+	newNode := newNumberLiteral(b.logger, b.factory, rules)
+	tail = b.append("NumberLiteral", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isNumberLiteral returns true if the state is NumberLiteral.
+func isNumberLiteral[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*NumberLiteral[T])
+	return
+}
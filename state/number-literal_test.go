@@ -0,0 +1,296 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberLiteral(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      string
+		rules      NumberRules
+		wantValue  any
+		wantBase   int
+		wantSuffix string
+		wantWidth  int
+		wantError  error
+		wantText   string
+		wantPos    int
+		wantData   string
+		wantNone   bool
+	}
+
+	tests := []testCase{
+		{
+			name:      "decimal",
+			input:     "1_000_000 tail",
+			rules:     GoNumber,
+			wantValue: int64(1000000),
+			wantBase:  10,
+			wantWidth: 9,
+		},
+		{
+			name:      "binary",
+			input:     "0b_1010",
+			rules:     GoNumber,
+			wantValue: int64(10),
+			wantBase:  2,
+			wantWidth: 7,
+		},
+		{
+			name:      "octal",
+			input:     "0O17",
+			rules:     GoNumber,
+			wantValue: int64(15),
+			wantBase:  8,
+			wantWidth: 4,
+		},
+		{
+			name:      "legacy octal",
+			input:     "017",
+			rules:     GoNumber,
+			wantValue: int64(15),
+			wantBase:  8,
+			wantWidth: 3,
+		},
+		{
+			name:      "hexadecimal",
+			input:     "0xFF",
+			rules:     GoNumber,
+			wantValue: int64(255),
+			wantBase:  16,
+			wantWidth: 4,
+		},
+		{
+			name:      "unsigned",
+			input:     "18446744073709551615",
+			rules:     GoNumber,
+			wantValue: uint64(18446744073709551615),
+			wantBase:  10,
+			wantWidth: 20,
+		},
+		{
+			name:      "float",
+			input:     "1.5e3",
+			rules:     GoNumber,
+			wantValue: float64(1500),
+			wantBase:  10,
+			wantWidth: 5,
+		},
+		{
+			name:      "leading point",
+			input:     ".25",
+			rules:     GoNumber,
+			wantValue: float64(0.25),
+			wantBase:  10,
+			wantWidth: 3,
+		},
+		{
+			name:      "float with leading zero",
+			input:     "09.5",
+			rules:     CNumber,
+			wantValue: float64(9.5),
+			wantBase:  10,
+			wantWidth: 4,
+		},
+		{
+			name:      "hexadecimal float",
+			input:     "0x1.8p3",
+			rules:     GoNumber,
+			wantValue: float64(12),
+			wantBase:  16,
+			wantWidth: 7,
+		},
+		{
+			name:      "imaginary",
+			input:     "2.5i",
+			rules:     GoNumber,
+			wantValue: complex(0, 2.5),
+			wantBase:  10,
+			wantWidth: 4,
+		},
+		{
+			name:      "python imaginary",
+			input:     "3J",
+			rules:     PythonNumber,
+			wantValue: complex(0, 3),
+			wantBase:  10,
+			wantWidth: 2,
+		},
+		{
+			name:       "c suffix",
+			input:      "10UL",
+			rules:      CNumber,
+			wantValue:  int64(10),
+			wantBase:   10,
+			wantSuffix: "UL",
+			wantWidth:  4,
+		},
+		{
+			name:       "rust suffix",
+			input:      "1.5f32",
+			rules:      RustNumber,
+			wantValue:  float64(1.5),
+			wantBase:   10,
+			wantSuffix: "f32",
+			wantWidth:  6,
+		},
+		{
+			name:      "rust range",
+			input:     "1..2",
+			rules:     RustNumber,
+			wantValue: int64(1),
+			wantBase:  10,
+			wantWidth: 1,
+		},
+		{
+			name:      "signed",
+			input:     "-42",
+			rules:     GoNumber.WithSign(),
+			wantValue: int64(-42),
+			wantBase:  10,
+			wantWidth: 3,
+		},
+		{
+			name:     "not a number",
+			input:    "abc",
+			rules:    GoNumber,
+			wantNone: true,
+		},
+		{
+			name:     "sign is not allowed",
+			input:    "-1",
+			rules:    GoNumber,
+			wantNone: true,
+		},
+		{
+			name:      "invalid octal digit",
+			input:     "0o179",
+			rules:     GoNumber,
+			wantError: ErrInvalidDigit,
+			wantText:  "invalid digit '9' in octal literal",
+			wantPos:   4,
+			wantData:  "9",
+		},
+		{
+			name:      "invalid legacy octal digit",
+			input:     "01_89",
+			rules:     GoNumber,
+			wantError: ErrInvalidDigit,
+			wantText:  "invalid digit '8' in octal literal",
+			wantPos:   3,
+			wantData:  "8",
+		},
+		{
+			name:      "invalid binary digit",
+			input:     "0b102",
+			rules:     GoNumber,
+			wantError: ErrInvalidDigit,
+			wantText:  "invalid digit '2' in binary literal",
+			wantPos:   4,
+			wantData:  "2",
+		},
+		{
+			name:      "double separator",
+			input:     "1__0",
+			rules:     GoNumber,
+			wantError: ErrInvalidSeparator,
+			wantPos:   2,
+			wantData:  "_",
+		},
+		{
+			name:      "trailing separator",
+			input:     "10_ ",
+			rules:     GoNumber,
+			wantError: ErrInvalidSeparator,
+			wantPos:   2,
+			wantData:  "_",
+		},
+		{
+			name:      "no digits",
+			input:     "0x",
+			rules:     GoNumber,
+			wantError: ErrMissingDigits,
+			wantText:  "missing digits: hexadecimal literal has no digits",
+			wantPos:   0,
+			wantData:  "0x",
+		},
+		{
+			name:      "exponent without digits",
+			input:     "1e+",
+			rules:     GoNumber,
+			wantError: ErrMissingDigits,
+			wantPos:   1,
+			wantData:  "e+",
+		},
+		{
+			name:      "hexadecimal float without exponent",
+			input:     "0x1.8",
+			rules:     GoNumber,
+			wantError: ErrMissingExponent,
+			wantPos:   0,
+			wantData:  "0x1.8",
+		},
+		{
+			name:      "invalid suffix",
+			input:     "12abc",
+			rules:     GoNumber,
+			wantError: ErrInvalidSuffix,
+			wantText:  "invalid suffix 'abc' on decimal literal",
+			wantPos:   2,
+			wantData:  "abc",
+		},
+		{
+			name:      "out of range",
+			input:     "0x1_0000_0000_0000_0000",
+			rules:     GoNumber,
+			wantError: ErrNumberRange,
+			wantPos:   0,
+			wantData:  "0x1_0000_0000_0000_0000",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.NumberLiteral(tc.rules).Emit(Token1)
+			// the state is re-entrant, so it gives the same result on retry
+			for range 2 {
+				receiver.Reset()
+				source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+				err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+				if tc.wantNone {
+					assert.ErrorIs(t, err, ErrRollback)
+					assert.Empty(t, receiver.Slice)
+					continue
+				}
+				if tc.wantError != nil {
+					assert.ErrorIs(t, err, errStateBreak)
+					if assert.Len(t, receiver.Slice, 1) {
+						msg := receiver.Slice[0]
+						assert.ErrorIs(t, msg.AsError(), tc.wantError)
+						if tc.wantText != "" {
+							assert.EqualError(t, msg.AsError().Err, tc.wantText)
+						}
+						assert.Equal(t, tc.wantPos, msg.Pos)
+						assert.Equal(t, tc.wantData, string(msg.AsError().Value.([]byte)))
+					}
+					continue
+				}
+				assert.ErrorIs(t, err, ErrCommit)
+				if assert.Len(t, receiver.Slice, 1) {
+					msg := receiver.Slice[0]
+					assert.Equal(t, tc.wantWidth, msg.Width)
+					assert.Equal(t, NumberValue{Value: tc.wantValue, Base: tc.wantBase, Suffix: tc.wantSuffix}, msg.Value)
+				}
+			}
+		})
+	}
+}
@@ -6,6 +6,12 @@ type (
 
 	// BytePredicate is a function that takes byte and returns true if it should be accepted.
 	BytePredicate func(byte) bool
+
+	// GraphemePredicate is a function that takes extended grapheme cluster and returns true if it should be accepted.
+	GraphemePredicate func(string) bool
+
+	// BitsPredicate is a function that takes bit field value and returns true if it should be accepted.
+	BitsPredicate func(uint64) bool
 )
 
 // IsByte returns a function that checks if the given byte is equal to the sample.
@@ -22,6 +28,20 @@ func IsRune(sample rune) func(rune) bool {
 	}
 }
 
+// IsGrapheme returns a function that checks if the given grapheme cluster is equal to the sample.
+func IsGrapheme(sample string) func(string) bool {
+	return func(in string) bool {
+		return sample == in
+	}
+}
+
+// IsBits returns a function that checks if the given bit field value is equal to the sample.
+func IsBits(sample uint64) func(uint64) bool {
+	return func(in uint64) bool {
+		return sample == in
+	}
+}
+
 // True returns a function that always returns true.
 func True[T any]() func(T) bool {
 	return func(_ T) bool { return true }
@@ -0,0 +1,257 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrPrefixExceedsMax indicates that the declared length or count exceeds the configured maximum.
+	ErrPrefixExceedsMax = errors.New("declared length exceeds maximum")
+	// ErrPrefixExceedsInput indicates that the declared length or count exceeds the remaining input.
+	ErrPrefixExceedsInput = errors.New("declared length exceeds remaining input")
+	// ErrNegativePrefix indicates that the declared length or count is negative.
+	ErrNegativePrefix = errors.New("negative declared length")
+)
+
+// sizedChunkSize is the size of the chunk used to read the sized data.
+const sizedChunkSize = 4096
+
+type (
+	// Sized is a state that reads a length field and then consumes exactly that many bytes.
+	Sized[T any] struct {
+		logger   common.Logger
+		format   BinaryFormat
+		max      uint64
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+
+	// Counted is a state that reads a count field and then applies the sub state exactly
+	// that many times.
+	Counted[T any] struct {
+		logger   common.Logger
+		format   BinaryFormat
+		max      uint64
+		builder  Builder[T]
+		provider Provider[T]
+		state    *State[T]
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+)
+
+// toUint64 converts the decoded integer value to uint64.
+func toUint64(value any) (ret uint64, err error) {
+	var signed int64
+	switch v := value.(type) {
+	case uint8:
+		ret = uint64(v)
+		return
+	case uint16:
+		ret = uint64(v)
+		return
+	case uint32:
+		ret = uint64(v)
+		return
+	case uint64:
+		ret = v
+		return
+	case int8:
+		signed = int64(v)
+	case int16:
+		signed = int64(v)
+	case int32:
+		signed = int64(v)
+	case int64:
+		signed = v
+	default:
+		common.AssertUnreachable("invalid grammar: not an integer prefix: %T", value)
+	}
+	if signed < 0 {
+		err = fmt.Errorf("%w: %d", ErrNegativePrefix, signed)
+		return
+	}
+	ret = uint64(signed)
+	return
+}
+
+// readPrefix decodes the length or count field. It returns ok=false if the field can't be decoded.
+// The returned error is the user error to be reported.
+func readPrefix(tx xio.State, format BinaryFormat, max uint64) (n uint64, ok bool, userErr error) {
+	sub := xio.AsSource(tx).Begin().Deref()
+	subTx := xio.AsTx(sub)
+	value, ok, err := format.Decode(sub)
+	if err != nil || !ok {
+		common.AssertNoError(subTx.Rollback(), "rollback error")
+		ok = false
+		return
+	}
+	common.AssertNoError(subTx.Commit(), "commit error")
+	if n, userErr = toUint64(value); userErr != nil {
+		return
+	}
+	if max > 0 && n > max {
+		userErr = fmt.Errorf("%w: %d > %d", ErrPrefixExceedsMax, n, max)
+	}
+	return
+}
+
+// newSized creates a new instance of the Sized state.
+func newSized[T any](logger common.Logger, factory message.Factory[T], format BinaryFormat, max uint64) *Sized[T] {
+	return &Sized[T]{
+		logger:  logger,
+		factory: factory,
+		format:  format,
+		max:     max,
+	}
+}
+
+// setReceiver sets the receiver of the state.
+func (s *Sized[T]) setReceiver(receiver message.Receiver[T]) {
+	s.receiver = receiver
+}
+
+// Update implements the Update interface. It reads the length field and the data.
+// The data without the length field is stored as the value to be emitted.
+func (s Sized[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(s.receiver, "receiver is not set")
+	n, ok, userErr := readPrefix(tx, s.format, s.max)
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	if userErr != nil {
+		err = emitErrorMessage(ctx, s.factory, s.receiver, tx, userErr)
+		return
+	}
+	payload := make([]byte, 0, min(n, sizedChunkSize))
+	chunk := make([]byte, sizedChunkSize)
+	for remaining := n; remaining > 0; {
+		size := min(remaining, sizedChunkSize)
+		var read int
+		read, err = tx.Read(chunk[:size])
+		if err != nil && !errors.Is(err, io.EOF) {
+			return
+		}
+		payload = append(payload, chunk[:read]...)
+		remaining -= uint64(read)
+		if errors.Is(err, io.EOF) && remaining > 0 {
+			err = emitErrorMessage(ctx, s.factory, s.receiver, tx,
+				fmt.Errorf("%w: %d > %d", ErrPrefixExceedsInput, n, len(payload)))
+			return
+		}
+	}
+	setValue(ctx, payload)
+	err = ErrChainNext
+	return
+}
+
+// newCounted creates a new instance of the Counted state.
+func newCounted[T any](
+	logger common.Logger,
+	factory message.Factory[T],
+	format BinaryFormat,
+	max uint64,
+	builder Builder[T],
+	provider Provider[T],
+) *Counted[T] {
+	return &Counted[T]{
+		logger:   logger,
+		factory:  factory,
+		format:   format,
+		max:      max,
+		builder:  builder,
+		provider: provider,
+	}
+}
+
+// setReceiver sets the receiver of the state. The items messages are sent to the same
+// receiver, so they are forwarded only if the whole chain is committed.
+func (c *Counted[T]) setReceiver(receiver message.Receiver[T]) {
+	c.receiver = receiver
+	builder := c.builder
+	builder.receiver = receiver
+	c.state = newState(c.logger, builder, c.provider)
+}
+
+// Update implements the Update interface. It reads the count field and applies the sub
+// state count times. The count is stored as the value to be emitted.
+func (c Counted[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(c.receiver, "receiver is not set")
+	n, ok, userErr := readPrefix(tx, c.format, c.max)
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	if userErr != nil {
+		err = emitErrorMessage(ctx, c.factory, c.receiver, tx, userErr)
+		return
+	}
+	source := xio.AsSource(tx)
+	for i := uint64(0); i < n; i++ {
+		if !source.Has() {
+			err = emitErrorMessage(ctx, c.factory, c.receiver, tx,
+				fmt.Errorf("%w: %d > %d", ErrPrefixExceedsInput, n, i))
+			return
+		}
+		sub := source.Begin().Deref()
+		err = c.state.Update(ctx, sub)
+		if !errors.Is(err, ErrCommit) {
+			common.AssertNoError(xio.AsTx(sub).Rollback(), "rollback error")
+			if errors.Is(err, ErrIncomplete) || errors.Is(err, ErrInvalidInput) {
+				err = ErrRollback
+			}
+			return
+		}
+		common.AssertNoError(xio.AsTx(sub).Commit(), "commit error")
+	}
+	setValue(ctx, n)
+	err = ErrChainNext
+	return
+}
+
+// Sized adds a state that reads the length field of the given integer format and then
+// consumes exactly that many bytes. If max is not zero, the greater lengths are reported
+// as error messages, as well as lengths exceeding the remaining input. The data
+// without the length field is emitted as the message value.
+func (b Builder[T]) Sized(format BinaryFormat, max uint64) (tail *Chain[T]) {
+	common.AssertNotNil(format, "invalid grammar: nil binary format")
+	newNode := newSized(b.logger, b.factory, format, max)
+	tail = b.append("Sized("+format.Name()+")", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// Counted adds a state that reads the count field of the given integer format and then
+// applies the sub state exactly that many times. If max is not zero, the greater counts
+// are reported as error messages, as well as counts exceeding the remaining input.
+// The count is emitted as the message value. Each item must be terminated by Break in the
+// sub state.
+func (b Builder[T]) Counted(format BinaryFormat, max uint64, builder Builder[T], provider Provider[T]) (tail *Chain[T]) {
+	common.AssertNotNil(format, "invalid grammar: nil binary format")
+	newNode := newCounted(b.logger, b.factory, format, max, builder, provider)
+	tail = b.append("Counted("+format.Name()+")", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isSized returns true if the state is Sized.
+func isSized[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Sized[T])
+	return
+}
+
+// isCounted returns true if the state is Counted.
+func isCounted[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Counted[T])
+	return
+}
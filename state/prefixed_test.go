@@ -0,0 +1,170 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrefixed(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      []byte
+		state      func(b Builder[Token]) *Chain[Token]
+		wantTokens []Token
+		wantValue  any
+		wantWidth  int
+		wantErr    error
+		wantError  error
+	}
+
+	item := func(b Builder[Token]) []Update[Token] {
+		return AsSlice[Update[Token]](
+			b.Binary(U8).Emit(Token2).Break(),
+		)
+	}
+
+	tests := []testCase{
+		{
+			name:  "sized",
+			input: []byte{0x03, 'a', 'b', 'c', 'd'},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(U8, 0).Emit(Token1)
+			},
+			wantTokens: []Token{Token1},
+			wantValue:  []byte("abc"),
+			wantWidth:  4,
+			wantError:  ErrCommit,
+		},
+		{
+			name:  "sized empty",
+			input: []byte{0x00, 0x00},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(U16BE, 0).Emit(Token1)
+			},
+			wantTokens: []Token{Token1},
+			wantValue:  []byte{},
+			wantWidth:  2,
+			wantError:  ErrCommit,
+		},
+		{
+			name:  "sized varint",
+			input: append([]byte{0x80, 0x01}, bytes.Repeat([]byte{'x'}, 128)...),
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(ULEB128, 1024).Emit(Token1)
+			},
+			wantTokens: []Token{Token1},
+			wantValue:  bytes.Repeat([]byte{'x'}, 128),
+			wantWidth:  130,
+			wantError:  ErrCommit,
+		},
+		{
+			name:  "sized exceeds max",
+			input: []byte{0x03, 'a', 'b', 'c'},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(U8, 2).Emit(Token1)
+			},
+			wantErr:   ErrPrefixExceedsMax,
+			wantError: errStateBreak,
+		},
+		{
+			name:  "sized exceeds input",
+			input: []byte{0x05, 'a', 'b'},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(U8, 0).Emit(Token1)
+			},
+			wantErr:   ErrPrefixExceedsInput,
+			wantError: errStateBreak,
+		},
+		{
+			name:  "sized negative",
+			input: []byte{0xFF, 'a'},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(I8, 0).Emit(Token1)
+			},
+			wantErr:   ErrNegativePrefix,
+			wantError: errStateBreak,
+		},
+		{
+			name:  "sized no prefix",
+			input: []byte{0x01},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Sized(U16LE, 0).Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+		{
+			name:  "counted",
+			input: []byte{0x02, 0x0A, 0x0B, 0x0C},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Counted(U8, 0, b, item).Emit(Token1)
+			},
+			wantTokens: []Token{Token2, Token2, Token1},
+			wantValue:  uint64(2),
+			wantWidth:  3,
+			wantError:  ErrCommit,
+		},
+		{
+			name:  "counted exceeds max",
+			input: []byte{0x03, 0x0A, 0x0B, 0x0C},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Counted(U8, 2, b, item).Emit(Token1)
+			},
+			wantErr:   ErrPrefixExceedsMax,
+			wantError: errStateBreak,
+		},
+		{
+			name:  "counted exceeds input",
+			input: []byte{0x03, 0x0A, 0x0B},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Counted(U8, 0, b, item).Emit(Token1)
+			},
+			wantErr:   ErrPrefixExceedsInput,
+			wantError: errStateBreak,
+		},
+		{
+			name:  "counted item mismatch",
+			input: []byte{0x02, 0x0A, 0x0B},
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Counted(U8, 0, b, func(b Builder[Token]) []Update[Token] {
+					return AsSlice[Update[Token]](
+						b.Byte(0x0A).Emit(Token2).Break(),
+					)
+				}).Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBuffer(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantErr != nil {
+				// items read before the error are forwarded with the error message
+				if assert.NotEmpty(t, receiver.Slice) {
+					assert.ErrorIs(t, receiver.Slice[len(receiver.Slice)-1].AsError(), tc.wantErr)
+				}
+				return
+			}
+			if !assert.Len(t, receiver.Slice, len(tc.wantTokens)) {
+				return
+			}
+			for i, msg := range receiver.Slice {
+				assert.Equal(t, tc.wantTokens[i], msg.Token)
+			}
+			if len(tc.wantTokens) > 0 {
+				last := receiver.Slice[len(receiver.Slice)-1]
+				assert.Equal(t, tc.wantValue, last.Value)
+				assert.Equal(t, tc.wantWidth, last.Width)
+			}
+		})
+	}
+}
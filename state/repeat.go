@@ -143,10 +143,19 @@ func isRepeatable[T any](s Update[T]) (ret bool) {
 		isOmit[T],
 		isRest[T],
 		isTap[T],
+		isEncoding[T],
+		isCheck[T],
+		isSized[T],
+		isCounted[T],
+		isAlignBits[T],
+		isLongest[T],
 		isBreak[T],
 		isNamed[T],
+		isNotRepeatableCapture[T],
+		isAnchor[T],
 		isNotRepeatableFnRune[T],
 		isNotRepeatableFnByte[T],
+		isNotRepeatableFnGrapheme[T],
 	))
 	ret = isRepeatableState(s)
 	return
@@ -23,7 +23,9 @@ func newRest[T any](logger common.Logger) *Rest[T] {
 // Update implements the State interface. It consumes all the remaining input from the io state.
 func (r *Rest[T]) Update(ctx context.Context, ioState xio.State) (err error) {
 	// just advance the reader and do nothing else
-	_, _ = io.Copy(io.Discard, ioState)
+	if _, err = io.Copy(io.Discard, ioState); err != nil {
+		return
+	}
 	err = ErrChainNext
 	return
 }
@@ -16,6 +16,8 @@ type Run[T any] struct {
 	eofErr   error
 	states   []Update[T]
 	current  int
+	// commitHook is called after each commit of the top level transaction
+	commitHook func(ctx context.Context) error
 }
 
 // NewRun creates a new instance of the Run state machine.
@@ -33,6 +35,13 @@ func NewRun[T any](
 	}
 }
 
+// WithCommitHook sets the function called after each commit of the top level transaction.
+// If the hook returns an error, the run stops with this error.
+func (r *Run[T]) WithCommitHook(hook func(ctx context.Context) error) *Run[T] {
+	r.commitHook = hook
+	return r
+}
+
 // currentState returns the current state of the lexer.
 func (r *Run[T]) currentState() Update[T] {
 	if len(r.states) == 0 && r.provider != nil {
@@ -98,6 +107,12 @@ loop:
 		case errors.Is(err, ErrCommit):
 			common.AssertNoError(tx.Commit(), "commit error")
 			r.Reset()
+			if r.commitHook == nil {
+				break
+			}
+			if err = r.commitHook(ctx); err != nil {
+				break loop
+			}
 		case errors.Is(err, ErrRollback):
 			common.AssertNoError(tx.Rollback(), "rollback error")
 			r.next()
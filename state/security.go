@@ -0,0 +1,136 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/diakovliev/lexer/xunicode"
+)
+
+var (
+	// ErrUnbalancedBidi indicates that the data contains unterminated bidi embeddings, overrides or isolates.
+	ErrUnbalancedBidi = errors.New("unbalanced bidi control characters")
+	// ErrMixedScript indicates that the data mixes runes from incompatible scripts.
+	ErrMixedScript = errors.New("mixed script")
+)
+
+type (
+	// CheckAction defines how the Check state reports failed checks.
+	CheckAction int
+
+	// CheckFn checks the data read by the chain. The data is converted to UTF-8.
+	// It returns nil if the check is passed.
+	CheckFn func(data string) error
+
+	// Check is a state that checks the data read by the chain so far and reports
+	// failed checks as error or warning messages.
+	Check[T any] struct {
+		logger   common.Logger
+		fn       CheckFn
+		action   CheckAction
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+)
+
+const (
+	// Reject emits an error message and breaks the lexer.
+	Reject CheckAction = iota
+	// Warn emits a warning message and continues the chain.
+	Warn
+)
+
+// newCheck creates a new instance of the Check state.
+func newCheck[T any](logger common.Logger, factory message.Factory[T], fn CheckFn, action CheckAction) *Check[T] {
+	return &Check[T]{
+		logger:  logger,
+		factory: factory,
+		fn:      fn,
+		action:  action,
+	}
+}
+
+// setReceiver sets the receiver of the state.
+func (c *Check[T]) setReceiver(receiver message.Receiver[T]) {
+	c.receiver = receiver
+}
+
+// Update implements the Update interface. It checks the data read by the chain.
+func (c Check[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(c.receiver, "receiver is not set")
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	checkErr := c.fn(string(xio.ToUTF8(xio.EncodingOf(tx), data)))
+	if checkErr == nil {
+		err = ErrChainNext
+		return
+	}
+	switch c.action {
+	case Reject:
+		err = emitErrorMessage(ctx, c.factory, c.receiver, tx, checkErr)
+		return
+	case Warn:
+	default:
+		common.AssertUnreachable("unknown check action: %d", c.action)
+	}
+	level, ok := GetTokenLevel(ctx)
+	common.AssertTrue(ok, "no token level in context")
+	msg, err := message.MakeWarning(ctx, c.factory, level, checkErr, data, int(pos), len(data))
+	if err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	if err = c.receiver.Receive(AsSlice(msg)); err != nil {
+		err = MakeErrBreak(err)
+		return
+	}
+	err = ErrChainNext
+	return
+}
+
+// CheckFn adds a state that checks the data read by the chain so far by the given function.
+// The failed check is reported according to the action.
+func (b Builder[T]) CheckFn(fn CheckFn, action CheckAction) (tail *Chain[T]) {
+	common.AssertNotNil(fn, "invalid grammar: nil check function")
+	common.AssertNotNilPtr(b.last, "invalid grammar: check can't be the first state in chain")
+	newNode := newCheck(b.logger, b.factory, fn, action)
+	tail = b.append("Check", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// BidiCheck adds a state that checks the data read by the chain so far, e.g. a comment
+// or a string literal, for unterminated bidi embeddings, overrides and isolates.
+func (b Builder[T]) BidiCheck(action CheckAction) (tail *Chain[T]) {
+	tail = b.CheckFn(func(data string) (err error) {
+		if !xunicode.IsBidiBalanced(data) {
+			err = ErrUnbalancedBidi
+		}
+		return
+	}, action)
+	return
+}
+
+// ScriptCheck adds a state that checks the data read by the chain so far, e.g.
+// an identifier, for mixed scripts according to UTS #39.
+func (b Builder[T]) ScriptCheck(action CheckAction) (tail *Chain[T]) {
+	tail = b.CheckFn(func(data string) (err error) {
+		if xunicode.IsMixedScript(data) {
+			err = fmt.Errorf("%w: %s", ErrMixedScript, strings.Join(xunicode.ScriptsOf(data), ", "))
+		}
+		return
+	}, action)
+	return
+}
+
+// isCheck returns true if the state is Check.
+func isCheck[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*Check[T])
+	return
+}
@@ -0,0 +1,150 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityChecks(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		state     func(b Builder[Token]) *Chain[Token]
+		wantTypes []message.Type
+		wantErr   error
+		wantError error
+	}
+
+	comment := func(action CheckAction) func(b Builder[Token]) *Chain[Token] {
+		return func(b Builder[Token]) *Chain[Token] {
+			return b.String("//").UntilRune(IsRune('\n')).BidiCheck(action).Emit(Token1)
+		}
+	}
+
+	identifier := func(action CheckAction) func(b Builder[Token]) *Chain[Token] {
+		return func(b Builder[Token]) *Chain[Token] {
+			return b.Identifier(XIDIdentifier).ScriptCheck(action).Emit(Token1)
+		}
+	}
+
+	tests := []testCase{
+		{
+			name:      "balanced comment",
+			input:     "// \u202Eabc\u202C\n",
+			state:     comment(Reject),
+			wantTypes: []message.Type{message.Token},
+			wantError: ErrCommit,
+		},
+		{
+			name:      "reject unbalanced comment",
+			input:     "// \u202E } if admin {\n",
+			state:     comment(Reject),
+			wantTypes: []message.Type{message.Error},
+			wantErr:   ErrUnbalancedBidi,
+			wantError: errStateBreak,
+		},
+		{
+			name:      "warn unbalanced comment",
+			input:     "// \u2067abc\n",
+			state:     comment(Warn),
+			wantTypes: []message.Type{message.Warning, message.Token},
+			wantErr:   ErrUnbalancedBidi,
+			wantError: ErrCommit,
+		},
+		{
+			name:      "single script identifier",
+			input:     "paypal2",
+			state:     identifier(Reject),
+			wantTypes: []message.Type{message.Token},
+			wantError: ErrCommit,
+		},
+		{
+			name:      "reject mixed script identifier",
+			input:     "p\u0430ypal",
+			state:     identifier(Reject),
+			wantTypes: []message.Type{message.Error},
+			wantErr:   ErrMixedScript,
+			wantError: errStateBreak,
+		},
+		{
+			name:      "warn mixed script identifier",
+			input:     "p\u0430ypal",
+			state:     identifier(Warn),
+			wantTypes: []message.Type{message.Warning, message.Token},
+			wantErr:   ErrMixedScript,
+			wantError: ErrCommit,
+		},
+		{
+			name:  "warning is dropped on rollback",
+			input: "p\u0430ypal",
+			state: func(b Builder[Token]) *Chain[Token] {
+				return b.Identifier(XIDIdentifier).ScriptCheck(Warn).Rune('!').Emit(Token1)
+			},
+			wantError: ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := tc.state(builder).Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if !assert.Len(t, receiver.Slice, len(tc.wantTypes)) {
+				return
+			}
+			for i, msg := range receiver.Slice {
+				assert.Equal(t, tc.wantTypes[i], msg.Type)
+				switch msg.Type {
+				case message.Error:
+					assert.ErrorIs(t, msg.AsError(), tc.wantErr)
+				case message.Warning:
+					assert.ErrorIs(t, msg.AsWarning(), tc.wantErr)
+				}
+			}
+		})
+	}
+}
+
+func TestSecurityChecks_WarningRollback(t *testing.T) {
+	receiver := message.Slice[Token]()
+	builder := makeTestBuilder(receiver)
+	state := builder.String("//").UntilRune(IsRune('\n')).BidiCheck(Warn).Rune('\n').Emit(Token1)
+
+	source := xio.New(builder.logger, bytes.NewBufferString("// ⁧abc"))
+	err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	assert.ErrorIs(t, err, ErrRollback)
+	assert.Empty(t, receiver.Slice)
+
+	// the warning of the rolled back input is not sent with the next token
+	source = xio.New(builder.logger, bytes.NewBufferString("// ok\n"))
+	err = state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	assert.ErrorIs(t, err, ErrCommit)
+	if assert.Len(t, receiver.Slice, 1) {
+		assert.Equal(t, message.Token, receiver.Slice[0].Type)
+	}
+}
+
+// errorOnlyFactory is a user factory which doesn't implement WarningFactory.
+type errorOnlyFactory struct {
+	message.Factory[Token]
+}
+
+func TestSecurityChecks_ErrorOnlyFactory(t *testing.T) {
+	receiver := message.Slice[Token]()
+	builder := Make[Token](makeTestBuilder(receiver).logger, errorOnlyFactory{message.DefaultFactory[Token]()}, receiver)
+	state := builder.String("//").UntilRune(IsRune('\n')).BidiCheck(Warn).Emit(Token1)
+	source := xio.New(builder.logger, bytes.NewBufferString("// ⁧abc\n"))
+	err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+	assert.ErrorIs(t, err, ErrCommit)
+	if assert.Len(t, receiver.Slice, 2) {
+		assert.Equal(t, message.Warning, receiver.Slice[0].Type)
+		assert.ErrorIs(t, receiver.Slice[0].AsWarning(), ErrUnbalancedBidi)
+	}
+}
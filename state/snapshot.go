@@ -0,0 +1,70 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSnapshotMismatch indicates that the snapshot doesn't match the states of the run.
+var ErrSnapshotMismatch = errors.New("snapshot doesn't match the states")
+
+// Snapshotter is implemented by the states which keep data between the top level commits,
+// like the Layout indentation levels. The lexer checkpoint saves the data by Snapshot and
+// passes it to Restore of the same state of the restored lexer.
+type Snapshotter interface {
+	// Snapshot returns the data kept by the state.
+	Snapshot() (data []byte, err error)
+	// Restore sets the data kept by the state.
+	Restore(data []byte) (err error)
+}
+
+// snapshotters returns the top level states implementing Snapshotter in the order of the
+// states and of the chains nodes.
+func (r *Run[T]) snapshotters() (ret []Snapshotter) {
+	if len(r.states) == 0 && r.provider != nil {
+		r.states = r.provider(r.builder)
+	}
+	for _, s := range r.states {
+		chain, ok := s.(*Chain[T])
+		if !ok {
+			if snapshotter, ok := s.(Snapshotter); ok {
+				ret = append(ret, snapshotter)
+			}
+			continue
+		}
+		for node := chain.head(); node != nil; node = node.next() {
+			if snapshotter, ok := node.deref().(Snapshotter); ok {
+				ret = append(ret, snapshotter)
+			}
+		}
+	}
+	return
+}
+
+// Snapshot returns the data kept by the states between the top level commits.
+func (r *Run[T]) Snapshot() (ret [][]byte, err error) {
+	for _, snapshotter := range r.snapshotters() {
+		var data []byte
+		if data, err = snapshotter.Snapshot(); err != nil {
+			return
+		}
+		ret = append(ret, data)
+	}
+	return
+}
+
+// Restore sets the data kept by the states from the data returned by Snapshot of the run
+// built by the same provider.
+func (r *Run[T]) Restore(data [][]byte) (err error) {
+	snapshotters := r.snapshotters()
+	if len(snapshotters) != len(data) {
+		err = fmt.Errorf("%w: %d states, %d snapshots", ErrSnapshotMismatch, len(snapshotters), len(data))
+		return
+	}
+	for i, snapshotter := range snapshotters {
+		if err = snapshotter.Restore(data[i]); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -25,7 +25,9 @@ func newState[T any](logger common.Logger, builder Builder[T], provider Provider
 }
 
 // Update implements State interface. It updates the current state of the lexer with the given transaction.
+// The encoding switched by the sub states is restored on return.
 func (s *State[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	defer saveEncoding(tx)()
 	err = s.run.Run(ctx, xio.AsSource(tx))
 	defer s.run.Reset()
 	return
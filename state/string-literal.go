@@ -0,0 +1,459 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+)
+
+var (
+	// ErrInvalidEscape indicates that the string literal contains an unknown or malformed escape sequence.
+	ErrInvalidEscape = errors.New("invalid escape sequence")
+	// ErrUnterminatedString indicates that the string literal is not closed before the end of the input or line.
+	ErrUnterminatedString = errors.New("unterminated string")
+)
+
+type (
+	// StringRules describes the string literal syntax.
+	StringRules struct {
+		// Quote opens and closes the literal, e.g. `"`, `'` or `"""`.
+		Quote string
+		// Escape starts the escape sequence. Zero means the raw literal without escapes.
+		Escape rune
+		// Simple maps the rune after the escape to the decoded rune, e.g. 'n' to '\n'.
+		Simple map[rune]rune
+		// Hex enables \x escapes of hex digits.
+		Hex bool
+		// HexDigits is the exact count of the hex escape digits, e.g. 2 for \xHH.
+		// Zero means any count of at least one digit.
+		HexDigits int
+		// Octal enables escapes of one to three octal digits, e.g. \0 or \377.
+		Octal bool
+		// OctalDigits is the exact count of the octal escape digits, e.g. 3 for \000.
+		// Zero means one to three digits.
+		OctalDigits int
+		// Unicode enables \uXXXX escapes.
+		Unicode bool
+		// Unicode8 enables \UXXXXXXXX escapes.
+		Unicode8 bool
+		// Surrogates enables UTF-16 surrogate pairs encoded by two \uXXXX escapes.
+		Surrogates bool
+		// ByteEscapes makes hex and octal escapes produce bytes instead of code points.
+		ByteEscapes bool
+		// LineContinuation makes the escaped newline to be skipped.
+		LineContinuation bool
+		// Multiline allows newlines inside the literal.
+		Multiline bool
+		// DropCR removes the carriage returns from the decoded string.
+		DropCR bool
+	}
+
+	// StringLiteral is a state that matches a string literal by the given rules and
+	// decodes its escape sequences. The decoded string is emitted as the message value.
+	StringLiteral[T any] struct {
+		logger   common.Logger
+		rules    StringRules
+		factory  message.Factory[T]
+		receiver message.Receiver[T]
+	}
+)
+
+var (
+	// GoString is the Go interpreted string literal.
+	GoString = StringRules{
+		Quote:  `"`,
+		Escape: '\\',
+		Simple: map[rune]rune{
+			'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v', '\\': '\\', '"': '"',
+		},
+		Hex:         true,
+		HexDigits:   2,
+		Octal:       true,
+		OctalDigits: 3,
+		Unicode:     true,
+		Unicode8:    true,
+		ByteEscapes: true,
+	}
+
+	// GoRawString is the Go raw string literal. The carriage returns are removed from
+	// its value.
+	GoRawString = StringRules{
+		Quote:     "`",
+		Multiline: true,
+		DropCR:    true,
+	}
+
+	// JSONString is the JSON string.
+	JSONString = StringRules{
+		Quote:  `"`,
+		Escape: '\\',
+		Simple: map[rune]rune{
+			'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', '\\': '\\', '/': '/', '"': '"',
+		},
+		Unicode:    true,
+		Surrogates: true,
+	}
+
+	// CString is the C string literal.
+	CString = StringRules{
+		Quote:  `"`,
+		Escape: '\\',
+		Simple: map[rune]rune{
+			'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+			'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+		},
+		Hex:              true,
+		Octal:            true,
+		Unicode:          true,
+		Unicode8:         true,
+		ByteEscapes:      true,
+		LineContinuation: true,
+	}
+
+	// PythonString is the Python string literal, use WithQuote for the single quoted one.
+	PythonString = StringRules{
+		Quote:  `"`,
+		Escape: '\\',
+		Simple: map[rune]rune{
+			'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+			'\\': '\\', '\'': '\'', '"': '"',
+		},
+		Hex:              true,
+		HexDigits:        2,
+		Octal:            true,
+		Unicode:          true,
+		Unicode8:         true,
+		LineContinuation: true,
+	}
+
+	// PythonTripleString is the Python triple quoted multiline string literal.
+	PythonTripleString = PythonString.WithQuote(`"""`).WithMultiline()
+)
+
+// RawString returns the rules of the literal without escapes enclosed in the given quote.
+func RawString(quote string) StringRules {
+	return StringRules{Quote: quote}
+}
+
+// WithQuote returns a copy of the rules with the given quote.
+func (sr StringRules) WithQuote(quote string) StringRules {
+	sr.Quote = quote
+	return sr
+}
+
+// WithMultiline returns a copy of the rules that allows newlines inside the literal.
+func (sr StringRules) WithMultiline() StringRules {
+	sr.Multiline = true
+	return sr
+}
+
+// newStringLiteral creates a new instance of the StringLiteral state.
+func newStringLiteral[T any](logger common.Logger, factory message.Factory[T], rules StringRules) *StringLiteral[T] {
+	return &StringLiteral[T]{
+		logger:  logger,
+		rules:   rules,
+		factory: factory,
+	}
+}
+
+// setReceiver sets the receiver of the state.
+func (sl *StringLiteral[T]) setReceiver(receiver message.Receiver[T]) {
+	sl.receiver = receiver
+}
+
+// quote checks if the quote is next in the input. It advances the tx only if the check is passed.
+func (sl StringLiteral[T]) quote(tx xio.State) (ret bool, err error) {
+	ahead := xio.AsSource(tx).Begin().Deref()
+	lookTx := xio.AsTx(ahead)
+	for _, q := range sl.rules.Quote {
+		var r rune
+		var rw int
+		r, rw, err = ahead.NextRune()
+		if err != nil && !errors.Is(err, io.EOF) {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+		err = nil
+		if rw == 0 || r != q {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+			return
+		}
+	}
+	common.AssertNoError(lookTx.Commit(), "commit error")
+	ret = true
+	return
+}
+
+// hex reads exactly n hex digits.
+func hex(tx xio.State, n int) (value rune, ok bool, err error) {
+	for range n {
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		digit, isDigit := hexDigit(r)
+		if rw == 0 || !isDigit {
+			if rw > 0 {
+				_, err = tx.Unread()
+				common.AssertNoError(err, "unread error")
+			}
+			return
+		}
+		value = value<<4 | digit
+	}
+	ok = true
+	return
+}
+
+// hexAny reads all the following hex digits, at least one. The value above the code
+// points range is not accumulated further.
+func hexAny(tx xio.State) (value rune, ok bool, err error) {
+	for {
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		digit, isDigit := hexDigit(r)
+		if rw == 0 || !isDigit {
+			if rw > 0 {
+				_, err = tx.Unread()
+				common.AssertNoError(err, "unread error")
+			}
+			return
+		}
+		if value <= unicode.MaxRune {
+			value = value<<4 | digit
+		}
+		ok = true
+	}
+}
+
+func hexDigit(r rune) (digit rune, ok bool) {
+	switch {
+	case '0' <= r && r <= '9':
+		digit, ok = r-'0', true
+	case 'a' <= r && r <= 'f':
+		digit, ok = r-'a'+10, true
+	case 'A' <= r && r <= 'F':
+		digit, ok = r-'A'+10, true
+	}
+	return
+}
+
+// octal reads the rest of the octal digits after the first one. If digits is zero, it
+// reads up to two more digits, otherwise exactly digits in total.
+func octal(tx xio.State, first rune, digits int) (value rune, ok bool, err error) {
+	value = first - '0'
+	exact := digits > 0
+	if !exact {
+		digits = 3
+	}
+	for range digits - 1 {
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 || r < '0' || r > '7' {
+			if rw > 0 {
+				_, err = tx.Unread()
+				common.AssertNoError(err, "unread error")
+			}
+			ok = !exact
+			return
+		}
+		value = value<<3 | (r - '0')
+	}
+	ok = true
+	return
+}
+
+// surrogate reads the low surrogate escape after the high one. It advances the tx
+// only if the low surrogate is found.
+func (sl StringLiteral[T]) surrogate(tx xio.State, high rune) (r rune, ok bool, err error) {
+	ahead := xio.AsSource(tx).Begin().Deref()
+	lookTx := xio.AsTx(ahead)
+	defer func() {
+		if ok {
+			common.AssertNoError(lookTx.Commit(), "commit error")
+		} else {
+			common.AssertNoError(lookTx.Rollback(), "rollback error")
+		}
+	}()
+	for _, want := range []rune{sl.rules.Escape, 'u'} {
+		next, rw, nextErr := ahead.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 || next != want {
+			return
+		}
+	}
+	low, isHex, err := hex(ahead, 4)
+	if err != nil || !isHex {
+		return
+	}
+	if r = utf16.DecodeRune(high, low); r != utf8.RuneError {
+		ok = true
+	}
+	return
+}
+
+// escape decodes the escape sequence after the escape rune and appends it to out.
+func (sl StringLiteral[T]) escape(tx xio.State, out []byte) (ret []byte, ok bool, err error) {
+	ret = out
+	r, rw, err := tx.NextRune()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return
+	}
+	err = nil
+	if rw == 0 {
+		return
+	}
+	if simple, isSimple := sl.rules.Simple[r]; isSimple {
+		ret = utf8.AppendRune(ret, simple)
+		ok = true
+		return
+	}
+	var value rune
+	switch {
+	case sl.rules.LineContinuation && r == '\n':
+		ok = true
+	case sl.rules.Hex && r == 'x':
+		if sl.rules.HexDigits > 0 {
+			value, ok, err = hex(tx, sl.rules.HexDigits)
+		} else {
+			value, ok, err = hexAny(tx)
+		}
+		if ok = ok && sl.validCode(value); ok {
+			ret = sl.appendCode(ret, value)
+		}
+	case sl.rules.Octal && '0' <= r && r <= '7':
+		if value, ok, err = octal(tx, r, sl.rules.OctalDigits); ok && sl.validCode(value) {
+			ret = sl.appendCode(ret, value)
+		} else {
+			ok = false
+		}
+	case sl.rules.Unicode && r == 'u':
+		if value, ok, err = hex(tx, 4); !ok {
+			return
+		}
+		if sl.rules.Surrogates && utf16.IsSurrogate(value) {
+			value, ok, err = sl.surrogate(tx, value)
+		}
+		if ok = ok && utf8.ValidRune(value); ok {
+			ret = utf8.AppendRune(ret, value)
+		}
+	case sl.rules.Unicode8 && r == 'U':
+		if value, ok, err = hex(tx, 8); ok && utf8.ValidRune(value) {
+			ret = utf8.AppendRune(ret, value)
+		} else {
+			ok = false
+		}
+	}
+	return
+}
+
+// validCode checks if the hex or octal escape value fits a byte or is a code point.
+func (sl StringLiteral[T]) validCode(value rune) bool {
+	if sl.rules.ByteEscapes {
+		return value <= 0xFF
+	}
+	return utf8.ValidRune(value)
+}
+
+// appendCode appends the hex or octal escape value as a byte or a code point.
+func (sl StringLiteral[T]) appendCode(out []byte, value rune) []byte {
+	if sl.rules.ByteEscapes {
+		return append(out, byte(value))
+	}
+	return utf8.AppendRune(out, value)
+}
+
+// reportError emits the error message for the data between from and the current
+// position of the tx and returns the break error.
+func (sl StringLiteral[T]) reportError(ctx context.Context, tx xio.State, from int64, userErr error) (err error) {
+	data, pos, err := xio.AsPending(tx).Pending()
+	common.AssertNoError(err, "pending data error")
+	err = emitErrorAt(ctx, sl.factory, sl.receiver, userErr, data[from-pos:], int(from))
+	return
+}
+
+// Update implements the Update interface. It matches the string literal and stores
+// the decoded string to be emitted.
+func (sl StringLiteral[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	common.AssertNotNil(sl.receiver, "receiver is not set")
+	start := xio.AsOffset(tx).Offset()
+	ok, err := sl.quote(tx)
+	if err != nil {
+		return
+	}
+	if !ok {
+		err = ErrRollback
+		return
+	}
+	var out []byte
+	for {
+		if ok, err = sl.quote(tx); err != nil {
+			return
+		}
+		if ok {
+			break
+		}
+		offset := xio.AsOffset(tx).Offset()
+		r, rw, nextErr := tx.NextRune()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if rw == 0 || (!sl.rules.Multiline && (r == '\n' || r == '\r')) {
+			err = sl.reportError(ctx, tx, start, ErrUnterminatedString)
+			return
+		}
+		if sl.rules.DropCR && r == '\r' {
+			continue
+		}
+		if sl.rules.Escape == 0 || r != sl.rules.Escape {
+			out = utf8.AppendRune(out, r)
+			continue
+		}
+		if out, ok, err = sl.escape(tx, out); err != nil {
+			return
+		}
+		if !ok {
+			err = sl.reportError(ctx, tx, offset, ErrInvalidEscape)
+			return
+		}
+	}
+	setValue(ctx, string(out))
+	err = ErrChainNext
+	return
+}
+
+// StringLiteral adds a state that matches the string literal by the given rules. The decoded
+// string is emitted as the message value. Invalid escape sequences and unterminated literals
+// are reported as error messages.
+func (b Builder[T]) StringLiteral(rules StringRules) (tail *Chain[T]) {
+	common.AssertFalse(rules.Quote == "", "invalid grammar: empty quote")
+	newNode := newStringLiteral(b.logger, b.factory, rules)
+	tail = b.append("StringLiteral", func() Update[T] { return newNode })
+	// sent all messages to the the first node receiver
+	newNode.setReceiver(tail.head().receiver)
+	return
+}
+
+// isStringLiteral returns true if the state is StringLiteral.
+func isStringLiteral[T any](s Update[T]) (ret bool) {
+	_, ret = s.(*StringLiteral[T])
+	return
+}
@@ -0,0 +1,208 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringLiteral(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     string
+		rules     StringRules
+		wantValue string
+		wantWidth int
+		wantError error
+		wantPos   int
+		wantData  string
+	}
+
+	tests := []testCase{
+		{
+			name:      "go escapes",
+			input:     `"a\tb\x41\101é\U0001F600\\\"" tail`,
+			rules:     GoString,
+			wantValue: "a\tbAAé\U0001F600\\\"",
+			wantWidth: 30,
+		},
+		{
+			name:      "go byte escapes",
+			input:     `"\xff\377"`,
+			rules:     GoString,
+			wantValue: "\xff\xff",
+			wantWidth: 10,
+		},
+		{
+			name:      "python code point escapes",
+			input:     `"\xff\377"`,
+			rules:     PythonString,
+			wantValue: "ÿÿ",
+			wantWidth: 10,
+		},
+		{
+			name:      "json surrogate pair",
+			input:     `"\ud83d\ude00\/"`,
+			rules:     JSONString,
+			wantValue: "\U0001F600/",
+			wantWidth: 16,
+		},
+		{
+			name:      "single quote",
+			input:     `'it\'s'`,
+			rules:     PythonString.WithQuote("'"),
+			wantValue: "it's",
+			wantWidth: 7,
+		},
+		{
+			name:      "triple quoted",
+			input:     "\"\"\"a \"quoted\"\nline\\\nb\"\"\"",
+			rules:     PythonTripleString,
+			wantValue: "a \"quoted\"\nlineb",
+			wantWidth: 24,
+		},
+		{
+			name:      "raw string",
+			input:     "`a\\n\nb`",
+			rules:     GoRawString,
+			wantValue: "a\\n\nb",
+			wantWidth: 7,
+		},
+		{
+			name:      "raw string crlf",
+			input:     "`a\r\nb\r`",
+			rules:     GoRawString,
+			wantValue: "a\nb",
+			wantWidth: 7,
+		},
+		{
+			name:      "c long hex escape",
+			input:     `"\x0041\x7g"`,
+			rules:     CString,
+			wantValue: "A\x07g",
+			wantWidth: 12,
+		},
+		{
+			name:      "c hex escape out of byte range",
+			input:     `"a\x141"`,
+			rules:     CString,
+			wantError: ErrInvalidEscape,
+			wantPos:   2,
+			wantData:  `\x141`,
+		},
+		{
+			name:      "c line continuation",
+			input:     "\"a\\\nb\\?\"",
+			rules:     CString,
+			wantValue: "ab?",
+			wantWidth: 8,
+		},
+		{
+			name:      "no quote",
+			input:     "abc",
+			rules:     GoString,
+			wantError: ErrRollback,
+		},
+		{
+			name:      "invalid escape",
+			input:     `"ab\qc"`,
+			rules:     GoString,
+			wantError: ErrInvalidEscape,
+			wantPos:   3,
+			wantData:  `\q`,
+		},
+		{
+			name:      "short hex escape",
+			input:     `"ab\x4g"`,
+			rules:     GoString,
+			wantError: ErrInvalidEscape,
+			wantPos:   3,
+			wantData:  `\x4`,
+		},
+		{
+			name:      "octal escape out of byte range",
+			input:     `"\400"`,
+			rules:     GoString,
+			wantError: ErrInvalidEscape,
+			wantPos:   1,
+			wantData:  `\400`,
+		},
+		{
+			name:      "short go octal escape",
+			input:     `"a\0b"`,
+			rules:     GoString,
+			wantError: ErrInvalidEscape,
+			wantPos:   2,
+			wantData:  `\0`,
+		},
+		{
+			name:      "short c octal escape",
+			input:     `"a\0b\12"`,
+			rules:     CString,
+			wantValue: "a\x00b\n",
+			wantWidth: 9,
+		},
+		{
+			name:      "lone surrogate",
+			input:     `"a\ud83dx"`,
+			rules:     JSONString,
+			wantError: ErrInvalidEscape,
+			wantPos:   2,
+			wantData:  `\ud83d`,
+		},
+		{
+			name:      "unterminated by newline",
+			input:     "\"abc\ndef\"",
+			rules:     GoString,
+			wantError: ErrUnterminatedString,
+			wantPos:   0,
+			wantData:  "\"abc\n",
+		},
+		{
+			name:      "unterminated by end of input",
+			input:     `"abc`,
+			rules:     GoString,
+			wantError: ErrUnterminatedString,
+			wantPos:   0,
+			wantData:  `"abc`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.StringLiteral(tc.rules).Emit(Token1)
+			// the state is re-entrant, so it gives the same result on retry
+			for range 2 {
+				receiver.Reset()
+				source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+				err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+				switch {
+				case tc.wantError == ErrRollback:
+					assert.ErrorIs(t, err, ErrRollback)
+					assert.Empty(t, receiver.Slice)
+				case tc.wantError != nil:
+					assert.ErrorIs(t, err, errStateBreak)
+					if assert.Len(t, receiver.Slice, 1) {
+						msg := receiver.Slice[0]
+						assert.ErrorIs(t, msg.AsError(), tc.wantError)
+						assert.Equal(t, tc.wantPos, msg.Pos)
+						assert.Equal(t, tc.wantData, string(msg.AsError().Value.([]byte)))
+					}
+				default:
+					assert.ErrorIs(t, err, ErrCommit)
+					if assert.Len(t, receiver.Slice, 1) {
+						msg := receiver.Slice[0]
+						assert.Equal(t, tc.wantValue, msg.AsString())
+						assert.Equal(t, tc.wantWidth, msg.Width)
+					}
+				}
+			}
+		})
+	}
+}
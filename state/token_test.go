@@ -6,4 +6,7 @@ const (
 	Token1 Token = iota
 	Token2
 	Token3
+	Token4
+	Token5
+	Token6
 )
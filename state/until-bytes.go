@@ -0,0 +1,88 @@
+package state
+
+import (
+	"context"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+type (
+	// UntilFlags configures the UntilBytes and the UntilString states.
+	UntilFlags int
+
+	// UntilBytes is a state that reads until one of the delimiters.
+	UntilBytes[T any] struct {
+		logger     common.Logger
+		delimiters [][]byte
+		flags      UntilFlags
+	}
+)
+
+const (
+	// UntilExclude stops before the delimiter. It is the default.
+	UntilExclude UntilFlags = 0
+	// UntilInclude consumes the delimiter.
+	UntilInclude UntilFlags = 1
+	// UntilEOF accepts the end of input if no delimiter is found, otherwise the state
+	// is rolled back.
+	UntilEOF UntilFlags = 2
+)
+
+// newUntilBytes creates a new instance of the UntilBytes state.
+func newUntilBytes[T any](logger common.Logger, flags UntilFlags, delimiters [][]byte) *UntilBytes[T] {
+	return &UntilBytes[T]{
+		logger:     logger,
+		delimiters: delimiters,
+		flags:      flags,
+	}
+}
+
+// Update implements the State interface. It searches the delimiters in the buffered
+// input instead of reading it rune by rune.
+func (ub UntilBytes[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	offset := xio.AsOffset(tx)
+	start := offset.Offset()
+	index, err := xio.AsSearch(tx).Search(ub.delimiters, ub.flags&UntilInclude != 0)
+	if err != nil {
+		return
+	}
+	if index < 0 && ub.flags&UntilEOF == 0 || offset.Offset() == start {
+		// no delimiter or nothing was read
+		_, err = tx.Unread()
+		common.AssertNoError(err, "unread error")
+		err = ErrRollback
+		return
+	}
+	err = ErrChainNext
+	return
+}
+
+// untilBytesState adds the UntilBytes state with the given name.
+func (b Builder[T]) untilBytesState(name string, flags UntilFlags, delimiters [][]byte) (tail *Chain[T]) {
+	common.AssertTrue(len(delimiters) > 0, "invalid grammar: no delimiters")
+	for _, delimiter := range delimiters {
+		common.AssertTrue(len(delimiter) > 0, "invalid grammar: empty delimiter")
+	}
+	tail = b.append(name, func() Update[T] { return newUntilBytes[T](b.logger, flags, delimiters) })
+	return
+}
+
+// UntilBytes creates a state that reads bytes until one of the delimiters. The delimiter
+// is consumed if the flags contain UntilInclude. If no delimiter is found, the state is
+// rolled back, unless the flags contain UntilEOF.
+func (b Builder[T]) UntilBytes(flags UntilFlags, delimiters ...[]byte) (tail *Chain[T]) {
+	tail = b.untilBytesState("UntilBytes", flags, delimiters)
+	return
+}
+
+// UntilString creates a state that reads bytes until one of the delimiters, like UntilBytes.
+// The delimiters are matched as UTF-8 bytes.
+func (b Builder[T]) UntilString(flags UntilFlags, delimiters ...string) (tail *Chain[T]) {
+	samples := make([][]byte, 0, len(delimiters))
+	for _, delimiter := range delimiters {
+		samples = append(samples, []byte(delimiter))
+	}
+	tail = b.untilBytesState("UntilString", flags, samples)
+	return
+}
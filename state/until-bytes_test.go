@@ -0,0 +1,86 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/diakovliev/lexer/message"
+	"github.com/diakovliev/lexer/xio"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUntilString(t *testing.T) {
+	type testCase struct {
+		name       string
+		input      string
+		flags      UntilFlags
+		delimiters []string
+		wantValue  string
+		wantError  error
+	}
+
+	tests := []testCase{
+		{
+			name:       "exclude",
+			input:      "<!-- a - b -->tail",
+			delimiters: []string{"-->"},
+			wantValue:  "<!-- a - b ",
+			wantError:  ErrCommit,
+		},
+		{
+			name:       "include",
+			input:      "<!-- a - b -->tail",
+			flags:      UntilInclude,
+			delimiters: []string{"-->"},
+			wantValue:  "<!-- a - b -->",
+			wantError:  ErrCommit,
+		},
+		{
+			name:       "several delimiters",
+			input:      "GET / HTTP/1.1\n\nbody\r\n\r\n",
+			flags:      UntilInclude,
+			delimiters: []string{"\r\n\r\n", "\n\n"},
+			wantValue:  "GET / HTTP/1.1\n\n",
+			wantError:  ErrCommit,
+		},
+		{
+			name:       "no delimiter",
+			input:      "<!-- a",
+			delimiters: []string{"-->"},
+			wantError:  ErrRollback,
+		},
+		{
+			name:       "no delimiter at eof",
+			input:      "<!-- a",
+			flags:      UntilEOF,
+			delimiters: []string{"-->"},
+			wantValue:  "<!-- a",
+			wantError:  ErrCommit,
+		},
+		{
+			name:       "nothing before delimiter",
+			input:      "-->",
+			delimiters: []string{"-->"},
+			wantError:  ErrRollback,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := message.Slice[Token]()
+			builder := makeTestBuilder(receiver)
+			state := builder.UntilString(tc.flags, tc.delimiters...).Emit(Token1)
+			source := xio.New(builder.logger, bytes.NewBufferString(tc.input))
+			err := state.Update(WithNextTokenLevel(context.Background()), source.Begin().Deref())
+			assert.ErrorIs(t, err, tc.wantError)
+			if tc.wantError != ErrCommit {
+				assert.Empty(t, receiver.Slice)
+				return
+			}
+			if assert.Len(t, receiver.Slice, 1) {
+				assert.Equal(t, tc.wantValue, receiver.Slice[0].AsString())
+			}
+		})
+	}
+}
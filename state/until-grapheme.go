@@ -0,0 +1,76 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+
+	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xio"
+)
+
+// UntilGrapheme is a state that reads extended grapheme clusters until the given function returns true.
+// The count of read grapheme clusters is limited by the quantifier.
+type UntilGrapheme[T any] struct {
+	logger common.Logger
+	pred   GraphemePredicate
+	q      Quantifier
+}
+
+// newUntilGrapheme creates a new state that reads grapheme clusters until the given function returns true.
+func newUntilGrapheme[T any](logger common.Logger, pred GraphemePredicate, q Quantifier) *UntilGrapheme[T] {
+	return &UntilGrapheme[T]{
+		logger: logger,
+		pred:   pred,
+		q:      q,
+	}
+}
+
+// Update implements the State interface. It reads grapheme clusters until the given function
+// returns true or the quantifier max is reached.
+func (ug UntilGrapheme[T]) Update(ctx context.Context, tx xio.State) (err error) {
+	count := uint(0)
+	for count < ug.q.max {
+		g, gw, nextErr := tx.NextGrapheme()
+		if nextErr != nil && !errors.Is(nextErr, io.EOF) {
+			err = nextErr
+			return
+		}
+		if errors.Is(nextErr, io.EOF) && gw == 0 {
+			break
+		}
+		if ug.pred(g) {
+			_, err = tx.Unread()
+			common.AssertNoError(err, "unread error")
+			break
+		}
+		count++
+	}
+	err = ug.q.makeResult(count)
+	return
+}
+
+// UntilGrapheme creates a state that reads grapheme clusters until the pred returns true.
+func (b Builder[T]) UntilGrapheme(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	q := CountBetween(1, math.MaxUint)
+	tail = b.append("UntilGrapheme", func() Update[T] { return newUntilGrapheme[T](b.logger, pred, q) })
+	return
+}
+
+// WhileGrapheme creates a state that reads grapheme clusters while the pred returns true.
+func (b Builder[T]) WhileGrapheme(pred GraphemePredicate) (tail *Chain[T]) {
+	common.AssertNotNil(pred, "invalid grammar: nil predicate")
+	q := CountBetween(1, math.MaxUint)
+	tail = b.append("WhileGrapheme", func() Update[T] { return newUntilGrapheme[T](b.logger, Not(pred), q) })
+	return
+}
+
+// Graphemes creates a state that reads any grapheme clusters, their count must satisfy the quantifier.
+// It is useful for the width limited fields, where the width is measured in user perceived characters.
+func (b Builder[T]) Graphemes(q Quantifier) (tail *Chain[T]) {
+	common.AssertTrue(q.isValid(), "invalid grammar: invalid quantifier: %s", q)
+	tail = b.append("Graphemes", func() Update[T] { return newUntilGrapheme[T](b.logger, False[string](), q) })
+	return
+}
@@ -44,6 +44,10 @@ func (m *XioStateMock) NextRune() (r rune, w int, err error) {
 	args := m.Called()
 	return args.Get(0).(rune), args.Get(1).(int), args.Error(2)
 }
+func (m *XioStateMock) NextGrapheme() (g string, w int, err error) {
+	args := m.Called()
+	return args.String(0), args.Int(1), args.Error(2)
+}
 func (m *XioStateMock) Read(p []byte) (n int, err error) {
 	args := m.Called()
 	return args.Get(0).(int), args.Error(1)
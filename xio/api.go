@@ -50,6 +50,14 @@ type (
 		NextRune() (r rune, w int, err error)
 	}
 
+	// NextGrapheme returns next extended grapheme cluster from the state.
+	// It is read operation.
+	NextGrapheme interface {
+		// NextGrapheme returns next extended grapheme cluster from the state
+		// and its width in bytes.
+		NextGrapheme() (g string, w int, err error)
+	}
+
 	// Has returns true if there are any bytes in state.
 	// It is non-read operation.
 	Has interface {
@@ -64,6 +72,40 @@ type (
 		Data() (data []byte, pos int64, err error)
 	}
 
+	// Offset returns the current position of the state.
+	Offset interface {
+		// Offset returns the current position of the state in the input.
+		Offset() int64
+	}
+
+	// Reset makes the source read from the given reader.
+	Reset interface {
+		// Reset makes the source read from the given reader from scratch.
+		Reset(reader io.Reader)
+	}
+
+	// Search searches the input for the samples.
+	Search interface {
+		// Search finds the nearest of the samples starting at the current position and
+		// advances the state to it, or past it if include is true. It returns the index of
+		// the found sample, or -1 and advances the state to the end of input if none is found.
+		Search(samples [][]byte, include bool) (index int, err error)
+	}
+
+	// Position reports the position of the state relative to the lines and the input start.
+	Position interface {
+		// Column returns the count of runes between the last line break and the current position.
+		Column() int
+		// Origin returns the offset of the input start. It is past the byte order mark if any.
+		Origin() int64
+	}
+
+	// Pending extracts read data from the state without advancing the state.
+	Pending interface {
+		// Pending returns read data from the state and its position.
+		Pending() (data []byte, pos int64, err error)
+	}
+
 	// Buffer is the interface that groups the methods for IO buffer access.
 	Buffer interface {
 		// Buffer returns the buffer and its offset. It does not affect the state.
@@ -76,6 +118,7 @@ type (
 		Unread
 		NextByte
 		NextRune
+		NextGrapheme
 		Has
 		Data
 		Buffer
@@ -116,3 +159,57 @@ func AsTx(state State) (tx Tx) {
 	}
 	return
 }
+
+// AsPending converts the given State to a Pending if it possible.
+// If the given State is not a Pending it panics.
+func AsPending(state State) (pending Pending) {
+	var i any = state
+	pending, ok := i.(Pending)
+	if !ok {
+		panic("not a Pending")
+	}
+	return
+}
+
+// AsOffset converts the given State to an Offset if it possible.
+// If the given State is not an Offset it panics.
+func AsOffset(state State) (offset Offset) {
+	var i any = state
+	offset, ok := i.(Offset)
+	if !ok {
+		panic("not an Offset")
+	}
+	return
+}
+
+// AsSearch converts the given State to a Search if it possible.
+// If the given State is not a Search it panics.
+func AsSearch(state State) (search Search) {
+	var i any = state
+	search, ok := i.(Search)
+	if !ok {
+		panic("not a Search")
+	}
+	return
+}
+
+// AsPosition converts the given State or Source to a Position if it possible.
+// If the given value is not a Position it panics.
+func AsPosition(state any) (position Position) {
+	position, ok := state.(Position)
+	if !ok {
+		panic("not a Position")
+	}
+	return
+}
+
+// AsReset converts the given Source to a Reset if it possible.
+// If the given Source is not a Reset it panics.
+func AsReset(source Source) (reset Reset) {
+	var i any = source
+	reset, ok := i.(Reset)
+	if !ok {
+		panic("not a Reset")
+	}
+	return
+}
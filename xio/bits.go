@@ -0,0 +1,98 @@
+package xio
+
+import (
+	"errors"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+)
+
+// BitOrder is the order of bits inside of the byte.
+type BitOrder int
+
+const (
+	// MSBFirst reads bits starting from the most significant bit of the byte,
+	// the first read bit is the most significant bit of the value.
+	MSBFirst BitOrder = iota
+	// LSBFirst reads bits starting from the least significant bit of the byte,
+	// the first read bit is the least significant bit of the value (e.g. DEFLATE).
+	LSBFirst
+)
+
+// MaxBits is the maximum count of bits which can be read at once.
+const MaxBits = 64
+
+// Bits is the interface that groups the methods for bit level reading.
+// Bit reads are the part of the transaction, so they are rolled back like byte reads.
+// Byte reads and Data skip the rest of the partially read byte.
+type Bits interface {
+	// NextBits reads n bits in the given order. It returns io.EOF and does not
+	// advance the state if there are less than n bits left.
+	NextBits(n int, order BitOrder) (value uint64, err error)
+	// AlignBits skips the rest of the partially read byte and returns the count of skipped bits.
+	AlignBits() (skipped int)
+	// BitOffset returns the count of the consumed bits of the current byte.
+	BitOffset() int
+}
+
+// NextBits implements Bits interface.
+func (s *state) NextBits(n int, order BitOrder) (value uint64, err error) {
+	common.AssertFalse(s.offset == -1, "transaction already complete")
+	common.AssertTrue(n > 0 && n <= MaxBits, "invalid bits count: %d", n)
+	// the bytes containing requested bits
+	data := make([]byte, (int(s.bit)+n+7)/8)
+	read, err := s.reader.ReadAt(s.offset, data)
+	if err != nil && !errors.Is(err, io.EOF) {
+		if !isFlowError(err) {
+			s.logger.Error("read error: %s", err)
+		}
+		return
+	}
+	if read < len(data) {
+		err = io.EOF
+		return
+	}
+	err = nil
+	s.mark()
+	for i := 0; i < n; i++ {
+		total := int(s.bit) + i
+		b := data[total/8]
+		shift := total % 8
+		switch order {
+		case MSBFirst:
+			value = value<<1 | uint64(b>>(7-shift)&1)
+		case LSBFirst:
+			value |= uint64(b>>shift&1) << i
+		default:
+			common.AssertUnreachable("unknown bit order: %d", order)
+		}
+	}
+	total := int(s.bit) + n
+	s.offset += int64(total / 8)
+	s.bit = uint8(total % 8)
+	return
+}
+
+// AlignBits implements Bits interface.
+func (s *state) AlignBits() (skipped int) {
+	common.AssertFalse(s.offset == -1, "transaction already complete")
+	s.mark()
+	skipped = s.align()
+	return
+}
+
+// BitOffset implements Bits interface.
+func (s *state) BitOffset() int {
+	return int(s.bit)
+}
+
+// AsBits converts the given State to a Bits if it possible.
+// If the given State is not a Bits it panics.
+func AsBits(state State) (bits Bits) {
+	var i any = state
+	bits, ok := i.(Bits)
+	if !ok {
+		panic("not a Bits")
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package xio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/diakovliev/lexer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestState_NextBits(t *testing.T) {
+	type testCase struct {
+		name      string
+		input     []byte
+		reads     []int
+		order     BitOrder
+		wantValue []uint64
+	}
+
+	tests := []testCase{
+		{
+			name:      "msb first",
+			input:     []byte{0b1010_1100, 0b0111_0000},
+			reads:     []int{1, 3, 6, 2},
+			order:     MSBFirst,
+			wantValue: []uint64{0b1, 0b010, 0b1100_01, 0b11},
+		},
+		{
+			name:      "lsb first",
+			input:     []byte{0b1010_1100, 0b0111_0000},
+			reads:     []int{2, 3, 5},
+			order:     LSBFirst,
+			wantValue: []uint64{0b00, 0b011, 0b00_101},
+		},
+		{
+			name:      "msb first 16 bits",
+			input:     []byte{0x12, 0x34},
+			reads:     []int{16},
+			order:     MSBFirst,
+			wantValue: []uint64{0x1234},
+		},
+		{
+			name:      "lsb first 16 bits",
+			input:     []byte{0x12, 0x34},
+			reads:     []int{16},
+			order:     LSBFirst,
+			wantValue: []uint64{0x3412},
+		},
+	}
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New(logger, bytes.NewBuffer(tc.input))
+			tx := r.Begin().Deref()
+			bits := AsBits(tx)
+			for i, n := range tc.reads {
+				value, err := bits.NextBits(n, tc.order)
+				assert.NoError(t, err)
+				assert.Equal(t, tc.wantValue[i], value, "read %d", i)
+			}
+			assert.NoError(t, AsTx(tx).Rollback())
+		})
+	}
+}
+
+func TestState_BitsTransactions(t *testing.T) {
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBuffer([]byte{0xF0, 'a', 'b'}))
+	tx := r.Begin().Deref()
+	bits := AsBits(tx)
+
+	value, err := bits.NextBits(2, MSBFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b11), value)
+	assert.True(t, tx.Has())
+
+	// rolled back child does not move the bit cursor
+	child := AsSource(tx).Begin().Deref()
+	_, err = AsBits(child).NextBits(4, MSBFirst)
+	assert.NoError(t, err)
+	assert.NoError(t, AsTx(child).Rollback())
+	assert.Equal(t, 2, bits.BitOffset())
+
+	// committed child propagates the bit cursor
+	child = AsSource(tx).Begin().Deref()
+	value, err = AsBits(child).NextBits(3, MSBFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b110), value)
+	assert.NoError(t, AsTx(child).Commit())
+	assert.Equal(t, 5, bits.BitOffset())
+
+	// unread restores the bit cursor
+	_, err = bits.NextBits(2, MSBFirst)
+	assert.NoError(t, err)
+	_, err = tx.Unread()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, bits.BitOffset())
+
+	// byte reads are aligned
+	b, err := tx.NextByte()
+	assert.NoError(t, err)
+	assert.Equal(t, byte('a'), b)
+	_, err = tx.Unread()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, bits.BitOffset())
+
+	// data includes the partially read byte and aligns the cursor
+	data, pos, err := tx.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pos)
+	assert.Equal(t, []byte{0xF0}, data)
+	assert.Equal(t, 0, bits.BitOffset())
+
+	// not enough bits
+	_, err = bits.NextBits(17, MSBFirst)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, bits.AlignBits())
+
+	r2, _, err := tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'a', r2)
+	assert.NoError(t, AsTx(tx).Commit())
+}
+
+func TestState_NextRuneKeepsBitsAtEOF(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBuffer([]byte{0b1010_1100}))
+	tx := r.Begin().Deref()
+	bits := AsBits(tx)
+	_, err := bits.NextBits(3, MSBFirst)
+	assert.NoError(t, err)
+
+	// the failed reads at the end of input don't discard the rest of the byte
+	_, w, err := tx.NextRune()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, w)
+	_, w, err = tx.NextGrapheme()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 0, w)
+
+	value, err := bits.NextBits(5, MSBFirst)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0b0_1100), value)
+	assert.NoError(t, AsTx(tx).Rollback())
+}
@@ -1,9 +1,93 @@
 package xio
 
-import "bytes"
+import (
+	"errors"
+	"io"
+)
 
-type buffer = bytes.Buffer
+// minBufferSize is the initial capacity of the buffer.
+const minBufferSize = 512
 
-func newBuffer(buf []byte) *buffer {
-	return bytes.NewBuffer(buf)
+// buffer is a sliding window over the input. Discarded bytes are reclaimed by
+// moving the remaining data to the beginning of the underlying array, so the
+// memory is reused across truncates instead of being reallocated.
+type buffer struct {
+	data  []byte
+	start int
+}
+
+func newBuffer() *buffer {
+	return &buffer{}
+}
+
+// Len returns the count of the buffered bytes.
+func (b buffer) Len() int {
+	return len(b.data) - b.start
+}
+
+// Cap returns the capacity of the underlying array.
+func (b buffer) Cap() int {
+	return cap(b.data)
+}
+
+// Bytes returns the buffered bytes. The returned slice is valid until the next
+// buffer modification.
+func (b buffer) Bytes() []byte {
+	return b.data[b.start:]
+}
+
+// Discard discards n bytes from the beginning of the buffer.
+func (b *buffer) Discard(n int) {
+	b.start += n
+	if b.start >= len(b.data) {
+		b.data = b.data[:0]
+		b.start = 0
+	}
+}
+
+// Grow ensures that the buffer can append n bytes without reallocation.
+func (b *buffer) Grow(n int) {
+	l := b.Len()
+	if cap(b.data)-len(b.data) >= n {
+		return
+	}
+	if cap(b.data)-l >= n {
+		// enough room after compaction
+		copy(b.data, b.data[b.start:])
+		b.data = b.data[:l]
+		b.start = 0
+		return
+	}
+	data := make([]byte, l, max(2*cap(b.data), l+n, minBufferSize))
+	copy(data, b.data[b.start:])
+	b.data = data
+	b.start = 0
+}
+
+// Fill appends up to n bytes read from the reader. It returns io.EOF if
+// less than n bytes were read.
+func (b *buffer) Fill(r io.Reader, n int) (read int, err error) {
+	if n <= 0 {
+		return
+	}
+	b.Grow(n)
+	l := len(b.data)
+	read, err = io.ReadFull(r, b.data[l:l+n])
+	b.data = b.data[:l+read]
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return
+}
+
+// Append appends the data to the buffer.
+func (b *buffer) Append(data []byte) {
+	b.Grow(len(data))
+	b.data = append(b.data, data...)
+}
+
+// Reset discards all data, the memory is kept for reuse.
+func (b *buffer) Reset() {
+	b.data = b.data[:0]
+	b.start = 0
 }
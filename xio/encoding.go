@@ -0,0 +1,292 @@
+package xio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type (
+	// Encoding decodes runes from the input bytes.
+	Encoding interface {
+		// Name returns the encoding name.
+		Name() string
+		// DecodeRune decodes the first rune of p and returns it and its width in bytes.
+		// If p contains invalid or incomplete encoded rune it returns utf8.RuneError and
+		// the count of the bytes to skip.
+		DecodeRune(p []byte) (r rune, w int)
+		// MaxRuneLen returns the maximum width of the encoded rune in bytes.
+		MaxRuneLen() int
+	}
+
+	// Encoded is the interface that wraps the Encoding method.
+	Encoded interface {
+		// Encoding returns the encoding used to decode runes.
+		Encoding() Encoding
+	}
+
+	// Reencode is the interface that wraps the SetEncoding method.
+	Reencode interface {
+		// SetEncoding sets the encoding used to decode runes.
+		SetEncoding(enc Encoding)
+	}
+
+	utf8Encoding struct{}
+
+	utf16Encoding struct {
+		name  string
+		order binary.ByteOrder
+	}
+
+	utf32Encoding struct {
+		name  string
+		order binary.ByteOrder
+	}
+
+	// singleByteEncoding maps bytes 0x80..0xFF to runes, the lower half is ASCII.
+	singleByteEncoding struct {
+		name  string
+		upper *[128]rune
+	}
+)
+
+var (
+	// UTF8 is the UTF-8 encoding. It is the default encoding.
+	UTF8 Encoding = utf8Encoding{}
+	// UTF16LE is the little endian UTF-16 encoding.
+	UTF16LE Encoding = utf16Encoding{name: "UTF-16LE", order: binary.LittleEndian}
+	// UTF16BE is the big endian UTF-16 encoding.
+	UTF16BE Encoding = utf16Encoding{name: "UTF-16BE", order: binary.BigEndian}
+	// UTF32LE is the little endian UTF-32 encoding.
+	UTF32LE Encoding = utf32Encoding{name: "UTF-32LE", order: binary.LittleEndian}
+	// UTF32BE is the big endian UTF-32 encoding.
+	UTF32BE Encoding = utf32Encoding{name: "UTF-32BE", order: binary.BigEndian}
+	// Latin1 is the ISO-8859-1 encoding.
+	Latin1 Encoding = singleByteEncoding{name: "ISO-8859-1", upper: &latin1Upper}
+	// Windows1252 is the Windows-1252 encoding.
+	Windows1252 Encoding = singleByteEncoding{name: "Windows-1252", upper: &windows1252Upper}
+
+	// boms is the list of known byte order marks. The order matters: UTF-32LE BOM
+	// starts with UTF-16LE BOM.
+	boms = []struct {
+		bom      []byte
+		encoding Encoding
+	}{
+		{bom: []byte{0x00, 0x00, 0xFE, 0xFF}, encoding: UTF32BE},
+		{bom: []byte{0xFF, 0xFE, 0x00, 0x00}, encoding: UTF32LE},
+		{bom: []byte{0xEF, 0xBB, 0xBF}, encoding: UTF8},
+		{bom: []byte{0xFE, 0xFF}, encoding: UTF16BE},
+		{bom: []byte{0xFF, 0xFE}, encoding: UTF16LE},
+	}
+
+	// encodings maps normalized encoding names and aliases to encodings.
+	encodings = map[string]Encoding{
+		"utf8":        UTF8,
+		"usascii":     UTF8,
+		"ascii":       UTF8,
+		"utf16":       UTF16BE,
+		"utf16le":     UTF16LE,
+		"utf16be":     UTF16BE,
+		"utf32":       UTF32BE,
+		"utf32le":     UTF32LE,
+		"utf32be":     UTF32BE,
+		"iso88591":    Latin1,
+		"latin1":      Latin1,
+		"l1":          Latin1,
+		"windows1252": Windows1252,
+		"cp1252":      Windows1252,
+	}
+
+	latin1Upper      = makeLatin1Upper()
+	windows1252Upper = makeWindows1252Upper()
+)
+
+// maxBOMLen is the maximum length of the byte order mark.
+const maxBOMLen = 4
+
+func makeLatin1Upper() (ret [128]rune) {
+	for i := range ret {
+		ret[i] = rune(0x80 + i)
+	}
+	return
+}
+
+func makeWindows1252Upper() (ret [128]rune) {
+	ret = makeLatin1Upper()
+	// 0x80..0x9F differ from ISO-8859-1, undefined bytes are mapped to C1 controls
+	copy(ret[:0x20], []rune{
+		'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+		0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+	})
+	return
+}
+
+// Name implements Encoding interface.
+func (utf8Encoding) Name() string {
+	return "UTF-8"
+}
+
+// DecodeRune implements Encoding interface.
+func (utf8Encoding) DecodeRune(p []byte) (r rune, w int) {
+	return utf8.DecodeRune(p)
+}
+
+// MaxRuneLen implements Encoding interface.
+func (utf8Encoding) MaxRuneLen() int {
+	return utf8.UTFMax
+}
+
+// Name implements Encoding interface.
+func (e utf16Encoding) Name() string {
+	return e.name
+}
+
+// DecodeRune implements Encoding interface.
+func (e utf16Encoding) DecodeRune(p []byte) (r rune, w int) {
+	if len(p) < 2 {
+		return utf8.RuneError, len(p)
+	}
+	r1 := rune(e.order.Uint16(p))
+	if !utf16.IsSurrogate(r1) {
+		return r1, 2
+	}
+	if len(p) < 4 {
+		return utf8.RuneError, 2
+	}
+	r = utf16.DecodeRune(r1, rune(e.order.Uint16(p[2:])))
+	if r == utf8.RuneError {
+		return utf8.RuneError, 2
+	}
+	return r, 4
+}
+
+// MaxRuneLen implements Encoding interface.
+func (utf16Encoding) MaxRuneLen() int {
+	return 4
+}
+
+// Name implements Encoding interface.
+func (e utf32Encoding) Name() string {
+	return e.name
+}
+
+// DecodeRune implements Encoding interface.
+func (e utf32Encoding) DecodeRune(p []byte) (r rune, w int) {
+	if len(p) < 4 {
+		return utf8.RuneError, len(p)
+	}
+	r = rune(e.order.Uint32(p))
+	if !utf8.ValidRune(r) {
+		r = utf8.RuneError
+	}
+	return r, 4
+}
+
+// MaxRuneLen implements Encoding interface.
+func (utf32Encoding) MaxRuneLen() int {
+	return 4
+}
+
+// Name implements Encoding interface.
+func (e singleByteEncoding) Name() string {
+	return e.name
+}
+
+// DecodeRune implements Encoding interface.
+func (e singleByteEncoding) DecodeRune(p []byte) (r rune, w int) {
+	if len(p) == 0 {
+		return utf8.RuneError, 0
+	}
+	if p[0] < utf8.RuneSelf {
+		return rune(p[0]), 1
+	}
+	return e.upper[p[0]-utf8.RuneSelf], 1
+}
+
+// MaxRuneLen implements Encoding interface.
+func (singleByteEncoding) MaxRuneLen() int {
+	return 1
+}
+
+// DetectBOM detects the encoding by the byte order mark at the start of p.
+// It returns the detected encoding and the BOM length, or nil and 0 if there is no BOM.
+func DetectBOM(p []byte) (enc Encoding, n int) {
+	for _, b := range boms {
+		if bytes.HasPrefix(p, b.bom) {
+			enc = b.encoding
+			n = len(b.bom)
+			return
+		}
+	}
+	return
+}
+
+// ToUTF8 converts the data encoded by the given encoding to UTF-8.
+// If the encoding is UTF-8 the data is returned as is.
+func ToUTF8(enc Encoding, data []byte) (ret []byte) {
+	if enc == UTF8 {
+		ret = data
+		return
+	}
+	ret = make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, w := enc.DecodeRune(data)
+		ret = utf8.AppendRune(ret, r)
+		data = data[w:]
+	}
+	return
+}
+
+// fullRune returns true if p begins with a complete encoded rune, valid or not, so
+// more bytes can't change the decoded rune.
+func fullRune(enc Encoding, p []byte) bool {
+	if _, ok := enc.(utf8Encoding); ok {
+		return utf8.FullRune(p)
+	}
+	return len(p) >= enc.MaxRuneLen()
+}
+
+// asciiCompatible returns true if the line break is always the single '\n' byte in the encoding.
+func asciiCompatible(enc Encoding) bool {
+	switch enc.(type) {
+	case utf8Encoding, singleByteEncoding:
+		return true
+	}
+	return false
+}
+
+// EncodingOf returns the encoding of the given state or UTF8 if the state does not
+// implement Encoded interface.
+func EncodingOf(state any) Encoding {
+	if encoded, ok := state.(Encoded); ok {
+		return encoded.Encoding()
+	}
+	return UTF8
+}
+
+// LookupEncoding returns the encoding by its name or alias, e.g. "utf-8", "UTF-16LE",
+// "latin1" or "cp1252". Names are case insensitive, '-' and '_' are ignored.
+func LookupEncoding(name string) (enc Encoding, ok bool) {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, strings.TrimSpace(name))
+	enc, ok = encodings[normalized]
+	return
+}
+
+// AsReencode converts the given State to a Reencode if it possible.
+// If the given State is not a Reencode it panics.
+func AsReencode(state State) (reencode Reencode) {
+	var i any = state
+	reencode, ok := i.(Reencode)
+	if !ok {
+		panic("not a Reencode")
+	}
+	return
+}
@@ -0,0 +1,164 @@
+package xio
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/diakovliev/lexer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding_DecodeRune(t *testing.T) {
+	type testCase struct {
+		name     string
+		encoding Encoding
+		input    []byte
+		wantRune rune
+		wantW    int
+	}
+
+	tests := []testCase{
+		{name: "utf8", encoding: UTF8, input: []byte("é"), wantRune: 'é', wantW: 2},
+		{name: "utf16le", encoding: UTF16LE, input: []byte{0xE9, 0x00}, wantRune: 'é', wantW: 2},
+		{name: "utf16be", encoding: UTF16BE, input: []byte{0x00, 0xE9}, wantRune: 'é', wantW: 2},
+		{name: "utf16le surrogates", encoding: UTF16LE, input: []byte{0x3D, 0xD8, 0x4D, 0xDC}, wantRune: '\U0001F44D', wantW: 4},
+		{name: "utf16be surrogates", encoding: UTF16BE, input: []byte{0xD8, 0x3D, 0xDC, 0x4D}, wantRune: '\U0001F44D', wantW: 4},
+		{name: "utf16le lone surrogate", encoding: UTF16LE, input: []byte{0x3D, 0xD8, 0x41, 0x00}, wantRune: utf8.RuneError, wantW: 2},
+		{name: "utf16le truncated", encoding: UTF16LE, input: []byte{0x41}, wantRune: utf8.RuneError, wantW: 1},
+		{name: "utf32le", encoding: UTF32LE, input: []byte{0x4D, 0xF4, 0x01, 0x00}, wantRune: '\U0001F44D', wantW: 4},
+		{name: "utf32be", encoding: UTF32BE, input: []byte{0x00, 0x01, 0xF4, 0x4D}, wantRune: '\U0001F44D', wantW: 4},
+		{name: "utf32be invalid", encoding: UTF32BE, input: []byte{0x00, 0x11, 0x00, 0x00}, wantRune: utf8.RuneError, wantW: 4},
+		{name: "latin1", encoding: Latin1, input: []byte{0xE9}, wantRune: 'é', wantW: 1},
+		{name: "latin1 c1", encoding: Latin1, input: []byte{0x80}, wantRune: 0x80, wantW: 1},
+		{name: "windows1252 euro", encoding: Windows1252, input: []byte{0x80}, wantRune: '€', wantW: 1},
+		{name: "windows1252 upper half", encoding: Windows1252, input: []byte{0xE9}, wantRune: 'é', wantW: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r, w := tc.encoding.DecodeRune(tc.input)
+			assert.Equal(t, tc.wantRune, r)
+			assert.Equal(t, tc.wantW, w)
+		})
+	}
+}
+
+func TestDetectBOM(t *testing.T) {
+	type testCase struct {
+		name  string
+		input []byte
+		want  Encoding
+		wantN int
+	}
+
+	tests := []testCase{
+		{name: "no bom", input: []byte("abc")},
+		{name: "utf8", input: []byte{0xEF, 0xBB, 0xBF, 'a'}, want: UTF8, wantN: 3},
+		{name: "utf16le", input: []byte{0xFF, 0xFE, 'a', 0x00}, want: UTF16LE, wantN: 2},
+		{name: "utf16be", input: []byte{0xFE, 0xFF, 0x00, 'a'}, want: UTF16BE, wantN: 2},
+		{name: "utf32le", input: []byte{0xFF, 0xFE, 0x00, 0x00}, want: UTF32LE, wantN: 4},
+		{name: "utf32be", input: []byte{0x00, 0x00, 0xFE, 0xFF}, want: UTF32BE, wantN: 4},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			enc, n := DetectBOM(tc.input)
+			assert.Equal(t, tc.want, enc)
+			assert.Equal(t, tc.wantN, n)
+		})
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	assert.Equal(t, []byte("héllo"), ToUTF8(UTF8, []byte("héllo")))
+	assert.Equal(t, []byte("hé"), ToUTF8(UTF16BE, []byte{0x00, 'h', 0x00, 0xE9}))
+	assert.Equal(t, []byte("€é"), ToUTF8(Windows1252, []byte{0x80, 0xE9}))
+}
+
+func TestXio_BOMDetection(t *testing.T) {
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	input := []byte{0xFF, 0xFE, 'h', 0x00, 0xE9, 0x00}
+	r := New(logger, bytes.NewBuffer(input), WithBOMDetection())
+
+	tx := r.Begin().Deref()
+	assert.Equal(t, UTF16LE, EncodingOf(tx))
+	for _, want := range []rune{'h', 'é'} {
+		got, w, err := tx.NextRune()
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, 2, w)
+	}
+	_, _, err := tx.NextRune()
+	assert.ErrorIs(t, err, io.EOF)
+
+	// positions are the original byte offsets
+	data, pos, err := tx.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), pos)
+	assert.Equal(t, input[2:], data)
+	assert.NoError(t, AsTx(tx).Commit())
+}
+
+func TestLookupEncoding(t *testing.T) {
+	for name, want := range map[string]Encoding{
+		"utf-8":        UTF8,
+		"UTF-16LE":     UTF16LE,
+		" utf_16be ":   UTF16BE,
+		"ISO-8859-1":   Latin1,
+		"Windows-1252": Windows1252,
+	} {
+		got, ok := LookupEncoding(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, ok := LookupEncoding("koi8-r")
+	assert.False(t, ok)
+}
+
+func TestState_SetEncoding(t *testing.T) {
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	input := []byte{'a', 'b', 0x00, 'c', 0x00}
+	r := New(logger, bytes.NewBuffer(input))
+
+	tx := r.Begin().Deref()
+	got, _, err := tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'a', got)
+
+	// rolled back child does not change the parent encoding
+	child := AsSource(tx).Begin().Deref()
+	AsReencode(child).SetEncoding(UTF16LE)
+	assert.NoError(t, AsTx(child).Rollback())
+	assert.Equal(t, UTF8, EncodingOf(tx))
+
+	// committed child propagates the encoding to the parent
+	child = AsSource(tx).Begin().Deref()
+	AsReencode(child).SetEncoding(UTF16LE)
+	assert.NoError(t, AsTx(child).Commit())
+	assert.Equal(t, UTF16LE, EncodingOf(tx))
+
+	for _, want := range []rune{'b', 'c'} {
+		got, w, err := tx.NextRune()
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, 2, w)
+	}
+
+	// committed root propagates the encoding to the next transactions
+	assert.NoError(t, AsTx(tx).Commit())
+	assert.Equal(t, UTF16LE, r.Encoding())
+	assert.Equal(t, UTF16LE, EncodingOf(r.Begin().Deref()))
+}
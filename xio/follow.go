@@ -0,0 +1,199 @@
+package xio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is the default interval between checks of the followed file.
+const DefaultPollInterval = 250 * time.Millisecond
+
+// followChunkSize is the maximum size of the chunk returned by Follower.Next.
+const followChunkSize = 32 * 1024
+
+type (
+	// Follower follows a growing file, like tail -f. It waits for new data instead
+	// of reporting the end of the file, and restarts from the beginning of the file
+	// if the file is truncated or rotated.
+	Follower struct {
+		path     string
+		interval time.Duration
+		notify   <-chan struct{}
+		file     *os.File
+		info     os.FileInfo
+		offset   int64
+		restart  bool
+		buf      []byte
+		done     chan struct{}
+		once     sync.Once
+	}
+
+	// FollowOption is a function that modifies the Follower behavior.
+	FollowOption func(*Follower)
+)
+
+// WithPollInterval sets the interval between checks of the followed file.
+func WithPollInterval(interval time.Duration) FollowOption {
+	return func(f *Follower) {
+		f.interval = interval
+	}
+}
+
+// WithNotify sets the channel which signals that the followed file was changed.
+// The file is checked on each signal in addition to the polling.
+func WithNotify(notify <-chan struct{}) FollowOption {
+	return func(f *Follower) {
+		f.notify = notify
+	}
+}
+
+// Follow creates a new follower of the file at the given path. The file may not
+// exist yet, it is opened as soon as it appears.
+func Follow(path string, opts ...FollowOption) (ret *Follower) {
+	ret = &Follower{
+		path:     path,
+		interval: DefaultPollInterval,
+		buf:      make([]byte, followChunkSize),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return
+}
+
+// Close stops following. Next returns the rest of the file data and then io.EOF.
+// It is safe to call Close concurrently with Next.
+func (f *Follower) Close() {
+	f.once.Do(func() { close(f.done) })
+}
+
+// open opens the followed file. It returns false if the file doesn't exist.
+func (f *Follower) open() (ok bool, err error) {
+	file, err := os.Open(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return
+	}
+	f.file = file
+	f.info = info
+	f.offset = 0
+	ok = true
+	return
+}
+
+// release closes the followed file.
+func (f *Follower) release() {
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+}
+
+// check detects the truncation and the rotation of the followed file.
+func (f *Follower) check() (err error) {
+	info, err := os.Stat(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		// rotated, the new file is not created yet
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	switch {
+	case !os.SameFile(f.info, info):
+		f.release()
+		f.restart = true
+	case info.Size() < f.offset:
+		f.offset = 0
+		f.restart = true
+	}
+	return
+}
+
+// wait waits for the next check. It returns io.EOF if the follower is closed.
+func (f *Follower) wait(ctx context.Context) (err error) {
+	timer := time.NewTimer(f.interval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-f.done:
+		err = io.EOF
+	case <-f.notify:
+	case <-timer.C:
+	}
+	return
+}
+
+// Next returns the next chunk of the followed file. It blocks until new data are
+// available, the follower is closed or the context is done. If restart is true,
+// the file was truncated or rotated and the chunk is the beginning of the new content.
+// It returns io.EOF when all data are returned after Close. The data are valid until
+// the next call.
+func (f *Follower) Next(ctx context.Context) (data []byte, restart bool, err error) {
+	closed := false
+	for {
+		if f.file == nil {
+			var ok bool
+			if ok, err = f.open(); err != nil {
+				return
+			}
+			if !ok && closed {
+				err = io.EOF
+				return
+			}
+		}
+		if f.file != nil {
+			var n int
+			n, err = f.file.ReadAt(f.buf, f.offset)
+			if err != nil && !errors.Is(err, io.EOF) {
+				return
+			}
+			err = nil
+			if n > 0 {
+				f.offset += int64(n)
+				data = f.buf[:n]
+				restart = f.restart
+				f.restart = false
+				return
+			}
+			if closed {
+				f.release()
+				err = io.EOF
+				return
+			}
+			if err = f.check(); err != nil {
+				return
+			}
+			if f.restart {
+				// read the new content immediately
+				continue
+			}
+		}
+		err = f.wait(ctx)
+		if errors.Is(err, io.EOF) {
+			// read the rest of the data before the end
+			closed = true
+			err = nil
+			continue
+		}
+		if err != nil {
+			f.release()
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package xio
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFollower(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	follower := Follow(path, WithPollInterval(time.Millisecond))
+
+	// the file is created later
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, os.WriteFile(path, []byte("first"), 0o644))
+	}()
+	data, restart, err := follower.Next(ctx)
+	assert.NoError(t, err)
+	assert.False(t, restart)
+	assert.Equal(t, "first", string(data))
+
+	// rotation
+	assert.NoError(t, os.Rename(path, filepath.Join(dir, "app.log.1")))
+	assert.NoError(t, os.WriteFile(path, []byte("second"), 0o644))
+	data, restart, err = follower.Next(ctx)
+	assert.NoError(t, err)
+	assert.True(t, restart)
+	assert.Equal(t, "second", string(data))
+
+	// truncation
+	assert.NoError(t, os.WriteFile(path, []byte("3rd"), 0o644))
+	data, restart, err = follower.Next(ctx)
+	assert.NoError(t, err)
+	assert.True(t, restart)
+	assert.Equal(t, "3rd", string(data))
+
+	follower.Close()
+	_, _, err = follower.Next(ctx)
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,66 @@
+package xio
+
+import (
+	"io"
+	"os"
+)
+
+// MappedFile is a read only file mapped into memory. It implements io.ReaderAt.
+// Use NewBytes with the Bytes result to lex the file without copying it.
+type MappedFile struct {
+	data  []byte
+	unmap func([]byte) error
+}
+
+// Mmap maps the file at the given path into memory. On platforms without mmap
+// support the file is read into memory. The returned file must be closed.
+func Mmap(path string) (ret *MappedFile, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return
+	}
+	ret, err = mmap(file, info.Size())
+	return
+}
+
+// Bytes returns the mapped data. The data are valid until Close is called.
+func (f *MappedFile) Bytes() []byte {
+	return f.data
+}
+
+// Len returns the size of the mapped data.
+func (f *MappedFile) Len() int {
+	return len(f.data)
+}
+
+// ReadAt implements io.ReaderAt interface.
+func (f *MappedFile) ReadAt(out []byte, off int64) (n int, err error) {
+	if off < 0 {
+		err = os.ErrInvalid
+		return
+	}
+	if off >= int64(len(f.data)) {
+		err = io.EOF
+		return
+	}
+	n = copy(out, f.data[off:])
+	if n < len(out) {
+		err = io.EOF
+	}
+	return
+}
+
+// Close unmaps the file.
+func (f *MappedFile) Close() (err error) {
+	if f.data == nil || f.unmap == nil {
+		return
+	}
+	err = f.unmap(f.data)
+	f.data = nil
+	return
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package xio
+
+import (
+	"io"
+	"os"
+)
+
+func mmap(file *os.File, size int64) (ret *MappedFile, err error) {
+	data := make([]byte, size)
+	if _, err = io.ReadFull(file, data); err != nil {
+		return
+	}
+	ret = &MappedFile{data: data}
+	return
+}
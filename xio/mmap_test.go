@@ -0,0 +1,66 @@
+package xio
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diakovliev/lexer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewBytes(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+	input := []byte("\xef\xbb\xbfhello world")
+	source := NewBytes(logger, input, WithBOMDetection())
+	tx := source.Begin().Deref()
+	out := make([]byte, 5)
+	n, err := tx.Read(out)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	data, pos, err := tx.Data()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), pos)
+	assert.Equal(t, "hello", string(data))
+	assert.Same(t, &input[3], &data[0])
+	// the data can't be appended over the input
+	assert.Equal(t, len(data), cap(data))
+	assert.NoError(t, AsTx(tx).Commit())
+	tx = source.Begin().Deref()
+	n, err = tx.Read(make([]byte, 10))
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, 6, n)
+	assert.NoError(t, AsTx(tx).Rollback())
+	assert.Equal(t, "\xef\xbb\xbfhello world", string(input))
+}
+
+func TestMmap(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "input.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("hello world"), 0o644))
+	mapped, err := Mmap(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 11, mapped.Len())
+	out := make([]byte, 8)
+	n, err := mapped.ReadAt(out, 6)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, "world", string(out[:n]))
+	assert.NoError(t, mapped.Close())
+	assert.Nil(t, mapped.Bytes())
+
+	empty := filepath.Join(dir, "empty.txt")
+	assert.NoError(t, os.WriteFile(empty, nil, 0o644))
+	mapped, err = Mmap(empty)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, mapped.Len())
+		assert.NoError(t, mapped.Close())
+	}
+	_, err = Mmap(filepath.Join(dir, "missing.txt"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package xio
+
+import (
+	"errors"
+	"math"
+	"os"
+	"syscall"
+)
+
+var errFileTooLarge = errors.New("file too large to map")
+
+func mmap(file *os.File, size int64) (ret *MappedFile, err error) {
+	if size == 0 {
+		ret = &MappedFile{}
+		return
+	}
+	if size > math.MaxInt {
+		err = errFileTooLarge
+		return
+	}
+	data, err := syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return
+	}
+	ret = &MappedFile{data: data, unmap: syscall.Munmap}
+	return
+}
@@ -0,0 +1,55 @@
+package xio
+
+// Option is a function that modifies the Xio behavior.
+type Option func(*Xio)
+
+// WithEncoding sets the encoding used to decode runes. The default encoding is UTF8.
+func WithEncoding(enc Encoding) Option {
+	return func(r *Xio) {
+		r.encoding = enc
+	}
+}
+
+// WithBOMDetection enables the byte order mark detection. If the input starts with a
+// known BOM, the encoding is set accordingly and the BOM is skipped. Positions are
+// still reported as the original byte offsets.
+func WithBOMDetection() Option {
+	return func(r *Xio) {
+		r.detectBOM = true
+	}
+}
+
+// WithMaxBuffered sets the maximum count of the bytes kept in the buffer. Reads
+// requiring more buffered data fail with ErrLookaheadExceeded. Zero means no limit.
+func WithMaxBuffered(size int64) Option {
+	return func(r *Xio) {
+		r.maxBuffered = size
+	}
+}
+
+// WithMaxLookahead sets the maximum count of the bytes which transactions can read
+// ahead of the oldest active snapshot or the last committed offset. Reads beyond the limit fail with
+// ErrLookaheadExceeded. Zero means no limit.
+func WithMaxLookahead(size int64) Option {
+	return func(r *Xio) {
+		r.maxLookahead = size
+	}
+}
+
+// WithStartOffset sets the offset of the first byte read from the reader. It is used to
+// continue reading from the middle of the input, the positions are reported relative to
+// the beginning of the input. The byte order mark detection is not applied in this case.
+func WithStartOffset(offset int64) Option {
+	return func(r *Xio) {
+		r.pos = offset
+		r.offset = offset
+	}
+}
+
+// WithStartColumn sets the column of the start offset, it is used with WithStartOffset
+// to continue counting the columns from the middle of the line.
+func WithStartColumn(column int) Option {
+	return func(r *Xio) {
+		r.column = column
+	}
+}
@@ -0,0 +1,27 @@
+package xio
+
+import (
+	"github.com/diakovliev/lexer/common"
+)
+
+// NewPush creates new Xio instance which data are pushed by Feed calls. Reads beyond
+// the fed data fail with ErrNeedMore until Close is called, after that they fail
+// with io.EOF.
+func NewPush(logger common.Logger, opts ...Option) (ret *Xio) {
+	ret = New(logger, nil, opts...)
+	ret.push = true
+	return
+}
+
+// Feed appends the data to the push source. The data are copied.
+func (r *Xio) Feed(data []byte) {
+	common.AssertTrue(r.push, "not a push source")
+	common.AssertFalse(r.closed, "push source is closed")
+	r.buffer.Append(data)
+}
+
+// Close marks the end of the pushed data.
+func (r *Xio) Close() {
+	common.AssertTrue(r.push, "not a push source")
+	r.closed = true
+}
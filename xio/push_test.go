@@ -0,0 +1,77 @@
+package xio
+
+import (
+	"io"
+	"os"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/diakovliev/lexer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPush(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+	source := NewPush(logger)
+	source.Feed([]byte("a\xc3"))
+
+	tx := source.Begin().Deref()
+	r, w, err := tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'a', r)
+	assert.Equal(t, 1, w)
+	// the rune is incomplete
+	_, w, err = tx.NextRune()
+	assert.ErrorIs(t, err, ErrNeedMore)
+	assert.Equal(t, 0, w)
+	assert.True(t, tx.Has())
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	source.Feed([]byte("\xa9b"))
+	tx = source.Begin().Deref()
+	data := make([]byte, 5)
+	n, err := tx.Read(data)
+	assert.ErrorIs(t, err, ErrNeedMore)
+	assert.Equal(t, "aéb", string(data[:n]))
+	assert.NoError(t, AsTx(tx).Commit())
+	assert.False(t, source.Has())
+
+	source.Close()
+	tx = source.Begin().Deref()
+	_, err = tx.Read(data)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.False(t, tx.Has())
+	assert.NoError(t, AsTx(tx).Rollback())
+}
+
+func TestPush_IncompleteRunePrefix(t *testing.T) {
+	source := NewPush(logger.New())
+	// two bytes of the three byte rune
+	source.Feed([]byte("\xe2\x80"))
+	tx := source.Begin().Deref()
+	_, w, err := tx.NextRune()
+	assert.ErrorIs(t, err, ErrNeedMore)
+	assert.Equal(t, 0, w)
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	source.Feed([]byte("\x8d"))
+	tx = source.Begin().Deref()
+	r, w, err := tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, '\u200D', r)
+	assert.Equal(t, 3, w)
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	// the invalid byte is not completed by more bytes
+	source = NewPush(logger.New())
+	source.Feed([]byte("\xff\x80"))
+	tx = source.Begin().Deref()
+	r, w, err = tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, utf8.RuneError, r)
+	assert.Equal(t, 1, w)
+	assert.NoError(t, AsTx(tx).Rollback())
+}
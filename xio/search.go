@@ -0,0 +1,90 @@
+package xio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/diakovliev/lexer/common"
+)
+
+// searchChunkSize is the count of bytes fetched on each search step.
+const searchChunkSize = 4096
+
+// nearest returns the position and the index of the nearest of the samples in the data.
+// The first sample wins if several samples are found at the same position.
+func nearest(data []byte, samples [][]byte) (at int, index int) {
+	at, index = -1, -1
+	for i, sample := range samples {
+		found := bytes.Index(data, sample)
+		if found >= 0 && (at < 0 || found < at) {
+			at, index = found, i
+		}
+	}
+	return
+}
+
+// Search implements Search interface. It scans the buffered data directly, fetching
+// more data by chunks, so no data is copied. The last read, which can be undone by
+// Unread, is the whole search.
+func (s *state) Search(samples [][]byte, include bool) (index int, err error) {
+	common.AssertFalse(s.offset == -1, "transaction already complete")
+	common.AssertTrue(len(samples) > 0, "no samples")
+	longest := 0
+	for _, sample := range samples {
+		common.AssertTrue(len(sample) > 0, "empty sample")
+		longest = max(longest, len(sample))
+	}
+	s.mark()
+	s.align()
+	from := s.offset
+	for {
+		end := int64(s.reader.len())
+		limited := false
+		if s.reader.maxLookahead > 0 && end > s.reader.pos+s.reader.maxLookahead {
+			end = max(s.reader.pos+s.reader.maxLookahead, from)
+			limited = true
+		}
+		window, rangeErr := s.reader.Range(int(from), int(end))
+		common.AssertNoError(rangeErr, "data range error")
+		at, found := nearest(window, samples)
+		// the match is final if no longer sample can start before it and end beyond the window
+		if found >= 0 && (at+longest <= len(window) || limited) {
+			s.found(from+int64(at), samples[found], include)
+			index = found
+			return
+		}
+		if limited {
+			err = ErrLookaheadExceeded
+			s.offset = s.last.offset
+			return
+		}
+		n, fetchErr := s.reader.Fetch(searchChunkSize)
+		switch {
+		case n > 0:
+			// the sample can be split between the window and the fetched data
+			from = max(from, end-int64(longest)+1)
+		case found >= 0 && errors.Is(fetchErr, io.EOF):
+			s.found(from+int64(at), samples[found], include)
+			index = found
+			return
+		case errors.Is(fetchErr, io.EOF):
+			s.offset = end
+			index = -1
+			return
+		default:
+			// nothing is consumed on error
+			err = fetchErr
+			s.offset = s.last.offset
+			return
+		}
+	}
+}
+
+// found advances the state to the found sample, or past it if include is true.
+func (s *state) found(at int64, sample []byte, include bool) {
+	s.offset = at
+	if include {
+		s.offset += int64(len(sample))
+	}
+}
@@ -0,0 +1,117 @@
+package xio
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/diakovliev/lexer/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	type testCase struct {
+		name       string
+		source     func() *Xio
+		samples    []string
+		include    bool
+		wantIndex  int
+		wantOffset int64
+		wantError  error
+	}
+
+	long := strings.Repeat("x", 3*searchChunkSize)
+
+	tests := []testCase{
+		{
+			name:       "exclude",
+			source:     func() *Xio { return NewString(logger, "abc-->def") },
+			samples:    []string{"-->"},
+			wantIndex:  0,
+			wantOffset: 3,
+		},
+		{
+			name:       "include",
+			source:     func() *Xio { return NewString(logger, "abc-->def") },
+			samples:    []string{"-->"},
+			include:    true,
+			wantIndex:  0,
+			wantOffset: 6,
+		},
+		{
+			name:       "nearest sample",
+			source:     func() *Xio { return NewString(logger, "abc\r\n\r\ndef\n\n") },
+			samples:    []string{"\n\n", "\r\n\r\n"},
+			wantIndex:  1,
+			wantOffset: 3,
+		},
+		{
+			name:       "longer sample starting earlier",
+			source:     func() *Xio { return New(logger, iotest.OneByteReader(bytes.NewBufferString(long+"abcdef"))) },
+			samples:    []string{"e", "abcdef"},
+			wantIndex:  1,
+			wantOffset: int64(len(long)),
+		},
+		{
+			name:       "sample split between chunks",
+			source:     func() *Xio { return New(logger, bytes.NewBufferString(long[1:]+"\r\n\r\n")) },
+			samples:    []string{"\r\n\r\n"},
+			include:    true,
+			wantIndex:  0,
+			wantOffset: int64(len(long) + 3),
+		},
+		{
+			name:       "not found",
+			source:     func() *Xio { return New(logger, bytes.NewBufferString(long)) },
+			samples:    []string{"*/"},
+			wantIndex:  -1,
+			wantOffset: int64(len(long)),
+		},
+		{
+			name:      "lookahead exceeded",
+			source:    func() *Xio { return New(logger, bytes.NewBufferString(long+"*/"), WithMaxLookahead(16)) },
+			samples:   []string{"*/"},
+			wantError: ErrLookaheadExceeded,
+		},
+		{
+			name: "need more",
+			source: func() *Xio {
+				source := NewPush(logger)
+				source.Feed([]byte("abc*"))
+				return source
+			},
+			samples:   []string{"*/"},
+			wantError: ErrNeedMore,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			samples := make([][]byte, 0, len(tc.samples))
+			for _, sample := range tc.samples {
+				samples = append(samples, []byte(sample))
+			}
+			tx := tc.source().Begin().Deref()
+			index, err := AsSearch(tx).Search(samples, tc.include)
+			if tc.wantError != nil {
+				assert.ErrorIs(t, err, tc.wantError)
+				assert.Equal(t, int64(0), AsOffset(tx).Offset())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.wantIndex, index)
+			assert.Equal(t, tc.wantOffset, AsOffset(tx).Offset())
+			// the search is undone as the last read
+			_, err = tx.Unread()
+			assert.NoError(t, err)
+			assert.Equal(t, int64(0), AsOffset(tx).Offset())
+		})
+	}
+}
@@ -3,9 +3,11 @@ package xio
 import (
 	"errors"
 	"io"
+	"strings"
 	"unicode/utf8"
 
 	"github.com/diakovliev/lexer/common"
+	"github.com/diakovliev/lexer/xunicode"
 )
 
 type (
@@ -17,63 +19,117 @@ type (
 		parent *state // parent transaction
 		pos    int64  // position of the last data returned by Data()
 		offset int64  // current position
-		lastN  int    // last read bytes count
-		tx     *state // child transactions
+		bit    uint8  // count of the consumed bits of the byte at offset
+		last   cursor // position before the last read, used by Unread
+		start  int64  // position at the beginning of the transaction
+		live   int    // count of the active child transactions
+		// encoding is used to decode runes, it is inherited by child transactions
+		// and propagated to the parent on commit.
+		encoding Encoding
+	}
+
+	// cursor is a bit precise position.
+	cursor struct {
+		offset int64
+		bit    uint8
 	}
 )
 
 func newState(logger common.Logger, reader *Xio, pos int64) (ret *state) {
 	ret = &state{
-		logger: logger,
-		reader: reader,
-		pos:    pos,
-		offset: pos,
+		logger:   logger,
+		reader:   reader,
+		pos:      pos,
+		offset:   pos,
+		start:    pos,
+		encoding: reader.Encoding(),
 	}
 	return
 }
 
-// Begin starts a child transaction.
+// Begin starts a child transaction. Several child transactions can be active at the
+// same time, each of them has its own independent cursor starting at the current
+// position. The committed child moves the parent cursor to its own position.
 func (s *state) Begin() (ret common.Ref[State]) {
-	common.AssertNilPtr(s.tx, "too many transactions, Tx supports only one active child transaction")
-	s.tx = &state{
-		logger: s.logger,
-		reader: s.reader,
-		parent: s,
-		pos:    s.offset,
-		offset: s.offset,
-	}
-	ret = common.NewRef[State](s.tx)
+	common.AssertFalse(s.offset == -1, "transaction already complete")
+	s.live++
+	ret = common.NewRef[State](&state{
+		logger:   s.logger,
+		reader:   s.reader,
+		parent:   s,
+		pos:      s.offset,
+		offset:   s.offset,
+		start:    s.offset,
+		bit:      s.bit,
+		last:     cursor{offset: s.offset, bit: s.bit},
+		encoding: s.encoding,
+	})
 	return
 }
 
 func (s *state) resetTx() {
-	s.tx = nil
+	s.live--
 }
 
-func (s *state) update(offset int64) {
+func (s *state) update(offset int64, bit uint8) {
 	s.offset = offset
+	s.bit = bit
+	s.last = cursor{offset: offset, bit: bit}
 }
 
 func (s *state) reset() {
 	s.pos = -1
 	s.offset = -1
-	s.lastN = 0
+	s.bit = 0
+	s.last = cursor{}
+}
+
+// cursor returns the current position.
+func (s state) cursor() cursor {
+	return cursor{offset: s.offset, bit: s.bit}
+}
+
+// before returns true if the position is before the other one.
+func (c cursor) before(other cursor) bool {
+	return c.offset < other.offset || c.offset == other.offset && c.bit < other.bit
+}
+
+// mark remembers the current position for Unread.
+func (s *state) mark() {
+	s.last = cursor{offset: s.offset, bit: s.bit}
+}
+
+// align skips the rest of the partially read byte.
+func (s *state) align() (skipped int) {
+	if s.bit == 0 {
+		return
+	}
+	skipped = 8 - int(s.bit)
+	s.offset++
+	s.bit = 0
+	return
 }
 
 // Commit commits the transaction and returns the number of bytes read during the transaction.
 // Commit will fail if any of the child transactions are not committed or rolled back.
 func (s *state) Commit() (err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
-	common.AssertFalse(s.tx != nil && s.tx.offset != -1, "child transaction is not complete")
+	common.AssertFalse(s.live > 0, "child transaction is not complete")
 	if s.parent != nil {
+		// the sibling snapshot committed before must not be overwritten by an older position
+		common.AssertFalse(s.cursor().before(s.parent.cursor()), "snapshot is behind the committed position")
 		// update parent transaction position
-		s.parent.update(s.offset)
+		s.parent.update(s.offset, s.bit)
+		s.parent.SetEncoding(s.encoding)
 		s.parent.resetTx()
 	} else {
-		// update reader position directly if no parent transaction exists
+		// update reader position directly if no parent transaction exists,
+		// the reader position is always byte aligned
+		s.align()
+		common.AssertFalse(s.offset < s.reader.offset, "snapshot is behind the committed position")
 		s.reader.Update(s.offset)
-		common.AssertNoError(s.reader.Truncate(s.offset), "truncate error")
-		s.reader.resetTx()
+		s.reader.SetEncoding(s.encoding)
+		s.reader.release(s.start)
 	}
 	s.reset()
 	return
@@ -83,12 +139,11 @@ func (s *state) Commit() (err error) {
 // Rollback will rollback all non completed children transactions if any.
 func (s *state) Rollback() (err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
-	common.AssertFalse(s.tx != nil && s.tx.offset != -1, "child transaction is not complete")
-	// rollback child transactions first
+	common.AssertFalse(s.live > 0, "child transaction is not complete")
 	if s.parent != nil {
 		s.parent.resetTx()
 	} else {
-		s.reader.resetTx()
+		s.reader.release(s.start)
 	}
 	s.reset()
 	return
@@ -97,13 +152,11 @@ func (s *state) Rollback() (err error) {
 // Read reads data from the transaction reader into a byte slice.
 func (s *state) Read(out []byte) (n int, err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
+	s.mark()
+	s.align()
 	n, err = s.reader.ReadAt(s.offset, out)
-	if err != nil && !errors.Is(err, io.EOF) {
-		s.logger.Error("read error: %s", err)
-		return
-	}
-	s.lastN = n
-	s.offset += int64(s.lastN)
+	// n bytes are valid even if the read is limited
+	s.offset += int64(n)
 	return
 }
 
@@ -112,34 +165,64 @@ func (s *state) Read(out []byte) (n int, err error) {
 // rolled back, this function has no effect.
 func (s *state) Unread() (n int, err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
-	oldPos := s.offset
-	newPos := oldPos - int64(s.lastN)
-	s.offset = newPos
-	s.lastN = 0
+	s.offset = s.last.offset
+	s.bit = s.last.bit
 	return
 }
 
-// Data returns transaction data (reader data from offset to pos), updates pos and
-// returns data position.
-func (s *state) Data() (data []byte, pos int64, err error) {
+// Pending returns transaction data (reader data from offset to pos) and data
+// position. It does not update pos.
+func (s *state) Pending() (data []byte, pos int64, err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
 	pos = s.pos
-	data = make([]byte, s.offset-pos)
+	end := s.offset
+	if s.bit > 0 {
+		// partially read byte belongs to the data
+		end++
+	}
+	if s.reader.static && !s.reader.readOnly {
+		// no copy, the data is a sub-slice of the input
+		data, err = s.reader.Range(int(pos), int(end))
+		data = data[:len(data):len(data)]
+		return
+	}
+	data = make([]byte, end-pos)
 	n, err := s.reader.ReadAt(pos, data)
 	if err != nil {
 		data = nil
 		return
 	}
 	common.AssertTrue(n == len(data), "data len error")
+	return
+}
+
+// Data returns transaction data (reader data from offset to pos), updates pos and
+// returns data position.
+func (s *state) Data() (data []byte, pos int64, err error) {
+	if data, pos, err = s.Pending(); err != nil {
+		return
+	}
+	s.align()
 	s.pos = s.offset
+	s.mark()
 	return
 }
 
 // Has returns true if the transaction has data at pos.
 func (s *state) Has() (ret bool) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
+	if s.bit > 0 {
+		// the rest of the partially read byte
+		ret = true
+		return
+	}
 	data := make([]byte, 1)
 	_, err := s.Read(data)
+	if errors.Is(err, ErrLookaheadExceeded) || errors.Is(err, ErrNeedMore) {
+		// there is data, but it can't be read yet
+		ret = true
+		return
+	}
 	common.AssertNoErrorOrIs(err, io.EOF, "unexpected read error")
 	ret = !errors.Is(err, io.EOF)
 	if ret {
@@ -151,12 +234,9 @@ func (s *state) Has() (ret bool) {
 
 func (s *state) nextBytes(size int) (data []byte, err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
-	_, _ = s.reader.Fetch(utf8.UTFMax)
 	data = make([]byte, size)
 	n, err := s.Read(data)
-	common.AssertNoErrorOrIs(err, io.EOF, "unexpected read error")
 	data = data[:n]
-	// lastN is set by nextBytes.
 	return
 }
 
@@ -166,41 +246,122 @@ func (s *state) NextByte() (b byte, err error) {
 	if len(data) != 0 {
 		b = data[0]
 	}
-	// lastN is set by Read inside nextBytes.
+	return
+}
+
+// isFlowError returns true if the read error is the expected control flow, like the
+// reached lookahead limit or the missing data of the push source, so it is not logged.
+func isFlowError(err error) bool {
+	return errors.Is(err, ErrNeedMore) || errors.Is(err, ErrLookaheadExceeded)
+}
+
+// decodeAt decodes the rune at the given offset. It returns zero width and io.EOF
+// if there is no more data.
+func (s *state) decodeAt(offset int64) (r rune, w int, err error) {
+	encoding := s.Encoding()
+	data := make([]byte, encoding.MaxRuneLen())
+	n, err := s.reader.ReadAt(offset, data)
+	if errors.Is(err, ErrNeedMore) && n > 0 {
+		// the rune is complete if it is valid or can't be completed by more bytes
+		if r, w = encoding.DecodeRune(data[:n]); r != utf8.RuneError || fullRune(encoding, data[:n]) {
+			err = nil
+			return
+		}
+		r, w = utf8.RuneError, 0
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		if !isFlowError(err) {
+			s.logger.Error("read error: %s", err)
+		}
+		return
+	}
+	if n == 0 {
+		r = utf8.RuneError
+		w = 0
+		return
+	}
+	// we have at least one rune, the end of input will be reported by the next call
+	err = nil
+	r, w = encoding.DecodeRune(data[:n])
 	return
 }
 
 // NextRune implements NextRune interface.
 func (s *state) NextRune() (r rune, w int, err error) {
 	common.AssertFalse(s.offset == -1, "transaction already complete")
-	_, _ = s.reader.Fetch(utf8.UTFMax)
-	data := make([]byte, utf8.UTFMax+1)
-	offset := s.offset
-	var i, n int
-	for i = 1; i < utf8.UTFMax+1; i++ {
-		n, err = s.reader.ReadAt(offset, data[:i])
-		if err != nil && !errors.Is(err, io.EOF) {
-			s.logger.Error("read error: %s", err)
-			return
-		}
-		if n < i && errors.Is(err, io.EOF) {
-			r = utf8.RuneError
-			w = 0
+	last := cursor{offset: s.offset, bit: s.bit}
+	s.align()
+	r, w, err = s.decodeAt(s.offset)
+	if w == 0 {
+		// nothing is read, so the rest of the partially read byte is kept
+		s.offset, s.bit = last.offset, last.bit
+		return
+	}
+	s.last = last
+	s.offset += int64(w)
+	return
+}
+
+// NextGrapheme implements NextGrapheme interface.
+func (s *state) NextGrapheme() (g string, w int, err error) {
+	common.AssertFalse(s.offset == -1, "transaction already complete")
+	last := cursor{offset: s.offset, bit: s.bit}
+	s.align()
+	var segmenter xunicode.Segmenter
+	var builder strings.Builder
+	for {
+		r, rw, decodeErr := s.decodeAt(s.offset + int64(w))
+		if decodeErr != nil && !errors.Is(decodeErr, io.EOF) {
+			s.offset, s.bit = last.offset, last.bit
+			err = decodeErr
 			return
 		}
-		r, w = utf8.DecodeRune(data[:i])
-		common.AssertTrue(w == i, "unexpected decoded rune width")
-		if w != utf8.RuneError {
+		if rw == 0 || (segmenter.Break(r) && w > 0) {
 			break
 		}
+		builder.WriteRune(r)
+		w += rw
+	}
+	if w == 0 {
+		// nothing is read, so the rest of the partially read byte is kept
+		s.offset, s.bit = last.offset, last.bit
+		err = io.EOF
+		return
 	}
-	// offset and lastN for Unread
-	s.lastN = i
-	s.offset += int64(s.lastN)
+	g = builder.String()
+	s.last = last
+	s.offset += int64(w)
 	return
 }
 
+// Offset implements Offset interface.
+func (s state) Offset() int64 {
+	return s.offset
+}
+
+// Encoding implements Encoded interface.
+func (s state) Encoding() Encoding {
+	return s.encoding
+}
+
+// SetEncoding implements Reencode interface. The encoding is used by the
+// state and its children, and will be propagated to the parent on commit.
+func (s *state) SetEncoding(enc Encoding) {
+	common.AssertNotNil(enc, "nil encoding")
+	s.encoding = enc
+}
+
 // Buffer returns the buffer and its offset. It does not affect the state.
 func (s state) Buffer() (ret []byte, offset int64, err error) {
 	return s.reader.Buffer()
 }
+
+// Column implements Position interface.
+func (s state) Column() int {
+	return s.reader.columnAt(s.offset)
+}
+
+// Origin implements Position interface.
+func (s state) Origin() int64 {
+	return s.reader.origin
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/diakovliev/lexer/logger"
 	"github.com/stretchr/testify/assert"
@@ -74,3 +76,181 @@ func TestTx2(t *testing.T) {
 	err = tx0.(*state).Commit()
 	assert.NoError(t, err)
 }
+
+func TestNextRune(t *testing.T) {
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBufferString("aé👍\xff"))
+
+	tx := r.Begin().Deref()
+	for _, want := range []struct {
+		r rune
+		w int
+	}{{'a', 1}, {'é', 2}, {'👍', 4}, {utf8.RuneError, 1}} {
+		got, w, err := tx.NextRune()
+		assert.NoError(t, err)
+		assert.Equal(t, want.r, got)
+		assert.Equal(t, want.w, w)
+	}
+	_, w, err := tx.NextRune()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Zero(t, w)
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	// unread must undo the whole rune
+	tx = r.Begin().Deref()
+	_, _, err = tx.NextRune()
+	assert.NoError(t, err)
+	_, _, err = tx.NextRune()
+	assert.NoError(t, err)
+	_, err = tx.Unread()
+	assert.NoError(t, err)
+	got, w, err := tx.NextRune()
+	assert.NoError(t, err)
+	assert.Equal(t, 'é', got)
+	assert.Equal(t, 2, w)
+}
+
+func TestNextGrapheme(t *testing.T) {
+
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBufferString("e\u0301\U0001F44D\U0001F3FD\r\nx"))
+
+	tx := r.Begin().Deref()
+	for _, want := range []string{"e\u0301", "\U0001F44D\U0001F3FD", "\r\n", "x"} {
+		got, w, err := tx.NextGrapheme()
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, len(want), w)
+	}
+	_, w, err := tx.NextGrapheme()
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Zero(t, w)
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	// unread must undo the whole grapheme
+	tx = r.Begin().Deref()
+	_, _, err = tx.NextGrapheme()
+	assert.NoError(t, err)
+	_, _, err = tx.NextGrapheme()
+	assert.NoError(t, err)
+	_, err = tx.Unread()
+	assert.NoError(t, err)
+	got, _, err := tx.NextGrapheme()
+	assert.NoError(t, err)
+	assert.Equal(t, "\U0001F44D\U0001F3FD", got)
+}
+
+func TestState_Snapshots(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBufferString("abcdef"))
+
+	// sibling child transactions have independent cursors
+	tx := r.Begin().Deref()
+	a := AsSource(tx).Begin().Deref()
+	b := AsSource(tx).Begin().Deref()
+	_, err := a.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	_, err = b.Read(make([]byte, 4))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), AsOffset(a).Offset())
+	assert.Equal(t, int64(4), AsOffset(b).Offset())
+	// the longest one wins
+	assert.NoError(t, AsTx(a).Rollback())
+	assert.NoError(t, AsTx(b).Commit())
+	assert.Equal(t, int64(4), AsOffset(tx).Offset())
+	assert.NoError(t, AsTx(tx).Commit())
+
+	// the buffer is not truncated past the oldest live snapshot
+	old := r.Begin().Deref()
+	cur := r.Begin().Deref()
+	_, err = cur.Read(make([]byte, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, AsTx(cur).Commit())
+	assert.Equal(t, int64(4), r.pos)
+	data := make([]byte, 2)
+	n, err := old.Read(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "ef", string(data[:n]))
+	assert.NoError(t, AsTx(old).Rollback())
+	// the last snapshot is released, the buffer is truncated up to the committed offset
+	assert.Equal(t, int64(5), r.pos)
+
+	// the older snapshot can't move the committed position backwards
+	tx = r.Begin().Deref()
+	a = AsSource(tx).Begin().Deref()
+	b = AsSource(tx).Begin().Deref()
+	_, err = a.Read(make([]byte, 1))
+	assert.NoError(t, err)
+	assert.NoError(t, AsTx(a).Commit())
+	assert.Panics(t, func() { _ = AsTx(b).Commit() })
+	assert.NoError(t, AsTx(b).Rollback())
+	old = r.Begin().Deref()
+	assert.NoError(t, AsTx(tx).Commit())
+	assert.Panics(t, func() { _ = AsTx(old).Commit() })
+	assert.NoError(t, AsTx(old).Rollback())
+}
+
+func TestState_Position(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	r := New(logger, bytes.NewBufferString("ab\ncдe\nf"))
+
+	// the column is kept when the buffer is truncated by commits
+	wantColumns := []int{1, 2, 0, 1, 2, 3, 0, 1}
+	for _, want := range wantColumns {
+		tx := r.Begin().Deref()
+		assert.Equal(t, int64(0), AsPosition(tx).Origin())
+		_, _, err := tx.NextRune()
+		assert.NoError(t, err)
+		assert.Equal(t, want, AsPosition(tx).Column())
+		assert.NoError(t, AsTx(tx).Commit())
+	}
+
+	// the long line is committed byte by byte, so the runes are split by the truncates
+	line := strings.Repeat("д", columnTailSize)
+	r = New(logger, bytes.NewBufferString("x\n"+line))
+	for {
+		tx := r.Begin().Deref()
+		_, err := tx.Read(make([]byte, 1))
+		if err != nil {
+			assert.ErrorIs(t, err, io.EOF)
+			assert.NoError(t, AsTx(tx).Rollback())
+			break
+		}
+		assert.NoError(t, AsTx(tx).Commit())
+	}
+	assert.Equal(t, columnTailSize, r.Column())
+
+	// the line break is decoded in the not ASCII compatible encoding
+	r = New(logger, bytes.NewBuffer([]byte{'a', 0, '\n', 0, 'b', 0}), WithEncoding(UTF16LE))
+	for range 3 {
+		tx := r.Begin().Deref()
+		_, _, err := tx.NextRune()
+		assert.NoError(t, err)
+		assert.NoError(t, AsTx(tx).Commit())
+	}
+	assert.Equal(t, 1, r.Column())
+
+	// the byte order mark is not counted
+	r = New(logger, bytes.NewBuffer([]byte{0xEF, 0xBB, 0xBF, 'a'}), WithBOMDetection())
+	tx := r.Begin().Deref()
+	assert.Equal(t, int64(3), AsPosition(tx).Origin())
+	assert.Equal(t, 0, AsPosition(tx).Column())
+	assert.NoError(t, AsTx(tx).Rollback())
+}
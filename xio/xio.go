@@ -1,12 +1,26 @@
 package xio
 
 import (
+	"bytes"
+	"errors"
 	"io"
 	"math"
+	"unsafe"
 
 	"github.com/diakovliev/lexer/common"
 )
 
+// ErrLookaheadExceeded indicates that the read exceeds the configured maximum of the
+// buffered bytes or the maximum lookahead.
+var ErrLookaheadExceeded = errors.New("lookahead exceeded")
+
+// ErrNeedMore indicates that the push source has no more data yet, but it is not
+// closed, so the read can be retried after the next Feed.
+var ErrNeedMore = errors.New("need more data")
+
+// columnTailSize is the maximum size of the truncated data kept to count the column lazily.
+const columnTailSize = 4 << 10
+
 type (
 	// Xio is a buffered reader that allows to read from the buffer and rollback reads.
 	// It implements Source interface.
@@ -14,34 +28,146 @@ type (
 		logger common.Logger
 		reader io.Reader
 		buffer *buffer
+		// static is true if the whole input is in the buffer and there is nothing
+		// to fetch, the buffer is never reallocated in this case
+		static bool
+		// readOnly is true if the static input must not be modified, e.g. it is a string,
+		// so the data returned by transactions are copied
+		readOnly bool
+		// push is true if the data are fed by Feed instead of reading the reader,
+		// closed is true if no more data will be fed
+		push   bool
+		closed bool
 		pos    int64 // buffer position
 		offset int64 // current position in the reader, used for transactions and truncates
-		tx     *state
+		// snapshots counts the active root transactions by their start positions,
+		// the buffer is never truncated past the oldest of them
+		snapshots map[int64]int
+		// encoding is used to decode runes
+		encoding  Encoding
+		detectBOM bool
+		// maxBuffered is the maximum count of the buffered bytes, zero means no limit
+		maxBuffered int64
+		// maxLookahead is the maximum count of bytes which can be read ahead of the
+		// oldest active snapshot or the last committed offset, zero means no limit
+		maxLookahead int64
+		// opts are the options applied on creation and reset
+		opts []Option
+		// column is the count of runes between the last line break and the column tail,
+		// the tail holds the truncated data after the line break which are not counted yet
+		column int
+		tail   []byte
+		// origin is the offset of the input start, it is past the byte order mark if any
+		origin int64
 	}
 )
 
 // New creates new Xoi instance.
 // The returned reader is buffered and can be used to rollback reads.
-func New(logger common.Logger, r io.Reader) *Xio {
-	return &Xio{
-		logger: logger,
-		reader: r,
-		buffer: newBuffer([]byte{}),
-		pos:    0,
-		offset: 0,
+func New(logger common.Logger, r io.Reader, opts ...Option) (ret *Xio) {
+	ret = &Xio{
+		logger:    logger,
+		reader:    r,
+		buffer:    newBuffer(),
+		pos:       0,
+		offset:    0,
+		snapshots: map[int64]int{},
+		encoding:  UTF8,
+		opts:      opts,
+	}
+	for _, opt := range opts {
+		opt(ret)
+	}
+	return
+}
+
+// Reset implements Reset interface. The buffer memory is reused, the options are applied
+// again. All transactions must be completed before the reset.
+func (r *Xio) Reset(reader io.Reader) {
+	common.AssertFalse(r.static || r.push, "not a reader source")
+	common.AssertTrue(len(r.snapshots) == 0, "transaction is not complete")
+	r.reader = reader
+	r.buffer.Reset()
+	r.pos = 0
+	r.offset = 0
+	r.encoding = UTF8
+	r.detectBOM = false
+	r.column = 0
+	r.tail = r.tail[:0]
+	r.origin = 0
+	for _, opt := range r.opts {
+		opt(r)
 	}
 }
 
-// Begin starts a new transaction for reading from the buffered reader.
+// NewBytes creates new Xio instance reading from the given data. The data is not copied,
+// the data returned by transactions are sub-slices of it, so it must not be modified
+// while the lexer or the produced values are in use.
+func NewBytes(logger common.Logger, data []byte, opts ...Option) (ret *Xio) {
+	ret = New(logger, nil, opts...)
+	ret.buffer = &buffer{data: data[:len(data):len(data)]}
+	ret.static = true
+	return
+}
+
+// NewString creates new Xio instance reading from the given string without copying it.
+// The string memory is read only, so unlike NewBytes the data returned by transactions
+// are copies.
+func NewString(logger common.Logger, s string, opts ...Option) (ret *Xio) {
+	ret = NewBytes(logger, unsafe.Slice(unsafe.StringData(s), len(s)), opts...)
+	ret.readOnly = true
+	return
+}
+
+// skipBOM detects the encoding by the byte order mark and skips it.
+func (r *Xio) skipBOM() {
+	r.detectBOM = false
+	if r.offset > 0 {
+		// not the beginning of the input
+		return
+	}
+	_, err := r.Fetch(maxBOMLen)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, ErrLookaheadExceeded) && !errors.Is(err, ErrNeedMore) {
+		common.AssertNoError(err, "fetch error")
+	}
+	enc, n := DetectBOM(r.buffer.Bytes())
+	if enc == nil {
+		return
+	}
+	r.encoding = enc
+	r.Update(r.offset + int64(n))
+	common.AssertNoError(r.Truncate(r.offset), "truncate error")
+	// the byte order mark is not a part of the first line
+	r.column = 0
+	r.tail = r.tail[:0]
+	r.origin = r.offset
+}
+
+// Begin starts a new transaction for reading from the buffered reader. Several
+// transactions can be active at the same time, each of them is an independent
+// snapshot starting at the current offset.
 func (r *Xio) Begin() (ret common.Ref[State]) {
-	common.AssertNilPtr(r.tx, "too many transactions, Xio supports only one active transaction")
-	r.tx = newState(r.logger, r, r.offset)
-	ret = common.NewRef[State](r.tx)
+	if r.detectBOM {
+		r.skipBOM()
+	}
+	r.snapshots[r.offset]++
+	ret = common.NewRef[State](newState(r.logger, r, r.offset))
 	return
 }
 
-func (r *Xio) resetTx() {
-	r.tx = nil
+// release releases the snapshot started at the given position and truncates
+// the buffer up to the oldest active snapshot.
+func (r *Xio) release(start int64) {
+	common.AssertTrue(r.snapshots[start] > 0, "unknown snapshot")
+	r.snapshots[start]--
+	if r.snapshots[start] == 0 {
+		delete(r.snapshots, start)
+	}
+	pos := r.offset
+	for start := range r.snapshots {
+		pos = min(pos, start)
+	}
+	common.AssertNoError(r.Truncate(pos), "truncate error")
 }
 
 func (r Xio) len() (ret int) {
@@ -64,8 +190,12 @@ func (r Xio) copyTo(pos int64, out []byte) (n int, err error) {
 
 // Has returns true if the reader has more data to read.
 func (r Xio) Has() (ret bool) {
-	n, _ := r.Fetch(1)
-	ret = n == 1
+	if int64(r.len()) > r.offset {
+		ret = true
+		return
+	}
+	n, err := r.Fetch(1)
+	ret = n == 1 || errors.Is(err, ErrLookaheadExceeded)
 	return
 }
 
@@ -98,28 +228,108 @@ func (r *Xio) Truncate(pos int64) (err error) {
 	// inside transaction implementation. Transaction
 	// must update reader position before Truncate call.
 	common.AssertFalse(pos > r.offset, "out of bounds")
-	data, err := r.Range(int(pos), math.MaxInt)
-	common.AssertNoError(err, "data range error")
-	newBuffer := newBuffer(data)
-	r.buffer = newBuffer
+	// keep the column of the new buffer position for the line anchors
+	r.skipColumn(r.buffer.Bytes()[:pos-r.pos])
+	r.buffer.Discard(int(pos - r.pos))
 	r.pos = pos
 	return
 }
 
-// Fetch fetches data from the reader and appends it to the buffer if it needed.
-// Fetch ensures that the buffer contains at least `size` bytes from the reader.
+// skipColumn keeps the truncated data needed to count the column later. If the line break
+// is a single byte in the encoding, only the data after the last line break are kept. The
+// kept data are counted only if they exceed columnTailSize.
+func (r *Xio) skipColumn(data []byte) {
+	if asciiCompatible(r.encoding) {
+		if i := bytes.LastIndexByte(data, '\n'); i >= 0 {
+			r.column = 0
+			r.tail = r.tail[:0]
+			data = data[i+1:]
+		}
+	}
+	r.tail = append(r.tail, data...)
+	if len(r.tail) > columnTailSize {
+		// the incomplete rune at the end is kept
+		var rest []byte
+		r.column, rest = countColumn(r.encoding, r.column, r.tail, r.encoding.MaxRuneLen())
+		r.tail = r.tail[:copy(r.tail, rest)]
+	}
+}
+
+// columnAt returns the column of the given position, the count of runes between the last
+// line break and the position.
+func (r Xio) columnAt(pos int64) (column int) {
+	data, err := r.Range(int(r.pos), int(pos))
+	common.AssertNoError(err, "data range error")
+	if len(r.tail) > 0 {
+		data = append(r.tail[:len(r.tail):len(r.tail)], data...)
+	}
+	column, _ = countColumn(r.encoding, r.column, data, 1)
+	return
+}
+
+// countColumn returns the column after the runes of data decoded by the encoding. It stops
+// if less than keep bytes are left and returns the rest of data.
+func countColumn(enc Encoding, column int, data []byte, keep int) (int, []byte) {
+	for len(data) >= keep && len(data) > 0 {
+		r, w := enc.DecodeRune(data)
+		data = data[max(w, 1):]
+		if r == '\n' {
+			column = 0
+		} else {
+			column++
+		}
+	}
+	return column, data
+}
+
+// Column implements Position interface. It returns the column of the committed position.
+func (r Xio) Column() int {
+	return r.columnAt(r.offset)
+}
+
+// Origin implements Position interface.
+func (r Xio) Origin() int64 {
+	return r.origin
+}
+
+// Fetch fetches `size` bytes from the reader and appends them to the buffer.
+// If the maximum of the buffered bytes is reached, it fetches less bytes and
+// returns ErrLookaheadExceeded.
 func (r Xio) Fetch(size int64) (n int64, err error) {
 	if size <= 0 {
 		return
 	}
-	r.buffer.Grow(int(size))
-	n, err = io.CopyN(r.buffer, r.reader, size)
+	if r.static || r.push && r.closed {
+		err = io.EOF
+		return
+	}
+	if r.push {
+		err = ErrNeedMore
+		return
+	}
+	allowed := size
+	if r.maxBuffered > 0 {
+		allowed = min(size, r.maxBuffered-int64(r.buffer.Len()))
+	}
+	read, err := r.buffer.Fill(r.reader, int(max(allowed, 0)))
+	n = int64(read)
+	if err == nil && allowed < size {
+		err = ErrLookaheadExceeded
+	}
 	return
 }
 
 // ReadAt reads from the buffered reader from given position and returns the number of bytes read.
 func (r Xio) ReadAt(pos int64, out []byte) (n int, err error) {
-	common.AssertFalse(pos < r.pos || pos < r.offset, "out of bounds")
+	common.AssertFalse(pos < r.pos, "out of bounds")
+	if r.maxLookahead > 0 && pos+int64(len(out)) > r.pos+r.maxLookahead {
+		allowed := max(r.pos+r.maxLookahead-pos, 0)
+		n, err = r.ReadAt(pos, out[:allowed])
+		if err == nil {
+			err = ErrLookaheadExceeded
+		}
+		return
+	}
 	end := int(pos) + len(out)
 	if r.len() >= end {
 		n, err = r.copyTo(pos, out)
@@ -127,7 +337,9 @@ func (r Xio) ReadAt(pos int64, out []byte) (n int, err error) {
 		return
 	}
 	_, err = r.Fetch(int64(end - r.len()))
-	common.AssertNoErrorOrIs(err, io.EOF, "fetch error")
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, ErrLookaheadExceeded) && !errors.Is(err, ErrNeedMore) {
+		common.AssertNoError(err, "fetch error")
+	}
 	// We need separate error variable to preserve original fetch error
 	// in particular case of io.EOF.
 	var copyErr error
@@ -136,6 +348,22 @@ func (r Xio) ReadAt(pos int64, out []byte) (n int, err error) {
 	return
 }
 
+// Encoding implements Encoded interface.
+func (r Xio) Encoding() Encoding {
+	return r.encoding
+}
+
+// SetEncoding implements Reencode interface.
+func (r *Xio) SetEncoding(enc Encoding) {
+	common.AssertNotNil(enc, "nil encoding")
+	if len(r.tail) > 0 && enc.Name() != r.encoding.Name() {
+		// the kept data are counted by the encoding they are read with
+		r.column, _ = countColumn(r.encoding, r.column, r.tail, 1)
+		r.tail = r.tail[:0]
+	}
+	r.encoding = enc
+}
+
 // Buffer returns the buffer and its offset. It does not affect the state.
 func (r Xio) Buffer() (ret []byte, offset int64, err error) {
 	offset = r.offset
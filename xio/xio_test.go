@@ -118,3 +118,67 @@ func TestReader_Truncate(t *testing.T) {
 		)
 	}
 }
+
+func TestReader_Limits(t *testing.T) {
+	logger := logger.New(
+		logger.WithLevel(logger.Trace),
+		logger.WithWriter(os.Stdout),
+	)
+
+	input := bytes.Repeat([]byte("0123456789"), 1000)
+	r := New(logger, bytes.NewBuffer(input), WithMaxLookahead(8), WithMaxBuffered(16))
+
+	capacity := -1
+	for pos := 0; pos < len(input); pos += 4 {
+		tx := r.Begin().Deref()
+		data := make([]byte, 4)
+		n, err := tx.Read(data)
+		assert.NoError(t, err)
+		assert.Equal(t, input[pos:pos+n], data[:n])
+		assert.NoError(t, AsTx(tx).Commit())
+		// the buffer memory is reused across truncates
+		if capacity == -1 {
+			capacity = r.buffer.Cap()
+		}
+		assert.Equal(t, capacity, r.buffer.Cap())
+		assert.LessOrEqual(t, r.buffer.Len(), 16)
+	}
+
+	r = New(logger, bytes.NewBuffer(input), WithMaxLookahead(8))
+	tx := r.Begin().Deref()
+	data := make([]byte, 10)
+	n, err := tx.Read(data)
+	assert.ErrorIs(t, err, ErrLookaheadExceeded)
+	assert.Equal(t, 8, n)
+	assert.True(t, tx.Has())
+	_, err = tx.NextByte()
+	assert.ErrorIs(t, err, ErrLookaheadExceeded)
+	assert.NoError(t, AsTx(tx).Rollback())
+
+	r = New(logger, bytes.NewBuffer(input), WithMaxBuffered(4))
+	tx = r.Begin().Deref()
+	n, err = tx.Read(data)
+	assert.ErrorIs(t, err, ErrLookaheadExceeded)
+	assert.Equal(t, 4, n)
+	assert.NoError(t, AsTx(tx).Rollback())
+}
+
+func TestReader_LimitsNotLogged(t *testing.T) {
+	var out bytes.Buffer
+	logger := logger.New(
+		logger.WithLevel(logger.Error),
+		logger.WithWriter(&out),
+	)
+
+	r := New(logger, bytes.NewBufferString("0123456789"), WithMaxLookahead(2))
+	tx := r.Begin().Deref()
+	_, err := tx.Read(make([]byte, 2))
+	assert.NoError(t, err)
+	_, _, err = tx.NextRune()
+	assert.ErrorIs(t, err, ErrLookaheadExceeded)
+	_, err = AsBits(tx).NextBits(8, MSBFirst)
+	assert.ErrorIs(t, err, ErrLookaheadExceeded)
+	assert.NoError(t, AsTx(tx).Rollback())
+	// the reached limit is the expected control flow, not a read error
+	assert.Empty(t, out.String())
+}
@@ -0,0 +1,195 @@
+package xunicode
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// graphemeBreak is a Grapheme_Cluster_Break property value.
+type graphemeBreak uint8
+
+const (
+	gbOther graphemeBreak = iota
+	gbCR
+	gbLF
+	gbControl
+	gbExtend
+	gbZWJ
+	gbRegionalIndicator
+	gbPrepend
+	gbSpacingMark
+	gbL
+	gbV
+	gbT
+	gbLV
+	gbLVT
+)
+
+const (
+	hangulSBase  = 0xAC00
+	hangulSCount = 11172
+	hangulTCount = 28
+)
+
+// graphemeBreakOf returns the Grapheme_Cluster_Break property value of the rune.
+func graphemeBreakOf(r rune) graphemeBreak {
+	switch {
+	case r == '\r':
+		return gbCR
+	case r == '\n':
+		return gbLF
+	case r == ZWJ:
+		return gbZWJ
+	case r >= hangulSBase && r < hangulSBase+hangulSCount:
+		if (r-hangulSBase)%hangulTCount == 0 {
+			return gbLV
+		}
+		return gbLVT
+	case (r >= 0x1100 && r <= 0x115F) || (r >= 0xA960 && r <= 0xA97C):
+		return gbL
+	case (r >= 0x1160 && r <= 0x11A7) || (r >= 0xD7B0 && r <= 0xD7C6):
+		return gbV
+	case (r >= 0x11A8 && r <= 0x11FF) || (r >= 0xD7CB && r <= 0xD7FB):
+		return gbT
+	case unicode.Is(regionalIndicator, r):
+		return gbRegionalIndicator
+	case unicode.Is(prepend, r):
+		return gbPrepend
+	case r == ZWNJ, unicode.In(r, unicode.Mn, unicode.Me, unicode.Other_Grapheme_Extend, emojiModifier):
+		return gbExtend
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Cs, unicode.Zl, unicode.Zp):
+		return gbControl
+	case unicode.Is(extraSpacingMark, r):
+		return gbSpacingMark
+	case unicode.Is(unicode.Mc, r) && !unicode.Is(notSpacingMark, r):
+		return gbSpacingMark
+	default:
+		return gbOther
+	}
+}
+
+// IsExtendedPictographic reports whether the rune has the Extended_Pictographic property.
+func IsExtendedPictographic(r rune) bool {
+	return unicode.Is(extendedPictographic, r)
+}
+
+// Segmenter splits a sequence of runes into extended grapheme clusters (UAX #29).
+// The zero value is ready to use.
+type Segmenter struct {
+	started bool
+	prev    graphemeBreak
+	// emoji is true if the current cluster ends with ExtPict Extend*
+	emoji bool
+	// emojiZWJ is true if the current cluster ends with ExtPict Extend* ZWJ
+	emojiZWJ bool
+	// ri is the count of the sequential regional indicators before the current rune
+	ri int
+}
+
+// Reset resets the segmenter to its initial state.
+func (s *Segmenter) Reset() {
+	*s = Segmenter{}
+}
+
+func isControlBreak(gb graphemeBreak) bool {
+	return gb == gbControl || gb == gbCR || gb == gbLF
+}
+
+// isBoundary implements the grapheme cluster boundary rules GB3-GB999.
+func (s Segmenter) isBoundary(gb graphemeBreak, pictographic bool) bool {
+	switch {
+	// GB3
+	case s.prev == gbCR && gb == gbLF:
+		return false
+	// GB4, GB5
+	case isControlBreak(s.prev), isControlBreak(gb):
+		return true
+	// GB6
+	case s.prev == gbL && (gb == gbL || gb == gbV || gb == gbLV || gb == gbLVT):
+		return false
+	// GB7
+	case (s.prev == gbLV || s.prev == gbV) && (gb == gbV || gb == gbT):
+		return false
+	// GB8
+	case (s.prev == gbLVT || s.prev == gbT) && gb == gbT:
+		return false
+	// GB9, GB9a
+	case gb == gbExtend, gb == gbZWJ, gb == gbSpacingMark:
+		return false
+	// GB9b
+	case s.prev == gbPrepend:
+		return false
+	// GB11
+	case s.emojiZWJ && pictographic:
+		return false
+	// GB12, GB13
+	case s.prev == gbRegionalIndicator && gb == gbRegionalIndicator && s.ri%2 == 1:
+		return false
+	// GB999
+	default:
+		return true
+	}
+}
+
+// Break reports whether there is a grapheme cluster boundary before the given rune
+// and advances the segmenter. The first rune always starts a new cluster.
+func (s *Segmenter) Break(r rune) (ret bool) {
+	gb := graphemeBreakOf(r)
+	pictographic := IsExtendedPictographic(r)
+	ret = !s.started || s.isBoundary(gb, pictographic)
+	s.started = true
+	switch {
+	case pictographic:
+		s.emoji = true
+		s.emojiZWJ = false
+	case gb == gbExtend && s.emoji && !ret:
+	case gb == gbZWJ && s.emoji && !ret:
+		s.emoji = false
+		s.emojiZWJ = true
+	default:
+		s.emoji = false
+		s.emojiZWJ = false
+	}
+	if gb == gbRegionalIndicator {
+		s.ri++
+	} else {
+		s.ri = 0
+	}
+	s.prev = gb
+	return
+}
+
+// FirstGrapheme returns the width in bytes of the first extended grapheme cluster
+// of the UTF-8 encoded data. It returns 0 if data is empty.
+func FirstGrapheme(data []byte) (w int) {
+	var s Segmenter
+	for w < len(data) {
+		r, rw := utf8.DecodeRune(data[w:])
+		if s.Break(r) && w > 0 {
+			break
+		}
+		w += rw
+	}
+	return
+}
+
+// Graphemes splits the string into extended grapheme clusters.
+func Graphemes(str string) (ret []string) {
+	var s Segmenter
+	start := 0
+	for i, r := range str {
+		if s.Break(r) && i > 0 {
+			ret = append(ret, str[start:i])
+			start = i
+		}
+	}
+	if start < len(str) {
+		ret = append(ret, str[start:])
+	}
+	return
+}
+
+// GraphemeCount returns the number of extended grapheme clusters in the string.
+func GraphemeCount(str string) int {
+	return len(Graphemes(str))
+}
@@ -0,0 +1,50 @@
+package xunicode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphemes(t *testing.T) {
+	type testCase struct {
+		name  string
+		input string
+		want  []string
+	}
+
+	tests := []testCase{
+		{name: "empty", input: "", want: nil},
+		{name: "ascii", input: "abc", want: []string{"a", "b", "c"}},
+		{name: "crlf", input: "a\r\nb", want: []string{"a", "\r\n", "b"}},
+		{name: "lf cr", input: "\n\r", want: []string{"\n", "\r"}},
+		{name: "combining marks", input: "e\u0301\u0302x", want: []string{"e\u0301\u0302", "x"}},
+		{name: "control and extend", input: "\n\u0301", want: []string{"\n", "\u0301"}},
+		{name: "emoji modifier", input: "\U0001F44D\U0001F3FDa", want: []string{"\U0001F44D\U0001F3FD", "a"}},
+		{
+			name:  "emoji zwj sequence",
+			input: "\U0001F468\u200d\U0001F469\u200d\U0001F467!",
+			want:  []string{"\U0001F468\u200d\U0001F469\u200d\U0001F467", "!"},
+		},
+		{name: "zwj without emoji", input: "a\u200d\U0001F469", want: []string{"a\u200d", "\U0001F469"}},
+		{
+			name:  "flags",
+			input: "\U0001F1FA\U0001F1E6\U0001F1FA\U0001F1F8\U0001F1FA",
+			want:  []string{"\U0001F1FA\U0001F1E6", "\U0001F1FA\U0001F1F8", "\U0001F1FA"},
+		},
+		{name: "hangul syllables", input: "한글", want: []string{"한", "글"}},
+		{name: "hangul jamo", input: "한ᄀ", want: []string{"한", "ᄀ"}},
+		{name: "spacing mark", input: "कि", want: []string{"कि"}},
+		{name: "prepend", input: "؀١", want: []string{"؀١"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Graphemes(tc.input))
+			assert.Equal(t, len(tc.want), GraphemeCount(tc.input))
+			if len(tc.want) > 0 {
+				assert.Equal(t, len(tc.want[0]), FirstGrapheme([]byte(tc.input)))
+			}
+		})
+	}
+}
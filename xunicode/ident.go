@@ -0,0 +1,93 @@
+// Package xunicode contains Unicode properties and algorithms which are not
+// provided by the standard unicode package, but are required by the lexer states.
+package xunicode
+
+import "unicode"
+
+const (
+	// ZWNJ is the ZERO WIDTH NON-JOINER rune.
+	ZWNJ = '\u200C'
+	// ZWJ is the ZERO WIDTH JOINER rune.
+	ZWJ = '\u200D'
+)
+
+var (
+	// idStart is the set of ID_Start categories: L, Nl and Other_ID_Start.
+	idStart = []*unicode.RangeTable{
+		unicode.L,
+		unicode.Nl,
+		unicode.Other_ID_Start,
+	}
+
+	// idContinue is the set of ID_Continue categories in addition to ID_Start.
+	idContinue = []*unicode.RangeTable{
+		unicode.Mn,
+		unicode.Mc,
+		unicode.Nd,
+		unicode.Pc,
+		unicode.Other_ID_Continue,
+	}
+
+	// idExcluded is the set of runes which never can be used in identifiers.
+	idExcluded = []*unicode.RangeTable{
+		unicode.Pattern_Syntax,
+		unicode.Pattern_White_Space,
+	}
+
+	// notXIDStart lists ID_Start runes which are not XID_Start because
+	// they are not closed under NFKC normalization.
+	notXIDStart = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{Lo: 0x037A, Hi: 0x037A, Stride: 1},
+			{Lo: 0x0E33, Hi: 0x0E33, Stride: 1},
+			{Lo: 0x0EB3, Hi: 0x0EB3, Stride: 1},
+			{Lo: 0x309B, Hi: 0x309C, Stride: 1},
+			{Lo: 0xFC5E, Hi: 0xFC63, Stride: 1},
+			{Lo: 0xFDFA, Hi: 0xFDFB, Stride: 1},
+			{Lo: 0xFE70, Hi: 0xFE7E, Stride: 2},
+			{Lo: 0xFF9E, Hi: 0xFF9F, Stride: 1},
+		},
+	}
+
+	// notXIDContinue lists ID_Continue runes which are not XID_Continue because
+	// they are not closed under NFKC normalization.
+	notXIDContinue = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{Lo: 0x037A, Hi: 0x037A, Stride: 1},
+			{Lo: 0x309B, Hi: 0x309C, Stride: 1},
+			{Lo: 0xFC5E, Hi: 0xFC63, Stride: 1},
+			{Lo: 0xFDFA, Hi: 0xFDFB, Stride: 1},
+			{Lo: 0xFE70, Hi: 0xFE7E, Stride: 2},
+		},
+	}
+)
+
+// IsIDStart reports whether the rune has the ID_Start property.
+func IsIDStart(r rune) bool {
+	return unicode.In(r, idStart...) && !unicode.In(r, idExcluded...)
+}
+
+// IsIDContinue reports whether the rune has the ID_Continue property.
+// ZWJ and ZWNJ are ID_Continue since Unicode 15.1 regardless of the
+// unicode package version.
+func IsIDContinue(r rune) bool {
+	if IsIDStart(r) || IsJoinControl(r) {
+		return true
+	}
+	return unicode.In(r, idContinue...) && !unicode.In(r, idExcluded...)
+}
+
+// IsXIDStart reports whether the rune has the XID_Start property (UAX #31).
+func IsXIDStart(r rune) bool {
+	return IsIDStart(r) && !unicode.Is(notXIDStart, r)
+}
+
+// IsXIDContinue reports whether the rune has the XID_Continue property (UAX #31).
+func IsXIDContinue(r rune) bool {
+	return IsIDContinue(r) && !unicode.Is(notXIDContinue, r)
+}
+
+// IsJoinControl reports whether the rune is ZWJ or ZWNJ.
+func IsJoinControl(r rune) bool {
+	return r == ZWJ || r == ZWNJ
+}
@@ -0,0 +1,44 @@
+package xunicode
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentifierProperties(t *testing.T) {
+	type testCase struct {
+		r                rune
+		idStart          bool
+		idContinue       bool
+		xidStart         bool
+		xidContinue      bool
+		joinControlValue bool
+	}
+
+	tests := []testCase{
+		{r: 'a', idStart: true, idContinue: true, xidStart: true, xidContinue: true},
+		{r: '1', idContinue: true, xidContinue: true},
+		{r: '_', idContinue: true, xidContinue: true},
+		{r: '$'},
+		{r: ' '},
+		{r: 'ж', idStart: true, idContinue: true, xidStart: true, xidContinue: true},
+		{r: '\u0301', idContinue: true, xidContinue: true},
+		{r: '℘', idStart: true, idContinue: true, xidStart: true, xidContinue: true},
+		{r: '\u037A', idStart: true, idContinue: true},
+		{r: '\u0E33', idStart: true, idContinue: true, xidContinue: true},
+		{r: '\u2E2F'},
+		{r: ZWJ, idContinue: true, xidContinue: true, joinControlValue: true},
+		{r: ZWNJ, idContinue: true, xidContinue: true, joinControlValue: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.r), func(t *testing.T) {
+			assert.Equal(t, tc.idStart, IsIDStart(tc.r), "ID_Start")
+			assert.Equal(t, tc.idContinue, IsIDContinue(tc.r), "ID_Continue")
+			assert.Equal(t, tc.xidStart, IsXIDStart(tc.r), "XID_Start")
+			assert.Equal(t, tc.xidContinue, IsXIDContinue(tc.r), "XID_Continue")
+			assert.Equal(t, tc.joinControlValue, IsJoinControl(tc.r), "Join_Control")
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package xunicode
+
+// The table below contains the Script_Extensions property values which are not
+// provided by the unicode package. It follows ScriptExtensions.txt of Unicode 15.0
+// for the marks and the punctuation shared by the scripts which are commonly used
+// in identifiers. The runes missing in the table use their Script property value.
+
+// scriptExtensions are the Script_Extensions values sorted by the range start.
+var scriptExtensions = []scriptExtension{
+	{0x0342, 0x0342, []string{"Greek"}},
+	{0x0345, 0x0345, []string{"Greek"}},
+	{0x0363, 0x036F, []string{"Latin"}},
+	{0x0483, 0x0483, []string{"Cyrillic", "Old_Permic"}},
+	{0x0484, 0x0484, []string{"Cyrillic", "Glagolitic"}},
+	{0x0485, 0x0486, []string{"Cyrillic", "Latin"}},
+	{0x0487, 0x0487, []string{"Cyrillic", "Glagolitic"}},
+	{0x0589, 0x0589, []string{"Armenian", "Georgian"}},
+	{0x060C, 0x060C, []string{"Arabic", "Nko", "Hanifi_Rohingya", "Syriac", "Thaana", "Yezidi"}},
+	{0x061B, 0x061B, []string{"Arabic", "Nko", "Hanifi_Rohingya", "Syriac", "Thaana", "Yezidi"}},
+	{0x061F, 0x061F, []string{"Adlam", "Arabic", "Nko", "Hanifi_Rohingya", "Syriac", "Thaana", "Yezidi"}},
+	{0x0640, 0x0640, []string{"Adlam", "Arabic", "Mandaic", "Manichaean", "Old_Uyghur", "Psalter_Pahlavi", "Hanifi_Rohingya", "Sogdian", "Syriac"}},
+	{0x064B, 0x0655, []string{"Arabic", "Syriac"}},
+	{0x0660, 0x0669, []string{"Arabic", "Thaana", "Yezidi"}},
+	{0x0670, 0x0670, []string{"Arabic", "Syriac"}},
+	{0x06D4, 0x06D4, []string{"Arabic", "Hanifi_Rohingya"}},
+	{0x0951, 0x0951, []string{"Bengali", "Devanagari", "Grantha", "Gujarati", "Gurmukhi", "Kannada", "Latin", "Malayalam", "Oriya", "Sharada", "Tamil", "Telugu", "Tirhuta"}},
+	{0x0952, 0x0952, []string{"Bengali", "Devanagari", "Grantha", "Gujarati", "Gurmukhi", "Kannada", "Latin", "Malayalam", "Oriya", "Tamil", "Telugu", "Tirhuta"}},
+	{0x10FB, 0x10FB, []string{"Georgian", "Latin"}},
+	{0x1802, 0x1803, []string{"Mongolian", "Phags_Pa"}},
+	{0x1805, 0x1805, []string{"Mongolian", "Phags_Pa"}},
+	{0x1DC0, 0x1DC1, []string{"Greek"}},
+	{0x2E43, 0x2E43, []string{"Cyrillic", "Glagolitic"}},
+	{0x3001, 0x3003, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana", "Yi"}},
+	{0x3008, 0x3011, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana", "Yi"}},
+	{0x3013, 0x3013, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana"}},
+	{0x3014, 0x301B, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana", "Yi"}},
+	{0x301C, 0x301F, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana"}},
+	{0x302A, 0x302D, []string{"Bopomofo", "Han"}},
+	{0x3030, 0x3030, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana"}},
+	{0x3031, 0x3035, []string{"Hiragana", "Katakana"}},
+	{0x3037, 0x3037, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana"}},
+	{0x3099, 0x309C, []string{"Hiragana", "Katakana"}},
+	{0x30A0, 0x30A0, []string{"Hiragana", "Katakana"}},
+	{0x30FB, 0x30FB, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana", "Yi"}},
+	{0x30FC, 0x30FC, []string{"Hiragana", "Katakana"}},
+	{0xA66F, 0xA66F, []string{"Cyrillic", "Glagolitic"}},
+	{0xA700, 0xA707, []string{"Han", "Latin"}},
+	{0xFF61, 0xFF65, []string{"Bopomofo", "Hangul", "Han", "Hiragana", "Katakana", "Yi"}},
+	{0xFF70, 0xFF70, []string{"Hiragana", "Katakana"}},
+	{0xFF9E, 0xFF9F, []string{"Hiragana", "Katakana"}},
+}
@@ -0,0 +1,247 @@
+package xunicode
+
+import (
+	"slices"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// bidiKind is the kind of the bidi formatting character.
+type bidiKind uint8
+
+const (
+	bidiNone bidiKind = iota
+	// bidiEmbedding is LRE, RLE, LRO or RLO, it is terminated by PDF.
+	bidiEmbedding
+	// bidiIsolate is LRI, RLI or FSI, it is terminated by PDI.
+	bidiIsolate
+	// bidiPDF is POP DIRECTIONAL FORMATTING.
+	bidiPDF
+	// bidiPDI is POP DIRECTIONAL ISOLATE.
+	bidiPDI
+)
+
+func bidiKindOf(r rune) bidiKind {
+	switch r {
+	case '\u202A', '\u202B', '\u202D', '\u202E':
+		return bidiEmbedding
+	case '\u2066', '\u2067', '\u2068':
+		return bidiIsolate
+	case '\u202C':
+		return bidiPDF
+	case '\u2069':
+		return bidiPDI
+	default:
+		return bidiNone
+	}
+}
+
+// IsBidiControl reports whether the rune has the Bidi_Control property, i.e. it is
+// one of the explicit bidirectional formatting characters or marks.
+func IsBidiControl(r rune) bool {
+	return unicode.Is(unicode.Bidi_Control, r)
+}
+
+// IsParagraphSeparator reports whether the rune has the bidi class B.
+func IsParagraphSeparator(r rune) bool {
+	switch r {
+	case '\n', '\r', 0x1C, 0x1D, 0x1E, 0x85, '\u2029':
+		return true
+	default:
+		return false
+	}
+}
+
+// IsBidiBalanced reports whether all bidi embeddings and overrides of the string are
+// terminated by PDF and all isolates are terminated by PDI before the end of the
+// paragraph (UAX #9). Unbalanced controls can make the rendered text differ from
+// its logical order ("Trojan Source", CVE-2021-42574).
+func IsBidiBalanced(s string) bool {
+	var stack []bidiKind
+	for _, r := range s {
+		if IsParagraphSeparator(r) {
+			if len(stack) > 0 {
+				return false
+			}
+			continue
+		}
+		switch kind := bidiKindOf(r); kind {
+		case bidiEmbedding, bidiIsolate:
+			stack = append(stack, kind)
+		case bidiPDF:
+			// PDF terminates the last embedding only inside the current isolate
+			if len(stack) > 0 && stack[len(stack)-1] == bidiEmbedding {
+				stack = stack[:len(stack)-1]
+			}
+		case bidiPDI:
+			// PDI terminates the last isolate and all embeddings inside it
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i] == bidiIsolate {
+					stack = stack[:i]
+					break
+				}
+			}
+		}
+	}
+	return len(stack) == 0
+}
+
+type (
+	// scriptRange is the range of runes of the script.
+	scriptRange struct {
+		lo, hi rune
+		script string
+	}
+
+	// scriptExtension is the range of runes with the Script_Extensions value.
+	scriptExtension struct {
+		lo, hi  rune
+		scripts []string
+	}
+)
+
+// scriptRanges are the not overlapping ranges of unicode.Scripts sorted by the range start.
+var scriptRanges = makeScriptRanges()
+
+func makeScriptRanges() (ret []scriptRange) {
+	for name, table := range unicode.Scripts {
+		for _, r := range table.R16 {
+			ret = appendScriptRange(ret, rune(r.Lo), rune(r.Hi), rune(r.Stride), name)
+		}
+		for _, r := range table.R32 {
+			ret = appendScriptRange(ret, rune(r.Lo), rune(r.Hi), rune(r.Stride), name)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].lo < ret[j].lo })
+	return
+}
+
+// appendScriptRange appends the range, the strided range is split into the single runes,
+// so it doesn't overlap the ranges of the other scripts.
+func appendScriptRange(ranges []scriptRange, lo, hi, stride rune, script string) []scriptRange {
+	if stride == 1 {
+		return append(ranges, scriptRange{lo: lo, hi: hi, script: script})
+	}
+	for r := lo; r <= hi; r += stride {
+		ranges = append(ranges, scriptRange{lo: r, hi: r, script: script})
+	}
+	return ranges
+}
+
+// scriptOf returns the Script property value of the rune.
+func scriptOf(r rune) string {
+	if r < utf8.RuneSelf {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') {
+			return "Latin"
+		}
+		return "Common"
+	}
+	i := sort.Search(len(scriptRanges), func(i int) bool { return scriptRanges[i].hi >= r })
+	if i < len(scriptRanges) && scriptRanges[i].lo <= r {
+		return scriptRanges[i].script
+	}
+	return "Unknown"
+}
+
+// scriptExtensionsOf returns the Script_Extensions property value of the rune, or nil
+// if it is the same as the Script property value.
+func scriptExtensionsOf(r rune) []string {
+	i := sort.Search(len(scriptExtensions), func(i int) bool { return scriptExtensions[i].hi >= r })
+	if i < len(scriptExtensions) && scriptExtensions[i].lo <= r {
+		return scriptExtensions[i].scripts
+	}
+	return nil
+}
+
+// augment returns the augmented script set of the script (UTS #39, 5.1).
+func augment(script string) []string {
+	switch script {
+	case "Han":
+		return []string{"Han", "Hanb", "Jpan", "Kore"}
+	case "Hiragana", "Katakana":
+		return []string{script, "Jpan"}
+	case "Hangul":
+		return []string{script, "Kore"}
+	case "Bopomofo":
+		return []string{script, "Hanb"}
+	default:
+		return []string{script}
+	}
+}
+
+// augmentedScripts returns the augmented script set of the rune (UTS #39, 5.1) built
+// from its Script_Extensions. It returns nil for Common and Inherited runes which match
+// any script.
+func augmentedScripts(r rune) (ret []string) {
+	extensions := scriptExtensionsOf(r)
+	if extensions == nil {
+		switch script := scriptOf(r); script {
+		case "Common", "Inherited":
+			return nil
+		default:
+			extensions = []string{script}
+		}
+	}
+	for _, script := range extensions {
+		for _, augmented := range augment(script) {
+			if !slices.Contains(ret, augmented) {
+				ret = append(ret, augmented)
+			}
+		}
+	}
+	return
+}
+
+// intersect returns the scripts of a which are present in b.
+func intersect(a, b []string) (ret []string) {
+	for _, s := range a {
+		for _, t := range b {
+			if s == t {
+				ret = append(ret, s)
+				break
+			}
+		}
+	}
+	return
+}
+
+// IsMixedScript reports whether the string is mixed-script according to UTS #39,
+// i.e. the intersection of the augmented script sets of its runes is empty. Common
+// and Inherited runes are compatible with any script, so "abc123" is single-script,
+// while "p\u0430ypal" with Cyrillic a (U+0430) is not.
+func IsMixedScript(s string) bool {
+	var resolved []string
+	all := true
+	for _, r := range s {
+		scripts := augmentedScripts(r)
+		if scripts == nil {
+			continue
+		}
+		if all {
+			resolved = scripts
+			all = false
+			continue
+		}
+		if resolved = intersect(resolved, scripts); len(resolved) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ScriptsOf returns the sorted list of scripts used by the string runes.
+// Common and Inherited scripts are not included.
+func ScriptsOf(s string) (ret []string) {
+	seen := map[string]bool{}
+	for _, r := range s {
+		script := scriptOf(r)
+		if script == "Common" || script == "Inherited" || seen[script] {
+			continue
+		}
+		seen[script] = true
+		ret = append(ret, script)
+	}
+	sort.Strings(ret)
+	return
+}
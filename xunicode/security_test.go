@@ -0,0 +1,102 @@
+package xunicode
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsBidiBalanced(t *testing.T) {
+	type testCase struct {
+		name  string
+		input string
+		want  bool
+	}
+
+	tests := []testCase{
+		{name: "no controls", input: "// comment", want: true},
+		{name: "marks only", input: "a\u200Eb\u200F", want: true},
+		{name: "closed override", input: "\u202Eabc\u202C", want: true},
+		{name: "closed isolate", input: "\u2067abc\u2069", want: true},
+		{name: "isolate closes nested embedding", input: "\u2066\u202Babc\u2069", want: true},
+		{name: "open override", input: "/* \u202E } \u2066 if admin */", want: false},
+		{name: "open isolate", input: "\u2067abc", want: false},
+		{name: "pdf does not close isolate", input: "\u2066abc\u202C", want: false},
+		{name: "paragraph end", input: "\u202Eabc\n\u202C", want: false},
+		{name: "unmatched pdi is ignored", input: "abc\u2069", want: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsBidiBalanced(tc.input))
+		})
+	}
+}
+
+func TestIsBidiControl(t *testing.T) {
+	for _, r := range []rune{'\u061C', '\u200E', '\u200F', '\u202A', '\u202E', '\u2066', '\u2069'} {
+		assert.True(t, IsBidiControl(r), "%U", r)
+	}
+	for _, r := range []rune{'a', ' ', ZWJ, '\u2029'} {
+		assert.False(t, IsBidiControl(r), "%U", r)
+	}
+}
+
+func TestIsMixedScript(t *testing.T) {
+	type testCase struct {
+		name        string
+		input       string
+		want        bool
+		wantScripts []string
+	}
+
+	tests := []testCase{
+		{name: "latin", input: "paypal_2", wantScripts: []string{"Latin"}},
+		{name: "common only", input: "_123"},
+		{name: "latin and cyrillic", input: "p\u0430ypal", want: true, wantScripts: []string{"Cyrillic", "Latin"}},
+		{name: "cyrillic with combining mark", input: "й\u0301", wantScripts: []string{"Cyrillic"}},
+		{name: "japanese", input: "漢ひカ", wantScripts: []string{"Han", "Hiragana", "Katakana"}},
+		{name: "korean", input: "漢한", wantScripts: []string{"Han", "Hangul"}},
+		{name: "hiragana and hangul", input: "ひ한", want: true, wantScripts: []string{"Hangul", "Hiragana"}},
+		{name: "greek and latin", input: "αb", want: true, wantScripts: []string{"Greek", "Latin"}},
+		{name: "thaana with arabic digit", input: "\u078B\u0663", wantScripts: []string{"Arabic", "Thaana"}},
+		{name: "katakana with prolonged sound mark", input: "カー", wantScripts: []string{"Katakana"}},
+		{name: "latin with arabic comma", input: "a\u060C", want: true, wantScripts: []string{"Latin"}},
+		{name: "georgian with armenian full stop", input: "\u10D0\u0589", wantScripts: []string{"Armenian", "Georgian"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, IsMixedScript(tc.input))
+			assert.Equal(t, tc.wantScripts, ScriptsOf(tc.input))
+		})
+	}
+}
+
+func TestScriptOf(t *testing.T) {
+	assert.Equal(t, "Latin", scriptOf('é'))
+	assert.Equal(t, "Cyrillic", scriptOf('\u0430'))
+	assert.Equal(t, "Han", scriptOf('漢'))
+	assert.Equal(t, "Inherited", scriptOf('\u0301'))
+	assert.Equal(t, "Unknown", scriptOf('\U000E0080'))
+	// every rune of the script tables is found by the sorted ranges
+	for name, table := range unicode.Scripts {
+		for _, r := range table.R16 {
+			assert.Equal(t, name, scriptOf(rune(r.Hi)), "%U", r.Hi)
+		}
+		for _, r := range table.R32 {
+			assert.Equal(t, name, scriptOf(rune(r.Hi)), "%U", r.Hi)
+		}
+	}
+	// the extensions are sorted, not overlapping and use the known scripts
+	for i, ext := range scriptExtensions {
+		assert.LessOrEqual(t, ext.lo, ext.hi)
+		if i > 0 {
+			assert.Less(t, scriptExtensions[i-1].hi, ext.lo)
+		}
+		for _, script := range ext.scripts {
+			assert.Contains(t, unicode.Scripts, script)
+		}
+	}
+}